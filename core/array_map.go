@@ -298,6 +298,14 @@ func (m *ArrayMap) Pprint(w io.Writer, indent int) int {
 }
 
 func (m *ArrayMap) Format(w io.Writer, indent int) int {
+	if i, ok := formatOverflowing(m, m.arr, w, indent); ok {
+		return i
+	}
+	if FORMAT_STYLE.alignMaps {
+		if i, ok := formatAligned(m.arr, "{", "}", w, indent); ok {
+			return i
+		}
+	}
 	var arr []Object
 	for i := 0; i < len(m.arr); i++ {
 		arr = append(arr, m.arr[i])
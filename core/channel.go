@@ -1,6 +1,7 @@
 package core
 
 import (
+	"sync"
 	"unsafe"
 )
 
@@ -11,6 +12,7 @@ type (
 	}
 	Channel struct {
 		ch       chan FutureResult
+		lock     sync.Mutex
 		isClosed bool
 		hash     uint32
 	}
@@ -55,8 +57,45 @@ func ExtractChannel(args []Object, index int) *Channel {
 }
 
 func (ch *Channel) Close() {
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
 	if !ch.isClosed {
 		close(ch.ch)
 		ch.isClosed = true
 	}
 }
+
+func (ch *Channel) IsClosed() bool {
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	return ch.isClosed
+}
+
+// Put puts v into the channel, blocking until there's room for it.
+// Returns false if the channel is (or gets) closed instead.
+func (ch *Channel) Put(v Object) (ok bool) {
+	if ch.IsClosed() {
+		return false
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+		}
+	}()
+	ch.ch <- MakeFutureResult(v, nil)
+	return true
+}
+
+// Take takes a value from the channel, blocking until one is
+// available. Returns false if the channel is closed and empty.
+// Panics with the error put into the channel, if any.
+func (ch *Channel) Take() (Object, bool) {
+	res, ok := <-ch.ch
+	if !ok {
+		return NIL, false
+	}
+	if res.err != nil {
+		panic(res.err)
+	}
+	return res.value, true
+}
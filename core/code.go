@@ -335,3 +335,22 @@ func (v Var) Namespace() *Namespace {
 func (v *VarRefExpr) Var() *Var {
 	return v.vr
 }
+
+// FreezeRoots moves every var's root (set by SetRoot) back into its
+// Value field, so the reflective code generator sees current values.
+func (env *Env) FreezeRoots() {
+	for _, ns := range env.Namespaces {
+		for _, vr := range ns.mappings {
+			if vr.root != nil {
+				vr.Value = *(*Object)(vr.root)
+				vr.root = nil
+			}
+		}
+	}
+}
+
+// Freeze moves the atom's current value into its initial value.
+func (a *Atom) Freeze() {
+	_, a.value = a.load()
+	a.current = nil
+}
@@ -54,9 +54,9 @@ func (env *Env) SetEnvArgs(newArgs []string) {
 		args.Append(MakeString(arg))
 	}
 	if args.Count() > 0 {
-		env.args.Value = args.Seq()
+		env.args.SetRoot(args.Seq())
 	} else {
-		env.args.Value = NIL
+		env.args.SetRoot(NIL)
 	}
 }
 
@@ -75,7 +75,7 @@ func (env *Env) SetClassPath(cp string) {
 	if cpVec.Count() == 0 {
 		cpVec.Append(MakeString(""))
 	}
-	env.classPath.Value = cpVec
+	env.classPath.SetRoot(cpVec)
 }
 
 /*
@@ -85,20 +85,20 @@ This runs after invariant initialization, which includes calling
 	initializations must be reflected in gen_code/gen_code.go.
 */
 func (env *Env) InitEnv(stdin io.Reader, stdout, stderr io.Writer, args []string) {
-	env.stdin.Value = MakeBufferedReader(stdin)
-	env.stdout.Value = MakeIOWriter(stdout)
-	env.stderr.Value = MakeIOWriter(stderr)
+	env.stdin.SetRoot(MakeBufferedReader(stdin))
+	env.stdout.SetRoot(MakeIOWriter(stdout))
+	env.stderr.SetRoot(MakeIOWriter(stderr))
 	env.SetEnvArgs(args)
 }
 
 func (env *Env) SetStdIO(stdin, stdout, stderr Object) {
-	env.stdin.Value = stdin
-	env.stdout.Value = stdout
-	env.stderr.Value = stderr
+	env.stdin.Set(stdin)
+	env.stdout.Set(stdout)
+	env.stderr.Set(stderr)
 }
 
 func (env *Env) StdIO() (stdin, stdout, stderr Object) {
-	return env.stdin.Value, env.stdout.Value, env.stderr.Value
+	return env.stdin.Resolve(), env.stdout.Resolve(), env.stderr.Resolve()
 }
 
 /*
@@ -108,7 +108,7 @@ This runs after invariant initialization, which includes calling
 	initializations must be reflected in gen_code/gen_code.go.
 */
 func (env *Env) SetMainFilename(filename string) {
-	env.MainFile.Value = MakeString(filename)
+	env.MainFile.SetRoot(MakeString(filename))
 }
 
 /*
@@ -118,25 +118,27 @@ This runs after invariant initialization, which includes calling
 	initializations must be reflected in gen_code/gen_code.go.
 */
 func (env *Env) SetFilename(obj Object) {
-	env.file.Value = obj
+	env.file.SetRoot(obj)
 }
 
 func (env *Env) IsStdIn(obj Object) bool {
-	return env.stdin.Value == obj
+	return env.stdin.Resolve() == obj
 }
 
 func (env *Env) CurrentNamespace() *Namespace {
-	return EnsureObjectIsNamespace(env.ns.Value, "")
+	return EnsureObjectIsNamespace(env.ns.Resolve(), "")
 }
 
 func (env *Env) SetCurrentNamespace(ns *Namespace) {
-	env.ns.Value = ns
+	env.ns.Set(ns)
 }
 
 func (env *Env) EnsureSymbolIsNamespace(sym Symbol) *Namespace {
 	if sym.ns != nil {
 		panic(RT.NewError("Namespace's name cannot be qualified: " + sym.ToString(false)))
 	}
+	nsLock.Lock()
+	defer nsLock.Unlock()
 	if env.Namespaces[sym.name] == nil {
 		env.Namespaces[sym.name] = NewNamespace(sym)
 	}
@@ -145,18 +147,37 @@ func (env *Env) EnsureSymbolIsNamespace(sym Symbol) *Namespace {
 
 func (env *Env) EnsureSymbolIsLib(sym Symbol) *Namespace {
 	ns := env.EnsureSymbolIsNamespace(sym)
-	env.libs.Value.(*MapSet).Add(sym)
+	nsLock.Lock()
+	env.libs.rootValue().(*MapSet).Add(sym)
+	nsLock.Unlock()
 	return ns
 }
 
+func (env *Env) namespace(name *string) *Namespace {
+	nsLock.RLock()
+	defer nsLock.RUnlock()
+	return env.Namespaces[name]
+}
+
+// AllNamespaces returns a copy of the namespaces of env.
+func (env *Env) AllNamespaces() map[*string]*Namespace {
+	nsLock.RLock()
+	defer nsLock.RUnlock()
+	res := make(map[*string]*Namespace, len(env.Namespaces))
+	for k, v := range env.Namespaces {
+		res[k] = v
+	}
+	return res
+}
+
 func (env *Env) NamespaceFor(ns *Namespace, s Symbol) *Namespace {
 	var res *Namespace
 	if s.ns == nil {
 		res = ns
 	} else {
-		res = ns.aliases[s.ns]
+		res = ns.alias(s.ns)
 		if res == nil {
-			res = env.Namespaces[s.ns]
+			res = env.namespace(s.ns)
 		}
 	}
 	if res != nil {
@@ -170,7 +191,7 @@ func (env *Env) ResolveIn(n *Namespace, s Symbol) (*Var, bool) {
 	if ns == nil {
 		return nil, false
 	}
-	if v, ok := ns.mappings[s.name]; ok {
+	if v, ok := ns.mapping(s.name); ok {
 		return v, true
 	}
 	if s.Equals(env.IN_NS_VAR.name) {
@@ -190,7 +211,7 @@ func (env *Env) FindNamespace(s Symbol) *Namespace {
 	if s.ns != nil {
 		return nil
 	}
-	ns := env.Namespaces[s.name]
+	ns := env.namespace(s.name)
 	if ns != nil {
 		ns.MaybeLazy("FindNameSpace")
 	}
@@ -204,6 +225,8 @@ func (env *Env) RemoveNamespace(s Symbol) *Namespace {
 	if s.Equals(SYMBOLS.joker_core) {
 		panic(RT.NewError("Cannot remove core namespace"))
 	}
+	nsLock.Lock()
+	defer nsLock.Unlock()
 	ns := env.Namespaces[s.name]
 	delete(env.Namespaces, s.name)
 	return ns
@@ -233,7 +256,7 @@ func (env *Env) ResolveSymbol(s Symbol) Symbol {
 			ns:   ns.Name.name,
 		}
 	}
-	vr, ok := currentNs.mappings[s.name]
+	vr, ok := currentNs.mapping(s.name)
 	if !ok {
 		return Symbol{
 			name: s.name,
@@ -4,129 +4,190 @@ import (
 	"bytes"
 	"fmt"
 	"strings"
-	"sync"
 	"unsafe"
 )
 
+/*
+   Evaluation doesn't keep a call stack: any number of goroutines
+   may evaluate code at the same time, so there is no single stack
+   to keep. Instead, an error records where it was raised while the
+   panic carrying it unwinds. Eval records the innermost expression
+   being evaluated (the position of the error) and Fn.Call records
+   each function the panic leaves, together with its call site,
+   which is the next expression Eval sees.
+*/
+
 type (
 	Traceable interface {
 		Name() string
 		Pos() Position
 	}
 	EvalError struct {
-		msg  string
-		pos  Position
-		rt   *Runtime
-		hash uint32
+		msg string
+		// Builds msg from the name of the function called by
+		// the innermost call expression, see NewArgTypeError.
+		msgFn     func(name string) string
+		callstack Callstack
+		hash      uint32
 	}
 	Frame struct {
 		traceable Traceable
 	}
+	// Callstack of an error, innermost frame first.
 	Callstack struct {
 		frames []Frame
-	}
-	Runtime struct {
-		callstack   *Callstack
-		currentExpr Expr
-		GIL         sync.Mutex
-	}
+		pos    Position
+		hasPos bool
+		// Set when the error left a function whose call
+		// site hasn't been recorded yet.
+		leftFn bool
+		// Set when the error is passed to another goroutine,
+		// e.g. by a future, to stop recording.
+		sealed bool
+	}
+	Traced interface {
+		Callstack() *Callstack
+	}
+	Runtime struct{}
 )
 
-var RT *Runtime = &Runtime{
-	callstack: &Callstack{frames: make([]Frame, 0, 50)},
-}
+var RT *Runtime = &Runtime{}
 
-func (rt *Runtime) clone() *Runtime {
-	return &Runtime{
-		callstack:   rt.callstack.clone(),
-		currentExpr: rt.currentExpr,
-	}
+func (rt *Runtime) NewError(msg string) *EvalError {
+	return &EvalError{msg: msg}
 }
 
-func (rt *Runtime) NewError(msg string) *EvalError {
-	res := &EvalError{
-		msg: msg,
-		rt:  rt.clone(),
-	}
-	if rt.currentExpr != nil {
-		res.pos = rt.currentExpr.Pos()
-	}
-	return res
+// NewCallError returns an error whose message mentions the name of the
+// function being called, which isn't known until the error is thrown.
+func (rt *Runtime) NewCallError(msgFn func(name string) string) *EvalError {
+	return &EvalError{msgFn: msgFn}
 }
 
 func (rt *Runtime) NewArgTypeError(index int, obj Object, expectedType string) *EvalError {
-	name := rt.currentExpr.(Traceable).Name()
-	return rt.NewError(fmt.Sprintf("Arg[%d] of %s must have type %s, got %s", index, name, expectedType, obj.GetType().ToString(false)))
+	return rt.NewCallError(func(name string) string {
+		return fmt.Sprintf("Arg[%d] of %s must have type %s, got %s", index, name, expectedType, obj.GetType().ToString(false))
+	})
 }
 
 func (rt *Runtime) NewErrorWithPos(msg string, pos Position) *EvalError {
 	return &EvalError{
-		msg: msg,
-		pos: pos,
-		rt:  rt.clone(),
+		msg:       msg,
+		callstack: Callstack{pos: pos, hasPos: true},
 	}
 }
 
-func (rt *Runtime) stacktrace() string {
-	var b bytes.Buffer
-	pos := Position{}
-	if rt.currentExpr != nil {
-		pos = rt.currentExpr.Pos()
+func Eval(expr Expr, env *LocalEnv) Object {
+	defer func() {
+		if r := recover(); r != nil {
+			if t, ok := r.(Traced); ok {
+				if err, ok := r.(*EvalError); ok {
+					err.resolveMsg(expr)
+				}
+				t.Callstack().enterExpr(expr)
+			}
+			panic(r)
+		}
+	}()
+	return expr.Eval(env)
+}
+
+// leaveFn is deferred by Fn.Call.
+func leaveFn() {
+	if r := recover(); r != nil {
+		if t, ok := r.(Traced); ok {
+			t.Callstack().leaveFn()
+		}
+		panic(r)
 	}
-	name := "global"
-	for _, f := range rt.callstack.frames {
-		framePos := f.traceable.Pos()
-		b.WriteString(fmt.Sprintf("  %s %s:%d:%d\n", name, framePos.Filename(), framePos.startLine, framePos.startColumn))
-		name = f.traceable.Name()
-		if strings.HasPrefix(name, "#'") {
-			name = name[2:]
+}
+
+func (s *Callstack) enterExpr(expr Expr) {
+	if s.sealed {
+		return
+	}
+	if !s.hasPos {
+		s.pos = expr.Pos()
+		s.hasPos = true
+	}
+	if s.leftFn {
+		tr, ok := expr.(Traceable)
+		if !ok {
+			// E.g. a watch called by def.
+			tr = &CallExpr{Position: expr.Pos()}
 		}
+		s.frames = append(s.frames, Frame{traceable: tr})
+		s.leftFn = false
 	}
-	b.WriteString(fmt.Sprintf("  %s %s:%d:%d", name, pos.Filename(), pos.startLine, pos.startColumn))
-	return b.String()
 }
 
-func (rt *Runtime) pushFrame() {
-	// TODO: this is all wrong. We cannot rely on
-	// currentExpr for stacktraces. Instead, each Callable
-	// should know it's name / position.
-	var tr Traceable
-	if rt.currentExpr != nil {
-		tr = rt.currentExpr.(Traceable)
-	} else {
-		tr = &CallExpr{}
+func (s *Callstack) leaveFn() {
+	if !s.sealed {
+		s.leftFn = true
 	}
-	rt.callstack.pushFrame(Frame{traceable: tr})
 }
 
-func (rt *Runtime) popFrame() {
-	rt.callstack.popFrame()
+// Seal stops recording frames, so that the error
+// can be rethrown by other goroutines.
+func (s *Callstack) Seal() {
+	s.sealed = true
 }
 
-func Eval(expr Expr, env *LocalEnv) Object {
-	parentExpr := RT.currentExpr
-	RT.currentExpr = expr
-	defer (func() { RT.currentExpr = parentExpr })()
-	return expr.Eval(env)
+// reset forgets the frames recorded so far, e.g. when
+// the error is thrown again.
+func (s *Callstack) reset() {
+	if !s.sealed {
+		*s = Callstack{}
+	}
 }
 
-func (s *Callstack) pushFrame(frame Frame) {
-	s.frames = append(s.frames, frame)
+// recoveredError returns r, recovered from a panic, as an Error.
+func recoveredError(r interface{}) Error {
+	switch r := r.(type) {
+	case Error:
+		return r
+	case error:
+		return RT.NewError(r.Error())
+	default:
+		return RT.NewError(fmt.Sprint(r))
+	}
 }
 
-func (s *Callstack) popFrame() {
-	s.frames = s.frames[:len(s.frames)-1]
+// sealError seals the callstack of err, if it has one.
+func sealError(err Error) {
+	if t, ok := err.(Traced); ok {
+		t.Callstack().Seal()
+	}
 }
 
-func (s *Callstack) clone() *Callstack {
-	res := &Callstack{frames: make([]Frame, len(s.frames))}
-	copy(res.frames, s.frames)
-	return res
+func (s *Callstack) stacktrace() string {
+	var b bytes.Buffer
+	name := "global"
+	for i := len(s.frames) - 1; i >= 0; i-- {
+		f := s.frames[i]
+		framePos := f.traceable.Pos()
+		b.WriteString(fmt.Sprintf("  %s %s:%d:%d\n", name, framePos.Filename(), framePos.startLine, framePos.startColumn))
+		name = f.traceable.Name()
+		if strings.HasPrefix(name, "#'") {
+			name = name[2:]
+		}
+	}
+	b.WriteString(fmt.Sprintf("  %s %s:%d:%d", name, s.pos.Filename(), s.pos.startLine, s.pos.startColumn))
+	return b.String()
+}
+
+// outermostPos returns the position of the outermost
+// call recorded, or the position of the error.
+func (s *Callstack) outermostPos() Position {
+	if len(s.frames) > 0 {
+		return s.frames[len(s.frames)-1].traceable.Pos()
+	}
+	return s.pos
 }
 
 func (s *Callstack) String() string {
 	var b bytes.Buffer
-	for _, f := range s.frames {
+	for i := len(s.frames) - 1; i >= 0; i-- {
+		f := s.frames[i]
 		pos := f.traceable.Pos()
 		b.WriteString(fmt.Sprintf("%s %s:%d:%d\n", f.traceable.Name(), pos.Filename(), pos.startLine, pos.startColumn))
 	}
@@ -136,12 +197,33 @@ func (s *Callstack) String() string {
 	return b.String()
 }
 
-func MakeEvalError(msg string, pos Position, rt *Runtime) *EvalError {
-	res := &EvalError{msg, pos, rt, 0}
+func MakeEvalError(msg string, pos Position) *EvalError {
+	res := RT.NewErrorWithPos(msg, pos)
 	res.hash = HashPtr(uintptr(unsafe.Pointer(res)))
 	return res
 }
 
+func (err *EvalError) Callstack() *Callstack {
+	return &err.callstack
+}
+
+func (err *EvalError) resolveMsg(expr Expr) {
+	if err.msgFn == nil || err.callstack.sealed {
+		return
+	}
+	if tr, ok := expr.(Traceable); ok {
+		err.msg = err.msgFn(tr.Name())
+		err.msgFn = nil
+	}
+}
+
+func (err *EvalError) message() string {
+	if err.msgFn != nil {
+		return err.msgFn("fn")
+	}
+	return err.msg
+}
+
 func (err *EvalError) ToString(escape bool) string {
 	return err.Error()
 }
@@ -167,18 +249,19 @@ func (err *EvalError) WithInfo(info *ObjectInfo) Object {
 }
 
 func (err *EvalError) Message() Object {
-	return MakeString(err.msg)
+	return MakeString(err.message())
 }
 
 func (err *EvalError) Error() string {
-	pos := err.pos
-	if len(err.rt.callstack.frames) > 0 && !LINTER_MODE {
-		return fmt.Sprintf("%s:%d:%d: Eval error: %s\nStacktrace:\n%s", pos.Filename(), pos.startLine, pos.startColumn, err.msg, err.rt.stacktrace())
+	s := &err.callstack
+	pos := s.pos
+	if len(s.frames) > 0 && !LINTER_MODE {
+		return fmt.Sprintf("%s:%d:%d: Eval error: %s\nStacktrace:\n%s", pos.Filename(), pos.startLine, pos.startColumn, err.message(), s.stacktrace())
 	} else {
-		if len(err.rt.callstack.frames) > 0 {
-			pos = err.rt.callstack.frames[0].traceable.Pos()
+		if len(s.frames) > 0 {
+			pos = s.outermostPos()
 		}
-		return fmt.Sprintf("%s:%d:%d: Eval error: %s", pos.Filename(), pos.startLine, pos.startColumn, err.msg)
+		return fmt.Sprintf("%s:%d:%d: Eval error: %s", pos.Filename(), pos.startLine, pos.startColumn, err.message())
 	}
 }
 
@@ -189,7 +272,7 @@ func (expr *VarRefExpr) Eval(env *LocalEnv) Object {
 func (expr *SetMacroExpr) Eval(env *LocalEnv) Object {
 	expr.vr.isMacro = true
 	expr.vr.isUsed = false
-	if fn, ok := expr.vr.Value.(*Fn); ok {
+	if fn, ok := expr.vr.rootValue().(*Fn); ok {
 		fn.isMacro = true
 	}
 	setMacroMeta(expr.vr)
@@ -250,7 +333,7 @@ func (expr *SetExpr) Eval(env *LocalEnv) Object {
 
 func (expr *DefExpr) Eval(env *LocalEnv) Object {
 	if expr.value != nil {
-		expr.vr.Value = Eval(expr.value, env)
+		expr.vr.BindRoot(Eval(expr.value, env))
 	}
 	meta := EmptyArrayMap()
 	meta.Add(KEYWORDS.line, Int{I: expr.startLine})
@@ -316,8 +399,11 @@ func (expr *CallExpr) Name() string {
 
 func (expr *ThrowExpr) Eval(env *LocalEnv) Object {
 	e := Eval(expr.e, env)
-	switch e.(type) {
+	switch e := e.(type) {
 	case Error:
+		if t, ok := e.(Traced); ok {
+			t.Callstack().reset()
+		}
 		panic(e)
 	default:
 		panic(RT.NewError("Cannot throw " + e.ToString(false)))
@@ -364,6 +450,7 @@ func evalBody(body []Expr, env *LocalEnv) Object {
 func evalLoop(body []Expr, env *LocalEnv) Object {
 	var res Object = NIL
 loop:
+	checkInterrupt()
 	for _, expr := range body {
 		res = Eval(expr, env)
 	}
@@ -402,16 +489,16 @@ func (expr *FnArityExpr) Eval(env *LocalEnv) Object {
 
 func (expr *LetExpr) Eval(env *LocalEnv) Object {
 	env = env.addEmptyFrame(len(expr.names))
-	for _, bindingExpr := range expr.values {
-		env.addBinding(Eval(bindingExpr, env))
+	for i, bindingExpr := range expr.values {
+		env.bindings[i] = Eval(bindingExpr, env)
 	}
 	return evalBody(expr.body, env)
 }
 
 func (expr *LoopExpr) Eval(env *LocalEnv) Object {
 	env = env.addEmptyFrame(len(expr.names))
-	for _, bindingExpr := range expr.values {
-		env.addBinding(Eval(bindingExpr, env))
+	for i, bindingExpr := range expr.values {
+		env.bindings[i] = Eval(bindingExpr, env)
 	}
 	return evalLoop(expr.body, env)
 }
@@ -0,0 +1,254 @@
+package core
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+   Evaluations. Vars have a single root shared by every goroutine;
+   dynamic bindings (binding, with-bindings) are made per goroutine,
+   in the Evaluation running on it. An evaluation holds a stack of
+   binding frames: binding pushes a frame with the new bindings on
+   top of those it's nested in, and pops it when done. Reading a
+   bound var returns its value in the top frame, and setting it
+   (var-set, in-ns) changes that value instead of the root.
+
+   Goroutines started by Joker code (futures, go blocks, pmap, pcalls)
+   always get evaluations of their own, with a copy of the current
+   bindings, and with *ns* bound so that changing it doesn't affect
+   any other goroutine. An embedder that evaluates code on behalf of
+   several clients at once (e.g. the nREPL server) begins an evaluation
+   on the goroutine doing the work, with bindings of its own for
+   *in*, *out*, *err* and *ns*. An evaluation can also be interrupted
+   without affecting any other.
+
+   Evaluations are keyed by goroutine id. Looking it up isn't free,
+   so it's only done for vars that are bound somewhere.
+*/
+
+type (
+	Evaluation struct {
+		goid        int64
+		interrupted int32
+		// Only accessed by the evaluation's goroutine.
+		frame *bindingFrame
+		// Set if the evaluation was begun by binding, on
+		// a goroutine that had none. It ends with its last frame.
+		implicit bool
+	}
+	bindingFrame struct {
+		// The boxed values of the vars bound by this frame
+		// and the frames below it.
+		bindings map[*Var]*Object
+		// The vars bound by this frame.
+		own  []*Var
+		prev *bindingFrame
+	}
+)
+
+var evaluations = struct {
+	sync.Mutex
+	m map[int64]*Evaluation
+}{m: map[int64]*Evaluation{}}
+
+// Number of evaluations, and of those interrupted
+// but not aborted yet.
+var evaluationCount, interruptCount int32
+
+func goroutineId() int64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	// The trace starts with "goroutine <id> [".
+	s := bytes.TrimPrefix(buf[:n], []byte("goroutine "))
+	if i := bytes.IndexByte(s, ' '); i >= 0 {
+		s = s[:i]
+	}
+	id, _ := strconv.ParseInt(string(s), 10, 64)
+	return id
+}
+
+func beginEvaluation() *Evaluation {
+	e := &Evaluation{goid: goroutineId()}
+	evaluations.Lock()
+	defer evaluations.Unlock()
+	if _, ok := evaluations.m[e.goid]; ok {
+		panic(RT.NewError("An evaluation is already running on this goroutine"))
+	}
+	evaluations.m[e.goid] = e
+	atomic.AddInt32(&evaluationCount, 1)
+	return e
+}
+
+// BeginEvaluation begins an evaluation on the calling goroutine,
+// with *in*, *out*, *err* and *ns* bound to the given values.
+// The caller must End it when done.
+func (env *Env) BeginEvaluation(stdin, stdout, stderr Object, ns *Namespace) *Evaluation {
+	e := beginEvaluation()
+	e.push(map[*Var]Object{
+		env.stdin:  stdin,
+		env.stdout: stdout,
+		env.stderr: stderr,
+		env.ns:     ns,
+	})
+	return e
+}
+
+// Bind binds v to val in the evaluation until it ends. It must be
+// called by the goroutine that began the evaluation, before it
+// evaluates anything.
+func (e *Evaluation) Bind(v *Var, val Object) {
+	e.frame.bindings[v] = &val
+	e.frame.own = append(e.frame.own, v)
+	atomic.AddInt32(&v.bindingCount, 1)
+}
+
+// push pushes a frame binding the given vars.
+func (e *Evaluation) push(bindings map[*Var]Object) {
+	f := &bindingFrame{bindings: map[*Var]*Object{}, prev: e.frame}
+	if e.frame != nil {
+		for v, b := range e.frame.bindings {
+			f.bindings[v] = b
+		}
+	}
+	for v, val := range bindings {
+		val := val
+		f.bindings[v] = &val
+		f.own = append(f.own, v)
+		atomic.AddInt32(&v.bindingCount, 1)
+	}
+	e.frame = f
+}
+
+// pop pops the top frame.
+func (e *Evaluation) pop() {
+	for _, v := range e.frame.own {
+		atomic.AddInt32(&v.bindingCount, -1)
+	}
+	e.frame = e.frame.prev
+}
+
+// End ends the evaluation. It must be called by the goroutine
+// that began it.
+func (e *Evaluation) End() {
+	for e.frame != nil {
+		e.pop()
+	}
+	evaluations.Lock()
+	defer evaluations.Unlock()
+	delete(evaluations.m, e.goid)
+	atomic.AddInt32(&evaluationCount, -1)
+	if atomic.CompareAndSwapInt32(&e.interrupted, 1, 0) {
+		atomic.AddInt32(&interruptCount, -1)
+	}
+}
+
+// Interrupt asks the code being evaluated to abort evaluation
+// at its next function call or loop iteration. Goroutines started
+// by the evaluation aren't interrupted. Interrupt may be called
+// from any goroutine.
+func (e *Evaluation) Interrupt() {
+	if atomic.CompareAndSwapInt32(&e.interrupted, 0, 1) {
+		atomic.AddInt32(&interruptCount, 1)
+	}
+}
+
+func currentEvaluation() *Evaluation {
+	if atomic.LoadInt32(&evaluationCount) == 0 {
+		return nil
+	}
+	goid := goroutineId()
+	evaluations.Lock()
+	defer evaluations.Unlock()
+	return evaluations.m[goid]
+}
+
+// evaluationBinding returns the value v is bound to
+// on the current goroutine, if any.
+func evaluationBinding(v *Var) (Object, bool) {
+	if atomic.LoadInt32(&v.bindingCount) == 0 {
+		return nil, false
+	}
+	if e := currentEvaluation(); e != nil && e.frame != nil {
+		if b, ok := e.frame.bindings[v]; ok {
+			return *b, true
+		}
+	}
+	return nil, false
+}
+
+// Set sets the value v is bound to on the current
+// goroutine, if any, or else its root.
+func (v *Var) Set(val Object) {
+	if atomic.LoadInt32(&v.bindingCount) > 0 {
+		if e := currentEvaluation(); e != nil && e.frame != nil {
+			if b, ok := e.frame.bindings[v]; ok {
+				*b = val
+				return
+			}
+		}
+	}
+	v.SetRoot(val)
+}
+
+// pushBindings binds the given vars on the current goroutine
+// until the matching popBindings.
+func pushBindings(bindings map[*Var]Object) {
+	e := currentEvaluation()
+	if e == nil {
+		e = beginEvaluation()
+		e.implicit = true
+	}
+	e.push(bindings)
+}
+
+func popBindings() {
+	e := currentEvaluation()
+	if e == nil || e.frame == nil {
+		panic(RT.NewError("Pop without matching push"))
+	}
+	e.pop()
+	if e.frame == nil && e.implicit {
+		e.End()
+	}
+}
+
+// currentBindings returns the values of the vars
+// bound on the current goroutine.
+func currentBindings() map[*Var]Object {
+	res := map[*Var]Object{}
+	if e := currentEvaluation(); e != nil && e.frame != nil {
+		for v, b := range e.frame.bindings {
+			res[v] = *b
+		}
+	}
+	return res
+}
+
+// goConveying calls f in a new goroutine, in an evaluation with
+// a copy of the current bindings and with *ns* bound.
+func goConveying(f func()) {
+	bindings := currentBindings()
+	if _, ok := bindings[GLOBAL_ENV.ns]; !ok {
+		bindings[GLOBAL_ENV.ns] = GLOBAL_ENV.ns.Resolve()
+	}
+	go func() {
+		e := beginEvaluation()
+		defer e.End()
+		e.push(bindings)
+		f()
+	}()
+}
+
+func checkInterrupt() {
+	if atomic.LoadInt32(&interruptCount) == 0 {
+		return
+	}
+	if e := currentEvaluation(); e != nil && atomic.CompareAndSwapInt32(&e.interrupted, 1, 0) {
+		atomic.AddInt32(&interruptCount, -1)
+		panic(RT.NewError("Evaluation interrupted"))
+	}
+}
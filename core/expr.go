@@ -103,7 +103,7 @@ func (expr *DefExpr) Dump(pos bool) Map {
 func (expr *CallExpr) InferType() *Type {
 	switch callableExpr := expr.callable.(type) {
 	case *VarRefExpr:
-		switch f := callableExpr.vr.Value.(type) {
+		switch f := callableExpr.vr.rootValue().(type) {
 		case *Fn:
 			if arity := selectArity(f.fnExpr, len(expr.args)); arity != nil && arity.taggedType != nil {
 				return arity.taggedType
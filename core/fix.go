@@ -0,0 +1,390 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+   Automatic fixes for mechanical linter warnings (--lint --fix).
+
+   The source is read again the way the formatter reads it (keeping
+   comments), warnings are mapped back to the forms they were reported
+   for, and each fix becomes a text edit. Everything outside of the
+   edited ranges is left untouched. The ns form is the exception:
+   when a require is removed, or :require is not sorted, it is
+   rebuilt and printed by the formatter (unless it has comments,
+   which the formatter would move while sorting).
+*/
+
+type (
+	fixNode struct {
+		obj      Object
+		parent   *fixNode
+		children []*fixNode
+		removed  bool
+	}
+
+	// TextEdit replaces runes [Start, End) of the source with Text.
+	TextEdit struct {
+		Start int
+		End   int
+		Text  string
+	}
+
+	fixer struct {
+		src   []rune
+		lines []int // rune offset of the start of each line
+		nodes map[[2]int]*fixNode
+		edits []TextEdit
+		ns    *fixNode
+	}
+)
+
+func newFixNode(obj Object, parent *fixNode) *fixNode {
+	node := &fixNode{obj: obj, parent: parent}
+	var children []Object
+	switch obj := obj.(type) {
+	case *ArrayMap:
+		children = obj.arr
+	case Vec:
+		for i := 0; i < obj.Count(); i++ {
+			children = append(children, obj.At(i))
+		}
+	case Seq:
+		if !obj.Equals(NIL) {
+			children = ToSlice(obj)
+		}
+	}
+	for _, child := range children {
+		// Skip surrogate map values the reader adds for comments.
+		if child.GetInfo() != nil {
+			node.children = append(node.children, newFixNode(child, node))
+		}
+	}
+	return node
+}
+
+func (f *fixer) index(node *fixNode) {
+	if info := node.obj.GetInfo(); info != nil {
+		key := [2]int{info.startLine, info.startColumn}
+		if _, ok := f.nodes[key]; !ok {
+			f.nodes[key] = node
+		}
+	}
+	for _, child := range node.children {
+		f.index(child)
+	}
+}
+
+func (f *fixer) offset(line, column int) int {
+	if line < 1 || line > len(f.lines) {
+		return -1
+	}
+	return f.lines[line-1] + column
+}
+
+func (f *fixer) start(node *fixNode) int {
+	info := node.obj.GetInfo()
+	return f.offset(info.startLine, info.startColumn-1) - utf8.RuneCountInString(info.prefix)
+}
+
+func (f *fixer) end(node *fixNode) int {
+	info := node.obj.GetInfo()
+	return f.offset(info.endLine, info.endColumn)
+}
+
+func (f *fixer) text(start, end int) string {
+	return string(f.src[start:end])
+}
+
+func (f *fixer) edit(start, end int, text string) {
+	if start >= 0 && end >= start && end <= len(f.src) {
+		f.edits = append(f.edits, TextEdit{Start: start, End: end, Text: text})
+	}
+}
+
+func (node *fixNode) indexInParent() int {
+	if node.parent != nil {
+		for i, child := range node.parent.children {
+			if child == node {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func (node *fixNode) hasComments() bool {
+	for _, child := range node.children {
+		if isComment(child.obj) || child.hasComments() {
+			return true
+		}
+	}
+	return false
+}
+
+func isListWithHead(node *fixNode, head Object) bool {
+	if _, ok := node.obj.(*List); !ok || len(node.children) == 0 {
+		return false
+	}
+	return node.children[0].obj.Equals(head)
+}
+
+func isRequireClause(node *fixNode) bool {
+	return isListWithHead(node, KEYWORDS.require)
+}
+
+func (f *fixer) fixUnusedNamespace(node *fixNode) bool {
+	libspec := node
+	if p := node.parent; p != nil {
+		if _, ok := p.obj.(Vec); ok && p.children[0] == node {
+			libspec = p
+		}
+	}
+	clause := libspec.parent
+	if clause == nil || !isRequireClause(clause) || clause.parent != f.ns ||
+		f.ns.obj.GetInfo().startColumn != 1 || libspec.obj.GetInfo().prefix != "" {
+		return false
+	}
+	libspec.removed = true
+	return true
+}
+
+func (f *fixer) fixUnusedAs(node *fixNode) bool {
+	if _, ok := node.obj.(Symbol); !ok || node.parent == nil {
+		return false
+	}
+	switch node.parent.obj.(type) {
+	case Vec, *ArrayMap:
+	default:
+		return false
+	}
+	i := node.indexInParent()
+	siblings := node.parent.children
+	if i < 1 || !siblings[i-1].obj.Equals(MakeKeyword("as")) {
+		return false
+	}
+	if i >= 2 {
+		f.edit(f.end(siblings[i-2]), f.end(node), "")
+	} else if i+1 < len(siblings) {
+		f.edit(f.start(siblings[i-1]), f.start(siblings[i+1]), "")
+	} else {
+		f.edit(f.start(siblings[i-1]), f.end(node), "")
+	}
+	return true
+}
+
+func (f *fixer) fixRedundantDo(node *fixNode) bool {
+	if !isListWithHead(node, SYMBOLS.do) || len(node.children) < 2 ||
+		node.obj.GetInfo().prefix != "" || node.hasComments() {
+		return false
+	}
+	body := node.children[1:]
+	f.edit(f.start(node), f.end(node), f.text(f.start(body[0]), f.end(body[len(body)-1])))
+	return true
+}
+
+func (f *fixer) fixIfWithoutElse(node *fixNode) bool {
+	if !isListWithHead(node, MakeSymbol("if")) || len(node.children) != 3 {
+		return false
+	}
+	head := node.children[0]
+	f.edit(f.start(head), f.end(head), "when")
+	return true
+}
+
+func isRequireSorted(clause *fixNode) bool {
+	var libs []Object
+	for _, child := range clause.children[1:] {
+		if isComment(child.obj) {
+			return true
+		}
+		libs = append(libs, child.obj)
+	}
+	return sort.IsSorted(RequireSort(libs))
+}
+
+// rebuild returns node's object without the removed forms
+// and the clauses that became empty.
+func rebuild(node *fixNode) Object {
+	if len(node.children) == 0 {
+		return node.obj
+	}
+	if _, ok := node.obj.(*List); !ok {
+		return node.obj
+	}
+	var objs []Object
+	for _, child := range node.children {
+		if child.removed {
+			continue
+		}
+		if isRequireClause(child) {
+			clause := rebuild(child).(*List)
+			if clause.Count() == 1 {
+				continue
+			}
+			objs = append(objs, clause)
+			continue
+		}
+		objs = append(objs, child.obj)
+	}
+	return NewListFrom(objs...).WithInfo(node.obj.GetInfo())
+}
+
+// removeLibspec cuts clause's child i out of the source
+// along with the comments that belong to it: the ones on
+// the lines above it, up to the previous libspec, and the one
+// following it on its last line. A comment after the clause head
+// belongs to the first libspec.
+func (f *fixer) removeLibspec(clause *fixNode, i int) {
+	children := clause.children
+	first, last := i, i
+	for first > 1 && isComment(children[first-1].obj) &&
+		(first == 2 || children[first-2].obj.GetInfo().endLine != children[first-1].obj.GetInfo().startLine) {
+		first--
+	}
+	if last+1 < len(children) && isComment(children[last+1].obj) &&
+		children[last+1].obj.GetInfo().startLine == children[last].obj.GetInfo().endLine {
+		last++
+	}
+	switch prev := children[first-1]; {
+	case !isComment(prev.obj):
+		f.edit(f.end(prev), f.end(children[last]), "")
+	case last+1 < len(children):
+		// The previous comment runs to the end of its line,
+		// so whatever follows the cut must stay on the next one.
+		f.edit(f.start(children[first]), f.start(children[last+1]), "")
+	default:
+		f.edit(f.start(children[first]), f.end(children[last]), "")
+	}
+}
+
+func (f *fixer) fixNs(force bool) {
+	if f.ns == nil || f.ns.obj.GetInfo().startColumn != 1 {
+		return
+	}
+	if f.ns.hasComments() {
+		// The formatter would move comments around when sorting
+		// :require, so just cut the removed libspecs out.
+		for _, clause := range f.ns.children {
+			for i, libspec := range clause.children {
+				if libspec.removed {
+					f.removeLibspec(clause, i)
+				}
+			}
+		}
+		return
+	}
+	changed := force
+	for _, clause := range f.ns.children {
+		if isRequireClause(clause) && !isRequireSorted(clause) {
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	var b bytes.Buffer
+	formatObject(rebuild(f.ns), 0, &b)
+	if b.String() != f.text(f.start(f.ns), f.end(f.ns)) {
+		f.edit(f.start(f.ns), f.end(f.ns), b.String())
+	}
+}
+
+// readForFix reads all top level forms of src the way
+// the formatter does, or returns false if src can't be read.
+func readForFix(src string, filename string) (objs []Object, ok bool) {
+	linterMode, formatMode, threshold := LINTER_MODE, FORMAT_MODE, HASHMAP_THRESHOLD
+	problemCount := PROBLEM_COUNT
+	LINTER_MODE, FORMAT_MODE, HASHMAP_THRESHOLD = false, true, 100000
+	defer func() {
+		LINTER_MODE, FORMAT_MODE, HASHMAP_THRESHOLD = linterMode, formatMode, threshold
+		PROBLEM_COUNT = problemCount
+	}()
+	reader := NewReader(strings.NewReader(src), filename)
+	for {
+		obj, err := TryRead(reader)
+		if err != nil {
+			return objs, err == io.EOF
+		}
+		objs = append(objs, obj)
+	}
+}
+
+// FixSource computes the edits that fix the given diagnostics
+// in src, the contents of filename. It returns the fixed source
+// and the diagnostics it fixed.
+func FixSource(src string, filename string, diagnostics []Diagnostic) (string, []Diagnostic) {
+	objs, ok := readForFix(src, filename)
+	if !ok {
+		return src, nil
+	}
+	f := &fixer{src: []rune(src), lines: []int{0}, nodes: map[[2]int]*fixNode{}}
+	for i, r := range f.src {
+		if r == '\n' {
+			f.lines = append(f.lines, i+1)
+		}
+	}
+	for _, obj := range objs {
+		node := newFixNode(obj, nil)
+		if f.ns == nil && isListWithHead(node, SYMBOLS.ns) {
+			f.ns = node
+		}
+		f.index(node)
+	}
+
+	var fixed []Diagnostic
+	nsChanged := false
+	for _, d := range diagnostics {
+		if d.Filename() != filename {
+			continue
+		}
+		node := f.nodes[[2]int{d.startLine, d.startColumn}]
+		if node == nil {
+			continue
+		}
+		ok := false
+		switch d.Rule {
+		case "unused-namespace":
+			ok = f.fixUnusedNamespace(node)
+			nsChanged = nsChanged || ok
+		case "unused-binding":
+			ok = f.fixUnusedAs(node)
+		case "redundant-do":
+			ok = f.fixRedundantDo(node)
+		case "if-without-else":
+			ok = f.fixIfWithoutElse(node)
+		}
+		if ok {
+			fixed = append(fixed, d)
+		}
+	}
+	f.fixNs(nsChanged)
+	return ApplyEdits(src, f.edits), fixed
+}
+
+// ApplyEdits applies non-overlapping edits to src. Duplicate
+// and overlapping edits (e.g. for a warning reported twice)
+// are dropped.
+func ApplyEdits(src string, edits []TextEdit) string {
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].Start < edits[j].Start
+	})
+	runes := []rune(src)
+	var b strings.Builder
+	pos := 0
+	for _, e := range edits {
+		if e.Start < pos {
+			continue
+		}
+		b.WriteString(string(runes[pos:e.Start]))
+		b.WriteString(e.Text)
+		pos = e.End
+	}
+	b.WriteString(string(runes[pos:]))
+	return b.String()
+}
@@ -82,6 +82,11 @@ func seqFirstAfterForcedBreak(seq Seq, w io.Writer, indent int) (Seq, Object, in
 }
 
 func formatBindings(v Vec, w io.Writer, indent int) int {
+	if FORMAT_STYLE.alignBindings {
+		if i, ok := formatAligned(ToSlice(v.Seq()), "[", "]", w, indent); ok {
+			return i
+		}
+	}
 	return v.Format(w, indent)
 }
 
@@ -232,7 +237,10 @@ func formatSeqEx(seq Seq, w io.Writer, indent int, formatAsDef bool) int {
 	prevObj := obj
 	seq, i = seqFirst(seq, w, i)
 	isDefRecord := false
-	if obj.Equals(SYMBOLS.defrecord) ||
+	indentRule := FORMAT_STYLE.indentFor(obj)
+	if indentRule != nil {
+		isDefRecord = indentRule.inner1
+	} else if obj.Equals(SYMBOLS.defrecord) ||
 		obj.Equals(SYMBOLS.defprotocol) ||
 		obj.Equals(SYMBOLS.extendProtocol) ||
 		obj.Equals(SYMBOLS.reify) ||
@@ -241,10 +249,21 @@ func formatSeqEx(seq Seq, w io.Writer, indent int, formatAsDef bool) int {
 		obj.Equals(SYMBOLS.extendType) {
 		isDefRecord = true
 	}
-	if obj.Equals(SYMBOLS.ns) || isOneAndBodyExpr(obj) {
+	if indentRule != nil {
+		if indentRule.isBody(obj, seq) {
+			restIndent = indent + 2
+		} else {
+			restIndent = indent + 1
+			if !seq.IsEmpty() && !isNewLine(obj, seq.First()) {
+				restIndent = i + 1
+			}
+		}
+	} else if obj.Equals(SYMBOLS.ns) || isOneAndBodyExpr(obj) {
 		seq, prevObj, i = seqFirstAfterSpace(seq, w, i, isDefRecord)
 	} else if obj.Equals(KEYWORDS.require) || obj.Equals(KEYWORDS._import) {
-		seq = sortRequire(seq)
+		if FORMAT_STYLE.sortRequires {
+			seq = sortRequire(seq)
+		}
 		seq, obj, _ = seqFirstAfterSpace(seq, w, i, isDefRecord)
 		for !seq.IsEmpty() {
 			seq, obj, _ = seqFirstAfterForcedBreak(seq, w, i+1)
@@ -297,10 +316,17 @@ func formatSeqEx(seq Seq, w io.Writer, indent int, formatAsDef bool) int {
 		}
 	}
 
+	// Once an argument is wrapped for being too long,
+	// the ones after it go on lines of their own too.
+	wrapped := false
 	for !seq.IsEmpty() {
 		nextObj := seq.First()
 		if isNewLine(obj, nextObj) {
 			seq, prevObj, i = seqFirstAfterBreak(prevObj, seq, w, restIndent, isDefRecord)
+		} else if i > restIndent && !isComment(nextObj) && (wrapped || exceedsLineWidth(nextObj, i+1)) {
+			wrapped = true
+			fmt.Fprint(w, "\n")
+			seq, prevObj, i = seqFirstAfterBreak(prevObj, seq, w, restIndent, isDefRecord)
 		} else {
 			seq, prevObj, i = seqFirstAfterSpace(seq, w, i, isDefRecord)
 		}
@@ -0,0 +1,288 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+/*
+   Formatter style, configured by :format section of .joker file:
+
+     {:format {:indents {defthing [[:block 1]]
+                         my.ns/defroutes [[:inner 0]]
+                         #"^with-" [[:block 1]]}
+               :sort-requires false
+               :blank-lines 1
+               :align-maps true
+               :align-bindings true
+               :max-line-width 100}}
+
+   :indents rules are cljfmt-style. [:block n] indents the body
+   of a form by two spaces if its first n arguments are on the line
+   of the form's symbol, and indents it as a function call otherwise.
+   [:inner 0] always indents the body by two spaces, [:inner 1] does
+   that for the forms inside the form (like methods of defprotocol).
+   A rule for a symbol replaces the built-in indentation for it.
+*/
+
+type (
+	formatIndent struct {
+		block  int // -1 if there is no :block rule
+		inner0 bool
+		inner1 bool
+	}
+	formatIndentRegex struct {
+		re     *regexp.Regexp
+		indent *formatIndent
+	}
+	formatStyle struct {
+		indents       map[string]*formatIndent
+		indentRegexes []formatIndentRegex
+		sortRequires  bool
+		// Blank lines between top-level forms, -1 to keep them as they are.
+		blankLines    int
+		alignMaps     bool
+		alignBindings bool
+		maxLineWidth  int
+	}
+)
+
+var FORMAT_STYLE = defaultFormatStyle()
+
+// Set while the width of a form is measured, to not
+// measure its parts again.
+var formatMeasuring bool
+
+var (
+	keywordFormat        = MakeKeyword("format")
+	keywordIndents       = MakeKeyword("indents")
+	keywordBlock         = MakeKeyword("block")
+	keywordInner         = MakeKeyword("inner")
+	keywordSortRequires  = MakeKeyword("sort-requires")
+	keywordBlankLines    = MakeKeyword("blank-lines")
+	keywordAlignMaps     = MakeKeyword("align-maps")
+	keywordAlignBindings = MakeKeyword("align-bindings")
+	keywordMaxLineWidth  = MakeKeyword("max-line-width")
+)
+
+func defaultFormatStyle() *formatStyle {
+	return &formatStyle{
+		sortRequires: true,
+		blankLines:   -1,
+		maxLineWidth: 80,
+	}
+}
+
+// readFormatStyle sets FORMAT_STYLE from :format section of config.
+// It returns an error message if the section is malformed.
+func readFormatStyle(config Map) string {
+	ok, v := config.Get(keywordFormat)
+	if !ok {
+		return ""
+	}
+	m, ok := v.(Map)
+	if !ok {
+		return ":format value must be a map, got " + v.GetType().ToString(false)
+	}
+	style := defaultFormatStyle()
+	if ok, v := m.Get(keywordIndents); ok {
+		indents, ok := v.(Map)
+		if !ok {
+			return ":indents value (in :format) must be a map, got " + v.GetType().ToString(false)
+		}
+		style.indents = map[string]*formatIndent{}
+		for iter := indents.Iter(); iter.HasNext(); {
+			p := iter.Next()
+			indent, msg := readFormatIndent(p.Value)
+			if msg != "" {
+				return msg
+			}
+			switch k := p.Key.(type) {
+			case Symbol:
+				style.indents[*k.name] = indent
+			case *Regex:
+				style.indentRegexes = append(style.indentRegexes, formatIndentRegex{re: k.R, indent: indent})
+			default:
+				return ":indents keys (in :format) must be symbols or regexes, got " + p.Key.GetType().ToString(false)
+			}
+		}
+	}
+	for _, f := range []struct {
+		k Keyword
+		v *bool
+	}{
+		{keywordSortRequires, &style.sortRequires},
+		{keywordAlignMaps, &style.alignMaps},
+		{keywordAlignBindings, &style.alignBindings},
+	} {
+		if ok, v := m.Get(f.k); ok {
+			b, ok := v.(Boolean)
+			if !ok {
+				return f.k.ToString(false) + " value (in :format) must be a boolean, got " + v.GetType().ToString(false)
+			}
+			*f.v = b.B
+		}
+	}
+	for _, f := range []struct {
+		k Keyword
+		v *int
+	}{
+		{keywordBlankLines, &style.blankLines},
+		{keywordMaxLineWidth, &style.maxLineWidth},
+	} {
+		if ok, v := m.Get(f.k); ok {
+			if v.Equals(NIL) {
+				continue
+			}
+			n, ok := v.(Int)
+			if !ok || n.I < 0 {
+				return f.k.ToString(false) + " value (in :format) must be a non-negative integer, got " + v.ToString(true)
+			}
+			*f.v = n.I
+		}
+	}
+	FORMAT_STYLE = style
+	return ""
+}
+
+func readFormatIndent(obj Object) (*formatIndent, string) {
+	res := &formatIndent{block: -1}
+	rules, ok := obj.(Vec)
+	if !ok {
+		return nil, ":indents rules (in :format) must be vectors like [[:block 1]], got " + obj.ToString(true)
+	}
+	for i := 0; i < rules.Count(); i++ {
+		rule, ok := rules.At(i).(Vec)
+		if !ok || rule.Count() != 2 {
+			return nil, ":indents rule (in :format) must be [:block n] or [:inner depth], got " + rules.At(i).ToString(true)
+		}
+		n, ok := rule.At(1).(Int)
+		if !ok || n.I < 0 {
+			return nil, ":indents rule (in :format) must be [:block n] or [:inner depth], got " + rule.ToString(true)
+		}
+		switch {
+		case rule.At(0).Equals(keywordBlock):
+			res.block = n.I
+		case rule.At(0).Equals(keywordInner) && n.I == 0:
+			res.inner0 = true
+		case rule.At(0).Equals(keywordInner) && n.I == 1:
+			res.inner1 = true
+		case rule.At(0).Equals(keywordInner):
+			return nil, ":inner depth (in :format) must be 0 or 1, got " + rule.ToString(true)
+		default:
+			return nil, ":indents rule (in :format) must be [:block n] or [:inner depth], got " + rule.ToString(true)
+		}
+	}
+	return res, ""
+}
+
+// indentFor returns the configured indentation of forms
+// starting with obj, or nil.
+func (style *formatStyle) indentFor(obj Object) *formatIndent {
+	sym, ok := obj.(Symbol)
+	if !ok {
+		return nil
+	}
+	if indent := style.indents[*sym.name]; indent != nil {
+		return indent
+	}
+	for _, r := range style.indentRegexes {
+		if r.re.MatchString(*sym.name) {
+			return r.indent
+		}
+	}
+	return nil
+}
+
+// isBody returns true if the rest of form
+// (after its symbol obj) is indented as a body.
+func (indent *formatIndent) isBody(obj Object, rest Seq) bool {
+	if indent.inner0 {
+		return true
+	}
+	if indent.block < 0 {
+		return false
+	}
+	prev := obj
+	for i := 0; i < indent.block; i++ {
+		if rest.IsEmpty() || isNewLine(prev, rest.First()) {
+			return false
+		}
+		prev = rest.First()
+		rest = rest.Rest()
+	}
+	return !rest.IsEmpty()
+}
+
+// exceedsLineWidth returns true if obj written at column
+// indent doesn't fit into the configured line width.
+func exceedsLineWidth(obj Object, indent int) bool {
+	if FORMAT_STYLE.maxLineWidth == 0 || formatMeasuring {
+		return false
+	}
+	formatMeasuring = true
+	defer func() {
+		formatMeasuring = false
+	}()
+	var b bytes.Buffer
+	formatObject(obj, indent, &b)
+	line := b.String()
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	return indent+len([]rune(line)) > FORMAT_STYLE.maxLineWidth
+}
+
+// alignedKeyWidth returns the width to pad the keys of key/value
+// pairs objs to, so that their values are aligned. It returns 0 if
+// they aren't laid out one pair per line.
+func alignedKeyWidth(objs []Object) int {
+	if len(objs) < 4 || len(objs)%2 != 0 {
+		return 0
+	}
+	width := 0
+	for i := 0; i < len(objs); i += 2 {
+		k, v := objs[i], objs[i+1]
+		if isComment(k) || isComment(v) || isNewLine(k, v) {
+			return 0
+		}
+		if i > 0 && !isNewLine(objs[i-1], k) {
+			return 0
+		}
+		var b bytes.Buffer
+		n := formatObject(k, 0, &b)
+		if strings.ContainsRune(b.String(), '\n') {
+			return 0
+		}
+		if n > width {
+			width = n
+		}
+	}
+	return width
+}
+
+// formatAligned writes key/value pairs objs between open and close
+// delimiters, one pair per line with their values aligned. It returns
+// false and writes nothing if they aren't laid out one pair per line.
+func formatAligned(objs []Object, open, close string, w io.Writer, indent int) (int, bool) {
+	width := alignedKeyWidth(objs)
+	if width == 0 {
+		return 0, false
+	}
+	fmt.Fprint(w, open)
+	ind := indent + len(open)
+	for i := 0; i < len(objs); i += 2 {
+		if i > 0 {
+			writeNewLines(w, objs[i-1], objs[i])
+			writeIndent(w, indent+len(open))
+		}
+		n := formatObject(objs[i], indent+len(open), w)
+		writeIndent(w, indent+len(open)+width+1-n)
+		ind = formatObject(objs[i+1], indent+len(open)+width+1, w)
+	}
+	fmt.Fprint(w, close)
+	return ind + len(close), true
+}
@@ -0,0 +1,179 @@
+package core
+
+import (
+	"sync"
+	"time"
+	"unsafe"
+)
+
+/*
+   Futures and promises. A future calls its fn in a goroutine of
+   its own; a promise gets its value from whoever delivers it first.
+   Deref blocks until the value is available. An error thrown by
+   a future's fn is rethrown every time the future is dereferenced.
+*/
+
+type (
+	BlockingDeref interface {
+		Deref
+		// DerefTimeout returns timeoutVal if the value isn't
+		// available within timeout.
+		DerefTimeout(timeout time.Duration, timeoutVal Object) Object
+	}
+	Future struct {
+		done  chan struct{}
+		value Object
+		err   Error
+	}
+	Promise struct {
+		done  chan struct{}
+		lock  sync.Mutex
+		value Object
+	}
+)
+
+// MakeFuture starts calling fn in a new goroutine
+// and returns the future of its result. The goroutine
+// gets a copy of the current bindings.
+func MakeFuture(fn Callable) *Future {
+	f := &Future{done: make(chan struct{})}
+	goConveying(func() {
+		defer close(f.done)
+		defer func() {
+			if r := recover(); r != nil {
+				err := recoveredError(r)
+				sealError(err)
+				f.err = err
+			}
+		}()
+		f.value = fn.Call([]Object{})
+	})
+	return f
+}
+
+func (f *Future) ToString(escape bool) string {
+	status := ":pending"
+	if f.IsRealized() {
+		status = ":ready"
+	}
+	return "#object[Future {:status " + status + "}]"
+}
+
+func (f *Future) Equals(other interface{}) bool {
+	return f == other
+}
+
+func (f *Future) GetInfo() *ObjectInfo {
+	return nil
+}
+
+func (f *Future) WithInfo(info *ObjectInfo) Object {
+	return f
+}
+
+func (f *Future) GetType() *Type {
+	return TYPE.Future
+}
+
+func (f *Future) Hash() uint32 {
+	return HashPtr(uintptr(unsafe.Pointer(f)))
+}
+
+func (f *Future) result() Object {
+	if f.err != nil {
+		panic(f.err)
+	}
+	return f.value
+}
+
+func (f *Future) Deref() Object {
+	<-f.done
+	return f.result()
+}
+
+func (f *Future) DerefTimeout(timeout time.Duration, timeoutVal Object) Object {
+	select {
+	case <-f.done:
+		return f.result()
+	case <-time.After(timeout):
+		return timeoutVal
+	}
+}
+
+func (f *Future) IsRealized() bool {
+	select {
+	case <-f.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func MakePromise() *Promise {
+	return &Promise{done: make(chan struct{})}
+}
+
+func (p *Promise) ToString(escape bool) string {
+	status := ":pending"
+	if p.IsRealized() {
+		status = ":ready"
+	}
+	return "#object[Promise {:status " + status + "}]"
+}
+
+func (p *Promise) Equals(other interface{}) bool {
+	return p == other
+}
+
+func (p *Promise) GetInfo() *ObjectInfo {
+	return nil
+}
+
+func (p *Promise) WithInfo(info *ObjectInfo) Object {
+	return p
+}
+
+func (p *Promise) GetType() *Type {
+	return TYPE.Promise
+}
+
+func (p *Promise) Hash() uint32 {
+	return HashPtr(uintptr(unsafe.Pointer(p)))
+}
+
+// Deliver sets the value of the promise and releases
+// its readers. It returns false if the promise
+// has already been delivered.
+func (p *Promise) Deliver(val Object) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.IsRealized() {
+		return false
+	}
+	p.value = val
+	close(p.done)
+	return true
+}
+
+func (p *Promise) Deref() Object {
+	<-p.done
+	return p.value
+}
+
+func (p *Promise) DerefTimeout(timeout time.Duration, timeoutVal Object) Object {
+	select {
+	case <-p.done:
+		return p.value
+	case <-time.After(timeout):
+		return timeoutVal
+	}
+}
+
+func (p *Promise) IsRealized() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
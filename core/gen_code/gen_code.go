@@ -8,6 +8,7 @@ import (
 	"bufio"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"os"
 	"path"
 	"reflect"
@@ -16,8 +17,12 @@ import (
 	"strconv"
 	"strings"
 
+	_ "github.com/candid82/joker/std/filepath"
 	_ "github.com/candid82/joker/std/html"
+	_ "github.com/candid82/joker/std/io"
+	_ "github.com/candid82/joker/std/os"
 	_ "github.com/candid82/joker/std/string"
+	_ "github.com/candid82/joker/std/time"
 
 	. "github.com/candid82/joker/core"
 	"github.com/candid82/joker/core/gen_go"
@@ -96,6 +101,10 @@ var CoreSourceFiles []FileInfo = []FileInfo{
 		Name:     "<joker.better-cond>",
 		Filename: "better_cond.joke",
 	},
+	{
+		Name:     "<joker.http.router>",
+		Filename: "http_router.joke",
+	},
 }
 
 func parseArgs(args []string) {
@@ -319,6 +328,8 @@ func main() {
 	runtime := []string{}
 	imports := NewImports()
 
+	GLOBAL_ENV.FreezeRoots()
+
 	// Mark "everything" as used.
 	ResetUsage()
 
@@ -649,6 +660,15 @@ func (genEnv *GenEnv) emitPtrToRegexp(target string, v reflect.Value) string {
 	return fmt.Sprintf("nil /* %s: &%s */", genEnv.Namespace.ToString(false), source)
 }
 
+func (genEnv *GenEnv) emitPtrToBigInt(target string, v reflect.Value) string {
+	importedAs := AddImport(genEnv.Import, "", "math/big", true)
+	source := fmt.Sprintf("new(%s.Int).SetString(%s, 10)", importedAs, strconv.Quote(v.Interface().(*big.Int).String()))
+	*genEnv.GenGo.Runtime = append(*genEnv.GenGo.Runtime, fmt.Sprintf(`
+	%s, _ = %s`[1:],
+		gen_go.AsTarget(target), source))
+	return fmt.Sprintf("nil /* %s: &%s */", genEnv.Namespace.ToString(false), source)
+}
+
 func coreTypeString(s string) string {
 	return strings.Replace(s, "core.", "", 1)
 }
@@ -837,9 +857,15 @@ func (genEnv *GenEnv) valueHookFn(target string, t reflect.Type, v reflect.Value
 }
 
 func (genEnv *GenEnv) pointerHookFn(target string, ptr, v reflect.Value) string {
+	if a, yes := ptr.Interface().(*Atom); yes {
+		a.Freeze()
+	}
+
 	switch pkg := v.Type().PkgPath(); pkg {
 	case "regexp":
 		return genEnv.emitPtrToRegexp(target, ptr)
+	case "math/big":
+		return genEnv.emitPtrToBigInt(target, ptr)
 	}
 
 	switch pkg := path.Base(v.Type().PkgPath()); pkg {
@@ -862,7 +888,7 @@ func (genEnv *GenEnv) ptrToValueFn(ptr, v reflect.Value) string {
 				if _, found := knownLateInits[sourceVarName]; found {
 					destVarId := uniqueId(destVar)
 					*genEnv.GenGo.Runtime = append(*genEnv.GenGo.Runtime, fmt.Sprintf(`
-	%s.Value = %s.Value`[1:],
+	%s.SetRoot(%s.rootValue())`[1:],
 						destVarId, uniqueId(e.Var())))
 				}
 			}
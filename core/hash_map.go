@@ -10,6 +10,7 @@ type (
 	}
 	Node interface {
 		assoc(shift uint, hash uint32, key Object, val Object, addedLeaf *Box) Node
+		editAssoc(owned ownedNodes, shift uint, hash uint32, key Object, val Object, addedLeaf *Box) Node
 		without(shift uint, hash uint32, key Object) Node
 		find(shift uint, hash uint32, key Object) *Pair
 		nodeSeq() Seq
@@ -865,6 +866,9 @@ func (m *HashMap) kvreduce(c Callable, init Object) Object {
 	for iter.HasNext() {
 		kv := iter.Next()
 		res = c.Call([]Object{res, kv.Key, kv.Value})
+		if r, ok := unreduced(res); ok {
+			return r
+		}
 	}
 	return res
 }
@@ -1,14 +1,26 @@
 package core
 
+import "sync"
+
 type (
 	StringPool map[string]*string
 )
 
+// stringsLock guards STRINGS, which is shared by all goroutines.
+var stringsLock sync.RWMutex
+
 func (p StringPool) Intern(s string) *string {
+	stringsLock.RLock()
 	ss, exists := p[s]
+	stringsLock.RUnlock()
 	if exists {
 		return ss
 	}
+	stringsLock.Lock()
+	defer stringsLock.Unlock()
+	if ss, exists := p[s]; exists {
+		return ss
+	}
 	p[s] = &s
 	return &s
 }
@@ -0,0 +1,376 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+   Width-aware layout of collections, after Wadler's "A prettier
+   printer" and Oppen's pretty printing algorithm.
+
+   A layout is built from text, lines, groups, fills and aligned
+   blocks. A line is printed as a space if the group it belongs to fits
+   into the rest of the line, and as a line break otherwise, so small
+   collections stay on one line and only those that overflow break.
+   The lines of a fill break one at a time, only before the parts that
+   don't fit. The lines inside an aligned block are indented at the
+   column the block starts at. A group can also have a miser layout,
+   used when it starts less than the miser width away from the margin.
+*/
+
+type (
+	layoutKind int
+	layout     struct {
+		kind  layoutKind
+		text  string
+		parts []*layout
+		miser *layout
+	}
+	layoutCmd struct {
+		indent int
+		flat   bool
+		// Set for the separators of fill parts.
+		fillSep bool
+		l       *layout
+	}
+	layoutPrinter struct {
+		w          io.Writer
+		width      int
+		miserWidth int
+		col        int
+		stack      []layoutCmd
+	}
+)
+
+const (
+	layoutText layoutKind = iota
+	layoutLine
+	layoutGroup
+	layoutFill
+	layoutAlign
+)
+
+var layoutSpace = &layout{kind: layoutLine}
+
+var (
+	printRightMargin *Var
+	printMiserWidth  *Var
+)
+
+func textLayout(s string) *layout {
+	return &layout{kind: layoutText, text: s}
+}
+
+func groupLayout(parts ...*layout) *layout {
+	return &layout{kind: layoutGroup, parts: parts}
+}
+
+func alignLayout(parts ...*layout) *layout {
+	return &layout{kind: layoutAlign, parts: parts}
+}
+
+// joinLayouts separates parts with lines.
+func joinLayouts(parts []*layout) []*layout {
+	var res []*layout
+	for i, p := range parts {
+		if i > 0 {
+			res = append(res, layoutSpace)
+		}
+		res = append(res, p)
+	}
+	return res
+}
+
+// bracketLayout lays out parts between open and close.
+func bracketLayout(open string, parts []*layout, close string) *layout {
+	return groupLayout(textLayout(open), innerLayout(parts), textLayout(close))
+}
+
+// innerLayout aligns parts. Parts that are all text fill the lines,
+// others are put on separate lines if they don't fit on one.
+func innerLayout(parts []*layout) *layout {
+	for _, p := range parts {
+		if p.kind != layoutText {
+			return alignLayout(joinLayouts(parts)...)
+		}
+	}
+	return alignLayout(&layout{kind: layoutFill, parts: parts})
+}
+
+func pairsLayout(open string, pairs [][2]*layout, close string) *layout {
+	parts := make([]*layout, len(pairs))
+	for i, p := range pairs {
+		parts[i] = alignLayout(groupLayout(p[0], layoutSpace, p[1]))
+	}
+	return groupLayout(textLayout(open), alignLayout(joinLayouts(parts)...), textLayout(close))
+}
+
+// pprintLayout returns the layout of obj for pprint.
+func pprintLayout(obj Object) *layout {
+	if _, ok := obj.(Pprinter); !ok {
+		return textLayout(obj.ToString(true))
+	}
+	switch obj := obj.(type) {
+	case Map:
+		var pairs [][2]*layout
+		for iter := obj.Iter(); iter.HasNext(); {
+			p := iter.Next()
+			pairs = append(pairs, [2]*layout{pprintLayout(p.Key), pprintLayout(p.Value)})
+		}
+		if r, ok := obj.(*Record); ok {
+			return pairsLayout("#"+r.typ.name+"{", pairs, "}")
+		}
+		return pairsLayout("{", pairs, "}")
+	case Set:
+		var parts []*layout
+		for iter := iter(obj.(Seqable).Seq()); iter.HasNext(); {
+			parts = append(parts, pprintLayout(iter.Next()))
+		}
+		return bracketLayout("#{", parts, "}")
+	case Vec:
+		parts := make([]*layout, obj.Count())
+		for i := range parts {
+			parts[i] = pprintLayout(obj.At(i))
+		}
+		return bracketLayout("[", parts, "]")
+	case Seq:
+		var parts []*layout
+		for iter := iter(obj); iter.HasNext(); {
+			parts = append(parts, pprintLayout(iter.Next()))
+		}
+		return seqLayout(obj, parts)
+	}
+	return textLayout(obj.ToString(true))
+}
+
+// seqLayout lays out the parts of a seq. A seq that starts with
+// a symbol, like a function call, keeps its first argument on the line
+// of the symbol unless it's printed in miser mode.
+func seqLayout(seq Seq, parts []*layout) *layout {
+	res := bracketLayout("(", parts, ")")
+	if _, ok := seq.First().(Symbol); !ok || len(parts) < 3 {
+		return res
+	}
+	head := groupLayout(textLayout("("), parts[0], textLayout(" "), innerLayout(parts[1:]), textLayout(")"))
+	head.miser = res
+	return head
+}
+
+// formatLayout returns the layout of a collection read by the
+// formatter. Forms other than collections are kept as they are.
+func formatLayout(obj Object) *layout {
+	prefix := ""
+	if info := obj.GetInfo(); info != nil {
+		prefix = info.prefix
+	}
+	var res *layout
+	switch obj := obj.(type) {
+	case *ArrayMap:
+		var pairs [][2]*layout
+		for i := 0; i < len(obj.arr); i += 2 {
+			pairs = append(pairs, [2]*layout{formatLayout(obj.arr[i]), formatLayout(obj.arr[i+1])})
+		}
+		res = pairsLayout("{", pairs, "}")
+	case *MapSet:
+		var parts []*layout
+		for iter := iter(obj.m.Keys()); iter.HasNext(); {
+			parts = append(parts, formatLayout(iter.Next()))
+		}
+		res = bracketLayout("#{", parts, "}")
+	case Vec:
+		parts := make([]*layout, obj.Count())
+		for i := range parts {
+			parts[i] = formatLayout(obj.At(i))
+		}
+		res = bracketLayout("[", parts, "]")
+	default:
+		var b bytes.Buffer
+		formatObject(obj, 0, &b)
+		return textLayout(b.String())
+	}
+	if prefix == "" {
+		return res
+	}
+	return &layout{kind: layoutAlign, parts: []*layout{textLayout(prefix), res}}
+}
+
+// formatsOnOneLine returns true if coll was written on one line
+// and has no comments, so the layout engine can break it.
+func formatsOnOneLine(coll Object, objs []Object) bool {
+	info := coll.GetInfo()
+	if info == nil || info.startLine != info.endLine {
+		return false
+	}
+	for _, obj := range objs {
+		if isComment(obj) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatOverflowing writes coll with the layout engine if
+// it overflows the configured line width. objs are its elements.
+func formatOverflowing(coll Object, objs []Object, w io.Writer, indent int) (int, bool) {
+	if FORMAT_STYLE.maxLineWidth == 0 || formatMeasuring || !formatsOnOneLine(coll, objs) {
+		return 0, false
+	}
+	if !exceedsLineWidth(coll, indent) {
+		return 0, false
+	}
+	// The prefix has already been written by formatObject.
+	formatMeasuring = true
+	l := formatLayout(coll)
+	formatMeasuring = false
+	if l.kind == layoutAlign {
+		l = l.parts[1]
+	}
+	return printLayout(w, l, indent, FORMAT_STYLE.maxLineWidth, 0), true
+}
+
+func printLayout(w io.Writer, l *layout, col int, width int, miserWidth int) int {
+	p := &layoutPrinter{w: w, width: width, miserWidth: miserWidth, col: col}
+	p.stack = append(p.stack, layoutCmd{indent: col, l: l})
+	p.run()
+	return p.col
+}
+
+func (p *layoutPrinter) push(indent int, flat bool, parts []*layout) {
+	for i := len(parts) - 1; i >= 0; i-- {
+		p.stack = append(p.stack, layoutCmd{indent: indent, flat: flat, l: parts[i]})
+	}
+}
+
+func (p *layoutPrinter) newLine(indent int) {
+	fmt.Fprint(p.w, "\n")
+	writeIndent(p.w, indent)
+	p.col = indent
+}
+
+func (p *layoutPrinter) run() {
+	for len(p.stack) > 0 {
+		c := p.stack[len(p.stack)-1]
+		p.stack = p.stack[:len(p.stack)-1]
+		if c.fillSep {
+			if p.fits(p.width-p.col-1, layoutCmd{flat: true, l: c.l}, true) {
+				fmt.Fprint(p.w, " ")
+				p.col++
+			} else {
+				p.newLine(c.indent)
+			}
+			p.stack = append(p.stack, layoutCmd{indent: c.indent, l: c.l})
+			continue
+		}
+		l := c.l
+		switch l.kind {
+		case layoutText:
+			fmt.Fprint(p.w, l.text)
+			if i := strings.LastIndexByte(l.text, '\n'); i >= 0 {
+				p.col = utf8.RuneCountInString(l.text[i+1:])
+			} else {
+				p.col += utf8.RuneCountInString(l.text)
+			}
+		case layoutLine:
+			if c.flat {
+				fmt.Fprint(p.w, " ")
+				p.col++
+			} else {
+				p.newLine(c.indent)
+			}
+		case layoutAlign:
+			p.push(p.col, c.flat, l.parts)
+		case layoutGroup:
+			switch {
+			case c.flat || p.fits(p.width-p.col, layoutCmd{flat: true, l: l}, true):
+				p.push(c.indent, true, l.parts)
+			case l.miser != nil && p.miserWidth > 0 && p.width-p.col < p.miserWidth:
+				p.stack = append(p.stack, layoutCmd{indent: c.indent, l: l.miser})
+			default:
+				p.push(c.indent, false, l.parts)
+			}
+		case layoutFill:
+			if c.flat {
+				p.push(c.indent, true, joinLayouts(l.parts))
+				continue
+			}
+			for i := len(l.parts) - 1; i >= 0; i-- {
+				p.stack = append(p.stack, layoutCmd{indent: c.indent, fillSep: i > 0, l: l.parts[i]})
+			}
+		}
+	}
+}
+
+// fits returns true if c, followed by what is on the stack up
+// to the next line break if withRest is set, fits into width.
+func (p *layoutPrinter) fits(width int, c layoutCmd, withRest bool) bool {
+	cmds := []layoutCmd{c}
+	rest := len(p.stack)
+	for width >= 0 {
+		if len(cmds) == 0 {
+			if !withRest || rest == 0 {
+				return true
+			}
+			rest--
+			cmds = append(cmds, p.stack[rest])
+		}
+		c := cmds[len(cmds)-1]
+		cmds = cmds[:len(cmds)-1]
+		if c.fillSep {
+			// The separator may break, like a line of a breaking group.
+			return true
+		}
+		switch c.l.kind {
+		case layoutText:
+			if i := strings.IndexByte(c.l.text, '\n'); i >= 0 {
+				return utf8.RuneCountInString(c.l.text[:i]) <= width
+			}
+			width -= utf8.RuneCountInString(c.l.text)
+		case layoutLine:
+			if !c.flat {
+				return true
+			}
+			width--
+		default:
+			parts := c.l.parts
+			if c.l.kind == layoutFill {
+				parts = joinLayouts(parts)
+			}
+			for i := len(parts) - 1; i >= 0; i-- {
+				cmds = append(cmds, layoutCmd{flat: c.flat, l: parts[i]})
+			}
+		}
+	}
+	return false
+}
+
+// intVarValue returns the value of the core var name, def if it
+// isn't defined and 0 if it's not a number.
+func intVarValue(vr **Var, name string, def int) int {
+	if *vr == nil {
+		*vr = GLOBAL_ENV.CoreNamespace.Resolve(name)
+		if *vr == nil {
+			return def
+		}
+	}
+	if n, ok := (*vr).Resolve().(Int); ok {
+		return n.I
+	}
+	return 0
+}
+
+// pprintLayoutObject pretty prints obj at column indent within
+// *print-right-margin*, in miser mode within *print-miser-width*
+// of the margin.
+func pprintLayoutObject(obj Object, indent int, w io.Writer) int {
+	width := intVarValue(&printRightMargin, "*print-right-margin*", 72)
+	if width <= 0 {
+		width = math.MaxInt32
+	}
+	miserWidth := intVarValue(&printMiserWidth, "*print-miser-width*", 40)
+	return printLayout(w, pprintLayout(obj), indent, width, miserWidth)
+}
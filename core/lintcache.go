@@ -0,0 +1,605 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+/*
+   Support for the persistent lint cache (--lint --cache).
+
+   A file that hasn't changed since the last run is not parsed again:
+   the declarations it made (namespaces and vars, with enough of their
+   values to check calls to them) are restored from the cache instead,
+   and so are the namespaces and vars it used, for the globally unused
+   report, and its inline suppressions.
+*/
+
+type (
+	CachedPosition struct {
+		Line      int `json:"line"`
+		Column    int `json:"column"`
+		EndLine   int `json:"endLine"`
+		EndColumn int `json:"endColumn"`
+	}
+
+	CachedArity struct {
+		// Tags of the arguments, "" for untagged ones.
+		Args []string `json:"args"`
+	}
+
+	CachedValue struct {
+		Kind     string        `json:"kind"`
+		Arities  []CachedArity `json:"arities,omitempty"`
+		Variadic *CachedArity  `json:"variadic,omitempty"`
+		Literal  string        `json:"literal,omitempty"`
+		Type     string        `json:"type,omitempty"`
+	}
+
+	CachedVar struct {
+		Name     string          `json:"name"`
+		Position *CachedPosition `json:"position,omitempty"`
+		Private  bool            `json:"private,omitempty"`
+		Macro    bool            `json:"macro,omitempty"`
+		Dynamic  bool            `json:"dynamic,omitempty"`
+		Tag      string          `json:"tag,omitempty"`
+		Value    *CachedValue    `json:"value,omitempty"`
+		// The declared (unevaluated) meta, e.g. {:private true}.
+		Meta string `json:"meta,omitempty"`
+	}
+
+	CachedNamespace struct {
+		Name string `json:"name"`
+		// Position of the name, if it was last given in the file.
+		Position        *CachedPosition `json:"position,omitempty"`
+		Vars            []CachedVar     `json:"vars,omitempty"`
+		TopLevelCallees []string        `json:"topLevelCallees,omitempty"`
+		// Names of vars of the namespace that now refer to vars of
+		// other namespaces, e.g. after (ns ...) refers joker.core again.
+		Refers map[string]string `json:"refers,omitempty"`
+	}
+
+	// DeclarationSnapshot is what the namespaces declared
+	// before a file is parsed.
+	DeclarationSnapshot struct {
+		// The names mapped to own vars, by namespace.
+		namespaces map[string]map[*string]bool
+	}
+
+	CachedSuppression struct {
+		Position  CachedPosition `json:"position"`
+		Directive CachedPosition `json:"directive"`
+		// nil means all rules.
+		Rules []string `json:"rules,omitempty"`
+		Used  bool     `json:"used,omitempty"`
+	}
+
+	// GlobalUsage is a set of namespaces and vars marked as globally used.
+	GlobalUsage struct {
+		namespaces []*Namespace
+		vars       []*Var
+	}
+
+	CachedUsage struct {
+		Namespaces []string `json:"namespaces,omitempty"`
+		Vars       []string `json:"vars,omitempty"`
+	}
+)
+
+// cachedValueExpr stands in for the value of a var restored from
+// the lint cache that is neither a fn nor a literal: only its type
+// is known.
+type cachedValueExpr struct {
+	Position
+	typ *Type
+}
+
+func (expr *cachedValueExpr) Eval(env *LocalEnv) Object {
+	return NIL
+}
+
+func (expr *cachedValueExpr) InferType() *Type {
+	return expr.typ
+}
+
+func (expr *cachedValueExpr) Dump(includePosition bool) Map {
+	return exprArrayMap(expr, "cached-value", includePosition)
+}
+
+func (expr *cachedValueExpr) Pack(p []byte, env *PackEnv) []byte {
+	return NewLiteralExpr(NIL).Pack(p, env)
+}
+
+func cachedPosition(pos Position) *CachedPosition {
+	return &CachedPosition{
+		Line:      pos.startLine,
+		Column:    pos.startColumn,
+		EndLine:   pos.endLine,
+		EndColumn: pos.endColumn,
+	}
+}
+
+func (p CachedPosition) position(filename string) Position {
+	return Position{
+		filename:    STRINGS.Intern(filename),
+		startLine:   p.Line,
+		startColumn: p.Column,
+		endLine:     p.EndLine,
+		endColumn:   p.EndColumn,
+	}
+}
+
+func tagString(obj Meta) string {
+	if m := obj.GetMeta(); m != nil {
+		if ok, tag := m.Get(KEYWORDS.tag); ok {
+			switch tag := tag.(type) {
+			case Symbol:
+				return tag.ToString(false)
+			case String:
+				return tag.S
+			}
+		}
+	}
+	return ""
+}
+
+func cachedArity(arity *FnArityExpr) CachedArity {
+	res := CachedArity{Args: []string{}}
+	for _, arg := range arity.args {
+		res.Args = append(res.Args, tagString(arg))
+	}
+	return res
+}
+
+func cachedValue(expr Expr) *CachedValue {
+	switch expr := expr.(type) {
+	case nil:
+		return nil
+	case *FnExpr:
+		res := &CachedValue{Kind: "fn"}
+		for i := range expr.arities {
+			res.Arities = append(res.Arities, cachedArity(&expr.arities[i]))
+		}
+		if expr.variadic != nil {
+			variadic := cachedArity(expr.variadic)
+			res.Variadic = &variadic
+		}
+		return res
+	case *MapExpr:
+		return &CachedValue{Kind: "map"}
+	case *SetExpr:
+		return &CachedValue{Kind: "set"}
+	case *VectorExpr:
+		return &CachedValue{Kind: "vector"}
+	case *LiteralExpr:
+		if !expr.isSurrogate {
+			// Only literals that read back the same, e.g. not #'var.
+			literal := expr.obj.ToString(true)
+			if obj, ok := readLiteral(literal); ok && obj.Equals(expr.obj) {
+				return &CachedValue{Kind: "literal", Literal: literal}
+			}
+		}
+	case *cachedValueExpr:
+		if expr.typ != nil {
+			return &CachedValue{Kind: "other", Type: expr.typ.name}
+		}
+		return &CachedValue{Kind: "other"}
+	}
+	res := &CachedValue{Kind: "other"}
+	if t := expr.InferType(); t != nil {
+		res.Type = t.name
+	}
+	return res
+}
+
+func (arity CachedArity) expr() FnArityExpr {
+	res := FnArityExpr{}
+	for _, tag := range arity.Args {
+		arg := MakeSymbol("_")
+		if tag != "" {
+			arg.meta = EmptyArrayMap().Assoc(KEYWORDS.tag, String{S: tag}).(Map)
+		}
+		res.args = append(res.args, arg)
+	}
+	return res
+}
+
+func readLiteral(s string) (obj Object, ok bool) {
+	linterMode := LINTER_MODE
+	LINTER_MODE = false
+	defer func() {
+		LINTER_MODE = linterMode
+		if r := recover(); r != nil {
+			ok = false
+		}
+	}()
+	obj, err := TryRead(NewReader(strings.NewReader(s), "<lint cache>"))
+	return obj, err == nil
+}
+
+func (v *CachedValue) expr() Expr {
+	if v == nil {
+		return nil
+	}
+	switch v.Kind {
+	case "fn":
+		res := &FnExpr{}
+		for _, arity := range v.Arities {
+			res.arities = append(res.arities, arity.expr())
+		}
+		if v.Variadic != nil {
+			variadic := v.Variadic.expr()
+			res.variadic = &variadic
+		}
+		return res
+	case "map":
+		return &MapExpr{}
+	case "set":
+		return &SetExpr{}
+	case "vector":
+		return &VectorExpr{}
+	case "literal":
+		if obj, ok := readLiteral(v.Literal); ok {
+			return NewLiteralExpr(obj)
+		}
+	}
+	return &cachedValueExpr{typ: TYPES[STRINGS.Intern(v.Type)]}
+}
+
+func isDeclaredIn(vr *Var, ns *Namespace, filename string) bool {
+	info := vr.GetInfo()
+	return vr.ns == ns && vr.rootValue() == nil && info != nil && info.Filename() == filename && !isDeclaredInConfig(vr)
+}
+
+func cachedVar(vr *Var) CachedVar {
+	res := CachedVar{
+		Name:    vr.name.ToString(false),
+		Private: vr.isPrivate,
+		Macro:   vr.isMacro,
+		Dynamic: vr.isDynamic,
+		Value:   cachedValue(vr.expr),
+	}
+	if vr.taggedType != nil {
+		res.Tag = vr.taggedType.name
+	}
+	if vr.meta != nil {
+		res.Meta = vr.meta.ToString(true)
+	}
+	return res
+}
+
+func sortCachedVars(vars []CachedVar) {
+	sort.Slice(vars, func(i, j int) bool {
+		return vars[i].Name < vars[j].Name
+	})
+}
+
+func calleeName(vr *Var) string {
+	if vr.ns == nil {
+		return vr.name.ToString(false)
+	}
+	return vr.Name()
+}
+
+// TakeDeclarationSnapshot returns what the namespaces declare now.
+func TakeDeclarationSnapshot() *DeclarationSnapshot {
+	res := &DeclarationSnapshot{namespaces: make(map[string]map[*string]bool)}
+	for _, ns := range GLOBAL_ENV.Namespaces {
+		names := make(map[*string]bool)
+		for name, vr := range ns.mappings {
+			if vr.ns == ns {
+				names[name] = true
+			}
+		}
+		res.namespaces[ns.Name.ToString(false)] = names
+	}
+	return res
+}
+
+// Declarations returns what filename, which has just been parsed,
+// declared: the namespaces it created (those not in before),
+// the vars it defined and the names it referred again.
+func Declarations(filename string, before *DeclarationSnapshot) []CachedNamespace {
+	var res []CachedNamespace
+	for _, ns := range GLOBAL_ENV.Namespaces {
+		name := ns.Name.ToString(false)
+		decl := CachedNamespace{Name: name}
+		own, existed := before.namespaces[name]
+		isNew := !existed
+		for name := range own {
+			if vr := ns.mappings[name]; vr != nil && vr.ns != ns && vr.ns != nil {
+				if decl.Refers == nil {
+					decl.Refers = make(map[string]string)
+				}
+				decl.Refers[*name] = vr.Name()
+			}
+		}
+		if info := ns.Name.GetInfo(); info != nil && info.Filename() == filename {
+			decl.Position = cachedPosition(info.Position)
+		}
+		for _, vr := range ns.mappings {
+			if isDeclaredIn(vr, ns, filename) {
+				v := cachedVar(vr)
+				v.Position = cachedPosition(vr.GetInfo().Position)
+				decl.Vars = append(decl.Vars, v)
+			}
+		}
+		for vr := range ns.topLevelCallees[STRINGS.Intern(filename)] {
+			decl.TopLevelCallees = append(decl.TopLevelCallees, calleeName(vr))
+		}
+		if isNew || decl.Position != nil || len(decl.Vars) > 0 || len(decl.TopLevelCallees) > 0 || len(decl.Refers) > 0 {
+			sortCachedVars(decl.Vars)
+			sort.Strings(decl.TopLevelCallees)
+			res = append(res, decl)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// restoreVar makes the var declared by v as its def in filename does.
+func restoreVar(ns *Namespace, v CachedVar, filename string) *Var {
+	name := MakeSymbol(v.Name)
+	vr := ns.mappings[name.name]
+	if vr == nil || vr.ns != ns {
+		vr = &Var{ns: ns, name: name}
+		ns.mappings[name.name] = vr
+	}
+	if v.Position != nil {
+		vr.WithInfo(&ObjectInfo{Position: v.Position.position(filename)})
+	}
+	vr.isPrivate = v.Private
+	vr.isMacro = v.Macro
+	vr.isDynamic = v.Dynamic
+	vr.taggedType = TYPES[STRINGS.Intern(v.Tag)]
+	vr.expr = v.Value.expr()
+	vr.meta = nil
+	if obj, ok := readLiteral(v.Meta); ok && v.Meta != "" {
+		if m, ok := obj.(Map); ok {
+			vr.meta = m
+		}
+	}
+	return vr
+}
+
+func restoreRefers(ns *Namespace, refers map[string]string) {
+	for name, varName := range refers {
+		sym := MakeSymbol(varName)
+		if other := GLOBAL_ENV.Namespaces[sym.ns]; other != nil {
+			if vr := other.mappings[sym.name]; vr != nil && vr.ns == other {
+				ns.mappings[STRINGS.Intern(name)] = vr
+			}
+		}
+	}
+}
+
+// RestoreDeclarations makes the declarations of filename
+// as if it was parsed again.
+func RestoreDeclarations(filename string, decls []CachedNamespace) {
+	for _, decl := range decls {
+		sym := MakeSymbol(decl.Name)
+		if decl.Position != nil {
+			sym = sym.WithInfo(&ObjectInfo{Position: decl.Position.position(filename)}).(Symbol)
+		}
+		ns := GLOBAL_ENV.EnsureSymbolIsNamespace(sym)
+		if decl.Position != nil {
+			// As create-ns does in linter mode.
+			ns.Name = sym
+		}
+		for _, v := range decl.Vars {
+			restoreVar(ns, v, filename)
+		}
+		restoreRefers(ns, decl.Refers)
+	}
+	for _, decl := range decls {
+		ns := GLOBAL_ENV.Namespaces[STRINGS.Intern(decl.Name)]
+		for _, callee := range decl.TopLevelCallees {
+			vr := &Var{name: MakeSymbol(callee)}
+			sym := MakeSymbol(callee)
+			if sym.ns != nil {
+				if calleeNs := GLOBAL_ENV.Namespaces[sym.ns]; calleeNs != nil {
+					if existing := calleeNs.mappings[sym.name]; existing != nil {
+						vr = existing
+					} else {
+						vr = InternFakeSymbol(calleeNs, sym)
+					}
+				}
+			}
+			ns.addTopLevelCallee(STRINGS.Intern(filename), vr)
+		}
+	}
+}
+
+// NamespacePositions returns the namespaces whose name
+// was last given in filename, with their positions.
+func NamespacePositions(filename string) []CachedNamespace {
+	var res []CachedNamespace
+	for _, ns := range GLOBAL_ENV.Namespaces {
+		if info := ns.Name.GetInfo(); info != nil && info.Filename() == filename {
+			res = append(res, CachedNamespace{Name: ns.Name.ToString(false), Position: cachedPosition(info.Position)})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// ReplayDeclarations does to the namespaces and vars of filename,
+// which is not linted again, what linting it in the second pass would do.
+func ReplayDeclarations(filename string, decls []CachedNamespace, positions []CachedNamespace) {
+	for _, decl := range positions {
+		if ns := GLOBAL_ENV.Namespaces[STRINGS.Intern(decl.Name)]; ns != nil {
+			ns.Name = ns.Name.WithInfo(&ObjectInfo{Position: decl.Position.position(filename)}).(Symbol)
+		}
+	}
+	for _, decl := range decls {
+		ns := GLOBAL_ENV.Namespaces[STRINGS.Intern(decl.Name)]
+		if ns == nil {
+			continue
+		}
+		for _, v := range decl.Vars {
+			restoreVar(ns, v, filename).isPredeclared = false
+		}
+		restoreRefers(ns, decl.Refers)
+	}
+}
+
+// NamespaceHashes returns a hash of what every namespace declares.
+// A file needs to be linted again when any of the namespaces it
+// depends on hashes differently.
+func NamespaceHashes() map[string]string {
+	res := make(map[string]string)
+	for _, ns := range GLOBAL_ENV.Namespaces {
+		if ns == GLOBAL_ENV.CoreNamespace {
+			continue
+		}
+		var vars []CachedVar
+		for _, vr := range ns.mappings {
+			if vr.ns == ns && vr.rootValue() == nil && vr.GetInfo() != nil && !isDeclaredInConfig(vr) {
+				vars = append(vars, cachedVar(vr))
+			}
+		}
+		sortCachedVars(vars)
+		b, _ := json.Marshal(struct {
+			Vars       []CachedVar
+			FullyKnown bool
+		}{vars, isFullyKnown(ns)})
+		sum := sha256.Sum256(b)
+		res[ns.Name.ToString(false)] = hex.EncodeToString(sum[:])
+	}
+	return res
+}
+
+// Dependencies returns the namespaces the file just linted
+// in the current namespace depends on, given what it used.
+func Dependencies(usage CachedUsage) []string {
+	deps := map[string]bool{}
+	ns := GLOBAL_ENV.CurrentNamespace()
+	deps[ns.Name.ToString(false)] = true
+	for _, alias := range ns.aliases {
+		deps[alias.Name.ToString(false)] = true
+	}
+	for _, vr := range ns.mappings {
+		if vr.ns != nil && vr.ns != GLOBAL_ENV.CoreNamespace {
+			deps[vr.ns.Name.ToString(false)] = true
+		}
+	}
+	for _, name := range usage.Namespaces {
+		deps[name] = true
+	}
+	var res []string
+	for name := range deps {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// TakeGlobalUsage returns the namespaces and vars marked
+// as globally used and clears the marks.
+func TakeGlobalUsage() *GlobalUsage {
+	res := &GlobalUsage{}
+	for _, ns := range GLOBAL_ENV.Namespaces {
+		if ns.isGloballyUsed {
+			res.namespaces = append(res.namespaces, ns)
+			ns.isGloballyUsed = false
+		}
+		for _, vr := range ns.mappings {
+			if vr.ns == ns && vr.isGloballyUsed {
+				res.vars = append(res.vars, vr)
+				vr.isGloballyUsed = false
+			}
+		}
+	}
+	return res
+}
+
+// Mark marks the namespaces and vars of u as globally used.
+func (u *GlobalUsage) Mark() {
+	for _, ns := range u.namespaces {
+		ns.isGloballyUsed = true
+	}
+	for _, vr := range u.vars {
+		vr.isGloballyUsed = true
+	}
+}
+
+func (u *GlobalUsage) Cached() CachedUsage {
+	var res CachedUsage
+	for _, ns := range u.namespaces {
+		res.Namespaces = append(res.Namespaces, ns.Name.ToString(false))
+	}
+	for _, vr := range u.vars {
+		res.Vars = append(res.Vars, vr.Name())
+	}
+	sort.Strings(res.Namespaces)
+	sort.Strings(res.Vars)
+	return res
+}
+
+// MarkCachedUsage marks the namespaces and vars of u as globally used.
+func MarkCachedUsage(u CachedUsage) {
+	for _, name := range u.Namespaces {
+		if ns := GLOBAL_ENV.Namespaces[STRINGS.Intern(name)]; ns != nil {
+			ns.isGloballyUsed = true
+		}
+	}
+	for _, name := range u.Vars {
+		sym := MakeSymbol(name)
+		if ns := GLOBAL_ENV.Namespaces[sym.ns]; ns != nil {
+			if vr := ns.mappings[sym.name]; vr != nil {
+				vr.isGloballyUsed = true
+			}
+		}
+	}
+}
+
+// Suppressions returns the inline suppressions read from filename.
+func Suppressions(filename string) []CachedSuppression {
+	var res []CachedSuppression
+	for _, s := range suppressions[STRINGS.Intern(filename)] {
+		cs := CachedSuppression{
+			Position:  *cachedPosition(s.Position),
+			Directive: *cachedPosition(s.directive),
+			Used:      s.used,
+		}
+		if s.rules != nil {
+			cs.Rules = []string{}
+			for seq := s.rules.(Seqable).Seq(); !seq.IsEmpty(); seq = seq.Rest() {
+				if rule, ok := seq.First().(Keyword); ok {
+					cs.Rules = append(cs.Rules, rule.Name())
+				}
+			}
+			sort.Strings(cs.Rules)
+		}
+		res = append(res, cs)
+	}
+	return res
+}
+
+// RestoreSuppressions brings back the inline suppressions of filename.
+func RestoreSuppressions(filename string, cached []CachedSuppression) {
+	key := STRINGS.Intern(filename)
+	delete(suppressions, key)
+	for _, cs := range cached {
+		s := &suppression{
+			Position:  cs.Position.position(filename),
+			directive: cs.Directive.position(filename),
+			used:      cs.Used,
+		}
+		if cs.Rules != nil {
+			rules := EmptySet()
+			for _, rule := range cs.Rules {
+				rules.Add(MakeKeyword(rule))
+			}
+			s.rules = rules
+		}
+		suppressions[key] = append(suppressions[key], s)
+	}
+}
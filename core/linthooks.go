@@ -0,0 +1,248 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+/*
+   Analysis of calls to third-party macros.
+
+   :lint-as in .joker file makes the linter analyze calls to a macro
+   as calls to another one, typically from the core namespace:
+
+     {:lint-as {my.ns/my-let clojure.core/let
+                my.ns/defthing clojure.core/def}}
+
+   :hooks maps a macro to a function defined in one of the .joke
+   files in .jokerd/hooks:
+
+     {:hooks {my.ns/defendpoint hooks.endpoint/defendpoint}}
+
+   A hook is called with the whole call form and returns the form
+   to analyze in its place, much like the macro would expand it.
+   The expansion is for analysis only: nothing is evaluated.
+*/
+
+var (
+	keywordLintAs = MakeKeyword("lint-as")
+	keywordHooks  = MakeKeyword("hooks")
+)
+
+var (
+	// Qualified macro name -> the symbol calls to it are analyzed as.
+	lintAs map[string]Symbol
+	// Qualified macro name -> hook function.
+	lintHooks map[string]Callable
+)
+
+// Special forms can't be referred to by qualified symbols.
+var specialFormNames = map[string]bool{
+	"def":          true,
+	"def-linter__": true,
+	"if":           true,
+	"do":           true,
+	"quote":        true,
+	"var":          true,
+	"fn*":          true,
+	"let*":         true,
+	"letfn*":       true,
+	"loop*":        true,
+	"recur":        true,
+	"throw":        true,
+	"try":          true,
+}
+
+// readLintAs reads :lint-as map of the config. It returns
+// an error message if the map is malformed.
+func readLintAs(config Map) string {
+	lintAs = nil
+	ok, v := config.Get(keywordLintAs)
+	if !ok {
+		return ""
+	}
+	m, ok := v.(Map)
+	if !ok {
+		return ":lint-as value must be a map, got " + v.GetType().ToString(false)
+	}
+	lintAs = make(map[string]Symbol)
+	for iter := m.Iter(); iter.HasNext(); {
+		p := iter.Next()
+		from, ok1 := p.Key.(Symbol)
+		to, ok2 := p.Value.(Symbol)
+		if !ok1 || !ok2 || from.ns == nil || to.ns == nil {
+			return ":lint-as keys and values must be qualified symbols, got " + p.Key.ToString(true) + " " + p.Value.ToString(true)
+		}
+		lintAs[from.ToString(false)] = to
+	}
+	return ""
+}
+
+// lintAsSymbol returns the symbol that replaces the head of calls
+// to macro name, following :lint-as entries that map to each other.
+func lintAsSymbol(name string) (Symbol, bool) {
+	to, ok := lintAs[name]
+	if !ok {
+		return to, false
+	}
+	seen := map[string]bool{name: true}
+	for {
+		next, ok := lintAs[to.ToString(false)]
+		if !ok || seen[to.ToString(false)] {
+			break
+		}
+		seen[to.ToString(false)] = true
+		to = next
+	}
+	switch *to.ns {
+	case "clojure.core", "cljs.core", "joker.core":
+		if specialFormNames[*to.name] {
+			return MakeSymbol(*to.name), true
+		}
+		return Symbol{ns: STRINGS.Intern("joker.core"), name: to.name}, true
+	}
+	return to, true
+}
+
+// loadLintHooks loads the files in .jokerd/hooks and
+// looks up the hook functions named in :hooks map of the config.
+func loadLintHooks(configDir string) {
+	lintHooks = nil
+	if LINTER_CONFIG == nil {
+		return
+	}
+	config, ok := LINTER_CONFIG.Resolve().(Map)
+	if !ok {
+		return
+	}
+	ok, v := config.Get(keywordHooks)
+	if !ok {
+		return
+	}
+	hooks, ok := v.(Map)
+	if !ok {
+		printConfigError(configDir, ":hooks value must be a map, got "+v.GetType().ToString(false))
+		return
+	}
+	current := GLOBAL_ENV.CurrentNamespace()
+	defer GLOBAL_ENV.SetCurrentNamespace(current)
+	// The code being linted doesn't see hook namespaces.
+	defer removeNewNamespaces(namespaceNames())
+	files, _ := filepath.Glob(filepath.Join(configDir, "hooks", "*.joke"))
+	sort.Strings(files)
+	for _, filename := range files {
+		if reader, err := NewReaderFromFile(filename); err == nil {
+			ProcessReader(reader, filename, EVAL)
+		}
+	}
+	lintHooks = make(map[string]Callable)
+	for iter := hooks.Iter(); iter.HasNext(); {
+		p := iter.Next()
+		macro, ok1 := p.Key.(Symbol)
+		hook, ok2 := p.Value.(Symbol)
+		if !ok1 || !ok2 || macro.ns == nil || hook.ns == nil {
+			printConfigError(configDir, ":hooks keys and values must be qualified symbols, got "+p.Key.ToString(true)+" "+p.Value.ToString(true))
+			continue
+		}
+		ns := GLOBAL_ENV.Namespaces[hook.ns]
+		if ns == nil {
+			printConfigError(configDir, "hook namespace "+*hook.ns+" is not defined in "+filepath.Join(configDir, "hooks"))
+			continue
+		}
+		var fn Callable
+		if vr := ns.mappings[hook.name]; vr != nil {
+			fn, _ = vr.rootValue().(Callable)
+		}
+		if fn == nil {
+			printConfigError(configDir, "hook "+hook.ToString(false)+" is not a function")
+			continue
+		}
+		lintHooks[macro.ToString(false)] = fn
+	}
+}
+
+// Hooks that keep expanding into calls to their macro are cut off.
+const maxLintHookDepth = 100
+
+var lintHookDepth int
+
+// parseAnalysisForm parses the form a call to a macro from :lint-as
+// or :hooks is analyzed as. It returns nil if seq isn't such a call.
+func parseAnalysisForm(seq Seq, ctx *ParseContext) Expr {
+	if lintHookDepth >= maxLintHookDepth {
+		return nil
+	}
+	form := analysisForm(seq, ctx)
+	if form == nil {
+		return nil
+	}
+	lintHookDepth++
+	defer func() {
+		lintHookDepth--
+	}()
+	return Parse(form, ctx)
+}
+
+// analysisForm returns the form a call to a macro from :lint-as
+// or :hooks is analyzed as, or nil if seq isn't such a call.
+func analysisForm(seq Seq, ctx *ParseContext) Object {
+	if len(lintAs) == 0 && len(lintHooks) == 0 {
+		return nil
+	}
+	sym, ok := seq.First().(Symbol)
+	if !ok || ctx.GetLocalBinding(sym) != nil {
+		return nil
+	}
+	var name string
+	if vr, ok := ctx.GlobalEnv.Resolve(sym); ok && vr.ns != nil {
+		name = *vr.ns.Name.name + "/" + *vr.name.name
+	} else if sym.ns != nil {
+		if ns := ctx.GlobalEnv.NamespaceFor(ctx.GlobalEnv.CurrentNamespace(), sym); ns != nil {
+			name = *ns.Name.name + "/" + *sym.name
+		}
+	}
+	if name == "" {
+		return nil
+	}
+	if hook := lintHooks[name]; hook != nil {
+		// The macro itself is still used by the call.
+		Parse(sym, ctx)
+		res, err := callLintHook(hook, seq)
+		if err != nil {
+			msg := err.Error()
+			if e, ok := err.(Error); ok {
+				msg = e.Message().ToString(false)
+			}
+			printParseError(GetPosition(seq), "lint-hook-error", fmt.Sprintf("Hook for %s failed: %s", name, msg))
+			return nil
+		}
+		if res.Equals(NIL) || res.Equals(seq) {
+			return nil
+		}
+		return fixInfo(res, seq.GetInfo())
+	}
+	if to, ok := lintAsSymbol(name); ok {
+		Parse(sym, ctx)
+		return fixInfo(seq.Rest().Cons(to.WithInfo(sym.GetInfo())), seq.GetInfo())
+	}
+	return nil
+}
+
+func callLintHook(hook Callable, form Seq) (res Object, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			switch r := r.(type) {
+			case *EvalError:
+				err = r
+			case *ExInfo:
+				err = r
+			case error:
+				err = r
+			default:
+				panic(r)
+			}
+		}
+	}()
+	return hook.Call([]Object{form}), nil
+}
@@ -0,0 +1,317 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+/*
+   User-defined lint rules.
+
+   Every .joke file in .jokerd/rules is evaluated before linting.
+   Public functions with :joker/rule metadata in the namespace
+   the file defines become rules:
+
+     (ns rules.no-println)
+
+     (defn ^:joker/rule no-println [{:keys [form ns vars]}] ...)
+
+   A rule is called with every top-level form once it's parsed and
+   returns nil, a diagnostic or a sequence of diagnostics:
+
+     {:id :no-println :severity :warning :message "..." :form sub-form}
+
+   Diagnostics are reported at the position of :form (the top-level
+   form by default) and can be suppressed like any other warning.
+*/
+
+type lintRule struct {
+	name string
+	fn   Callable
+}
+
+var lintRules []lintRule
+
+// SkipLintRules is set while vars are predeclared from a directory,
+// since nothing is reported in that pass.
+var SkipLintRules bool
+
+var (
+	keywordJokerRule = MakeKeyword("joker/rule")
+	keywordId        = MakeKeyword("id")
+	keywordSeverity  = MakeKeyword("severity")
+	keywordWarning   = MakeKeyword("warning")
+	keywordError     = MakeKeyword("error")
+	keywordVars      = MakeKeyword("vars")
+)
+
+func loadLintRules(configDir string) {
+	lintRules = nil
+	files, _ := filepath.Glob(filepath.Join(configDir, "rules", "*.joke"))
+	sort.Strings(files)
+	for _, filename := range files {
+		loadLintRuleFile(filename)
+	}
+}
+
+func loadLintRuleFile(filename string) {
+	reader, err := NewReaderFromFile(filename)
+	if err != nil {
+		return
+	}
+	current := GLOBAL_ENV.CurrentNamespace()
+	defer GLOBAL_ENV.SetCurrentNamespace(current)
+	// The code being linted doesn't see rule namespaces.
+	defer removeNewNamespaces(namespaceNames())
+	if ProcessReader(reader, filename, EVAL) != nil {
+		return
+	}
+	ns := GLOBAL_ENV.CurrentNamespace()
+	var rules []lintRule
+	for _, vr := range ns.mappings {
+		if vr.ns != ns || vr.isPrivate || vr.meta == nil {
+			continue
+		}
+		if ok, v := vr.meta.Get(keywordJokerRule); ok && ToBool(v) {
+			if fn, ok := vr.rootValue().(Callable); ok {
+				rules = append(rules, lintRule{name: vr.Name(), fn: fn})
+			}
+		}
+	}
+	// Rules of a file run in the order of their names.
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].name < rules[j].name
+	})
+	lintRules = append(lintRules, rules...)
+}
+
+func namespaceNames() map[*string]bool {
+	res := make(map[*string]bool)
+	for name := range GLOBAL_ENV.Namespaces {
+		res[name] = true
+	}
+	return res
+}
+
+// removeNewNamespaces removes the namespaces created
+// since existing names were taken with namespaceNames.
+func removeNewNamespaces(existing map[*string]bool) {
+	for name := range GLOBAL_ENV.Namespaces {
+		if !existing[name] {
+			delete(GLOBAL_ENV.Namespaces, name)
+		}
+	}
+}
+
+// applyLintRules calls the user-defined rules with a top-level form
+// that has just been parsed and reports what they return.
+func applyLintRules(form Object, filename string) {
+	if len(lintRules) == 0 || SkipLintRules {
+		return
+	}
+	pos := GetPosition(form)
+	arg := EmptyArrayMap()
+	arg.Add(KEYWORDS.form, form)
+	arg.Add(KEYWORDS.ns, GLOBAL_ENV.CurrentNamespace().Name)
+	arg.Add(KEYWORDS.file, MakeString(filename))
+	arg.Add(KEYWORDS.line, MakeInt(pos.startLine))
+	arg.Add(KEYWORDS.column, MakeInt(pos.startColumn))
+	arg.Add(KEYWORDS.endLine, MakeInt(pos.endLine))
+	arg.Add(KEYWORDS.endColumn, MakeInt(pos.endColumn))
+	arg.Add(keywordVars, resolvedVars(form))
+	for _, rule := range lintRules {
+		res, err := callLintRule(rule, arg)
+		if err != nil {
+			msg := err.Error()
+			if e, ok := err.(Error); ok {
+				msg = e.Message().ToString(false)
+			}
+			printParseError(pos, "lint-rule-error", fmt.Sprintf("Lint rule %s failed: %s", rule.name, msg))
+			continue
+		}
+		if err := reportLintRuleResult(res, pos); err != nil {
+			printParseError(pos, "lint-rule-error", fmt.Sprintf("Lint rule %s returned %s", rule.name, err.Error()))
+		}
+	}
+}
+
+func callLintRule(rule lintRule, arg Map) (res Object, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			switch r := r.(type) {
+			case *EvalError:
+				err = r
+			case *ExInfo:
+				err = r
+			case error:
+				err = r
+			default:
+				panic(r)
+			}
+		}
+	}()
+	return rule.fn.Call([]Object{arg}), nil
+}
+
+func reportLintRuleResult(res Object, pos Position) error {
+	switch res := res.(type) {
+	case Nil:
+		return nil
+	case Map:
+		return reportLintRuleDiagnostic(res, pos)
+	case Seqable:
+		for s := res.Seq(); !s.IsEmpty(); s = s.Rest() {
+			if err := reportLintRuleResult(s.First(), pos); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%s instead of a diagnostic map", res.GetType().ToString(false))
+}
+
+func reportLintRuleDiagnostic(d Map, pos Position) error {
+	ok, id := d.Get(keywordId)
+	if !ok {
+		return fmt.Errorf("a diagnostic without :id: %s", d.ToString(true))
+	}
+	rule, ok := id.(Keyword)
+	if !ok {
+		return fmt.Errorf("a diagnostic with :id that isn't a keyword: %s", d.ToString(true))
+	}
+	ok, msg := d.Get(KEYWORDS.message)
+	if !ok {
+		return fmt.Errorf("a diagnostic without :message: %s", d.ToString(true))
+	}
+	if ok, form := d.Get(KEYWORDS.form); ok {
+		if info := form.GetInfo(); info != nil {
+			pos = info.Position
+		}
+	}
+	pos = lintRulePosition(d, pos)
+	if !isLintRuleEnabled(rule) {
+		return nil
+	}
+	message := msg.ToString(false)
+	ruleName := rule.ToString(false)[1:]
+	_, severity := d.Get(keywordSeverity)
+	switch {
+	case severity == nil || severity.Equals(NIL) || severity.Equals(keywordWarning):
+		printParseWarning(pos, ruleName, message)
+	case severity.Equals(keywordError):
+		printParseError(pos, ruleName, message)
+	default:
+		return fmt.Errorf("a diagnostic with :severity other than :warning or :error: %s", d.ToString(true))
+	}
+	return nil
+}
+
+// lintRulePosition overrides pos with :line, :column,
+// :end-line and :end-column of diagnostic d.
+func lintRulePosition(d Map, pos Position) Position {
+	for _, f := range []struct {
+		k Keyword
+		v *int
+	}{
+		{KEYWORDS.line, &pos.startLine},
+		{KEYWORDS.column, &pos.startColumn},
+		{KEYWORDS.endLine, &pos.endLine},
+		{KEYWORDS.endColumn, &pos.endColumn},
+	} {
+		if ok, v := d.Get(f.k); ok {
+			if n, ok := v.(Int); ok {
+				*f.v = n.I
+			}
+		}
+	}
+	if pos.endLine < pos.startLine {
+		pos.endLine, pos.endColumn = pos.startLine, pos.startColumn
+	}
+	return pos
+}
+
+// isLintRuleEnabled returns false if the rule
+// is turned off in the :rules map of .joker file.
+func isLintRuleEnabled(rule Keyword) bool {
+	if LINTER_CONFIG == nil {
+		return true
+	}
+	config, ok := LINTER_CONFIG.Resolve().(Map)
+	if !ok {
+		return true
+	}
+	ok, rules := config.Get(KEYWORDS.rules)
+	if !ok {
+		return true
+	}
+	m, ok := rules.(Map)
+	if !ok {
+		return true
+	}
+	ok, v := m.Get(rule)
+	return !ok || ToBool(v)
+}
+
+// resolvedVars returns the metadata of the vars that
+// the symbols of form resolve to, keyed by symbol.
+func resolvedVars(form Object) Map {
+	res := EmptyArrayMap()
+	var walk func(obj Object)
+	walk = func(obj Object) {
+		switch obj := obj.(type) {
+		case Symbol:
+			if ok, _ := res.Get(obj); ok {
+				return
+			}
+			if vr, ok := GLOBAL_ENV.Resolve(obj); ok && vr.ns != nil {
+				res.Add(obj, varMeta(vr))
+			}
+		case Map:
+			for iter := obj.Iter(); iter.HasNext(); {
+				p := iter.Next()
+				walk(p.Key)
+				walk(p.Value)
+			}
+		case Seqable:
+			if _, ok := obj.(String); ok {
+				return
+			}
+			for s := obj.Seq(); !s.IsEmpty(); s = s.Rest() {
+				walk(s.First())
+			}
+		}
+	}
+	walk(form)
+	return res
+}
+
+func varMeta(vr *Var) Map {
+	var res Map = EmptyArrayMap()
+	if vr.meta != nil {
+		res = vr.meta
+	}
+	res = res.Assoc(KEYWORDS.ns, vr.ns.Name).(Map)
+	res = res.Assoc(KEYWORDS.name, vr.name).(Map)
+	for _, f := range []struct {
+		k Keyword
+		v bool
+	}{
+		{KEYWORDS.macro, vr.isMacro},
+		{KEYWORDS.private, vr.isPrivate},
+		{KEYWORDS.dynamic, vr.isDynamic},
+	} {
+		if f.v {
+			res = res.Assoc(f.k, Boolean{B: true}).(Map)
+		}
+	}
+	if info := vr.GetInfo(); info != nil {
+		if ok, _ := res.Get(KEYWORDS.file); !ok {
+			res = res.Assoc(KEYWORDS.file, MakeString(info.Filename())).(Map)
+		}
+		if ok, _ := res.Get(KEYWORDS.line); !ok {
+			res = res.Assoc(KEYWORDS.line, MakeInt(info.startLine)).(Map)
+		}
+	}
+	return res
+}
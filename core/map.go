@@ -2,7 +2,6 @@ package core
 
 import (
 	"bytes"
-	"fmt"
 	"io"
 )
 
@@ -62,6 +61,8 @@ func mapEquals(m Map, other interface{}) bool {
 	switch otherMap := other.(type) {
 	case Nil:
 		return false
+	case *Record:
+		return false
 	case Map:
 		if m.Count() != otherMap.Count() {
 			return false
@@ -111,22 +112,5 @@ func callMap(m Map, args []Object) Object {
 }
 
 func pprintMap(m Map, w io.Writer, indent int) int {
-	i := indent + 1
-	fmt.Fprint(w, "{")
-	if m.Count() > 0 {
-		for iter := m.Iter(); ; {
-			p := iter.Next()
-			i = pprintObject(p.Key, indent+1, w)
-			fmt.Fprint(w, " ")
-			i = pprintObject(p.Value, i+1, w)
-			if iter.HasNext() {
-				fmt.Fprint(w, "\n")
-				writeIndent(w, indent+1)
-			} else {
-				break
-			}
-		}
-	}
-	fmt.Fprint(w, "}")
-	return i + 1
+	return pprintLayoutObject(m, indent, w)
 }
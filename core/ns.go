@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 )
 
 type (
@@ -15,7 +16,11 @@ type (
 		aliases        map[*string]*Namespace
 		isUsed         bool
 		isGloballyUsed bool
-		hash           uint32
+		isPredeclared  bool
+		// Vars called by top level forms (by file), which may
+		// be macros defining vars the linter can't see.
+		topLevelCallees map[*string]map[*Var]bool
+		hash            uint32
 	}
 )
 
@@ -63,9 +68,11 @@ func (ns *Namespace) Hash() uint32 {
 }
 
 func (ns *Namespace) MaybeLazy(doc string) {
-	if ns.Lazy != nil {
-		lazyFn := ns.Lazy
-		ns.Lazy = nil
+	lazyNsLock.Lock()
+	lazyFn := ns.Lazy
+	ns.Lazy = nil
+	lazyNsLock.Unlock()
+	if lazyFn != nil {
 		lazyFn()
 		if VerbosityLevel > 0 {
 			fmt.Fprintf(Stderr, "NamespaceFor: Lazily initialized %s for %s\n", *ns.Name.name, doc)
@@ -75,6 +82,14 @@ func (ns *Namespace) MaybeLazy(doc string) {
 
 const nsHashMask uint32 = 0x90569f6f
 
+// nsLock guards the namespaces of GLOBAL_ENV and the mappings and
+// aliases of each namespace, since a namespace can be changed by one
+// goroutine while another one resolves symbols in it.
+var nsLock sync.RWMutex
+
+// lazyNsLock guards Lazy of every namespace.
+var lazyNsLock sync.Mutex
+
 func NewNamespace(sym Symbol) *Namespace {
 	return &Namespace{
 		Name:     sym,
@@ -88,11 +103,15 @@ func (ns *Namespace) Refer(sym Symbol, vr *Var) *Var {
 	if sym.ns != nil {
 		panic(RT.NewError("Can't intern namespace-qualified symbol " + sym.ToString(false)))
 	}
+	nsLock.Lock()
 	ns.mappings[sym.name] = vr
+	nsLock.Unlock()
 	return vr
 }
 
 func (ns *Namespace) ReferAll(other *Namespace) {
+	nsLock.Lock()
+	defer nsLock.Unlock()
 	for name, vr := range other.mappings {
 		if !vr.isPrivate {
 			ns.mappings[name] = vr
@@ -114,10 +133,12 @@ func (ns *Namespace) Intern(sym Symbol) *Var {
 		if LINTER_TYPES[sym.name] {
 			msg := fmt.Sprintf("Expecting var, but %s is a type", *sym.name)
 			pos := sym.GetInfo().Pos()
-			printParseWarning(pos, msg)
+			printParseWarning(pos, "var-is-type", msg)
 		}
 	}
 	sym.meta = nil
+	nsLock.Lock()
+	defer nsLock.Unlock()
 	existingVar, ok := ns.mappings[sym.name]
 	if !ok {
 		newVar := &Var{
@@ -135,7 +156,7 @@ func (ns *Namespace) Intern(sym Symbol) *Var {
 			}
 			ns.mappings[sym.name] = newVar
 			if !strings.HasPrefix(ns.Name.Name(), "joker.") {
-				printParseWarning(GetPosition(sym), fmt.Sprintf("WARNING: %s already refers to: %s in namespace %s, being replaced by: %s\n",
+				printParseWarning(GetPosition(sym), "redefined-core-var", fmt.Sprintf("WARNING: %s already refers to: %s in namespace %s, being replaced by: %s\n",
 					sym.ToString(false), existingVar.ToString(false), ns.Name.ToString(false), newVar.ToString(false)))
 			}
 			return newVar
@@ -143,12 +164,19 @@ func (ns *Namespace) Intern(sym Symbol) *Var {
 		panic(RT.NewErrorWithPos(fmt.Sprintf("WARNING: %s already refers to: %s in namespace %s",
 			sym.ToString(false), existingVar.ToString(false), ns.ToString(false)), sym.GetInfo().Pos()))
 	}
+	if existingVar.isPredeclared {
+		// Defined in the first pass of linting a directory,
+		// so this is the def the var was predeclared from.
+		existingVar.isPredeclared = false
+		existingVar.isUsed = false
+		return existingVar
+	}
 	if LINTER_MODE && existingVar.expr != nil && !existingVar.ns.Name.Equals(SYMBOLS.joker_core) {
 		if !isDeclaredInConfig(existingVar) {
 			if sym.GetInfo() == nil {
-				printParseWarning(existingVar.GetInfo().Pos(), "Subsequent duplicate def of "+existingVar.ToString(false))
+				printParseWarning(existingVar.GetInfo().Pos(), "duplicate-def", "Subsequent duplicate def of "+existingVar.ToString(false))
 			} else {
-				printParseWarning(sym.GetInfo().Pos(), "Duplicate def of "+existingVar.ToString(false))
+				printParseWarning(sym.GetInfo().Pos(), "duplicate-def", "Duplicate def of "+existingVar.ToString(false))
 			}
 		}
 	}
@@ -173,7 +201,7 @@ func isDeclaredInConfig(vr *Var) bool {
 
 func (ns *Namespace) InternVar(name string, val Object, meta *ArrayMap) *Var {
 	vr := ns.Intern(MakeSymbol(name))
-	vr.Value = val
+	vr.SetRoot(val)
 	meta.Add(KEYWORDS.ns, ns)
 	meta.Add(KEYWORDS.name, vr.name)
 	vr.meta = meta
@@ -184,11 +212,13 @@ func (ns *Namespace) AddAlias(alias Symbol, namespace *Namespace) {
 	if alias.ns != nil {
 		panic(RT.NewError("Alias can't be namespace-qualified"))
 	}
+	nsLock.Lock()
+	defer nsLock.Unlock()
 	existing := ns.aliases[alias.name]
 	if existing != nil && existing != namespace {
 		msg := "Alias " + alias.ToString(false) + " already exists in namespace " + ns.Name.ToString(false) + ", aliasing " + existing.Name.ToString(false)
 		if LINTER_MODE {
-			printParseError(GetPosition(alias), msg)
+			printParseError(GetPosition(alias), "duplicate-alias", msg)
 			return
 		}
 		panic(RT.NewError(msg))
@@ -196,14 +226,64 @@ func (ns *Namespace) AddAlias(alias Symbol, namespace *Namespace) {
 	ns.aliases[alias.name] = namespace
 }
 
+func (ns *Namespace) addTopLevelCallee(filename *string, vr *Var) {
+	if ns.topLevelCallees == nil {
+		ns.topLevelCallees = make(map[*string]map[*Var]bool)
+	}
+	if ns.topLevelCallees[filename] == nil {
+		ns.topLevelCallees[filename] = make(map[*Var]bool)
+	}
+	ns.topLevelCallees[filename][vr] = true
+}
+
 func (ns *Namespace) Resolve(name string) *Var {
-	return ns.mappings[STRINGS.Intern(name)]
+	vr, _ := ns.mapping(STRINGS.Intern(name))
+	return vr
+}
+
+func (ns *Namespace) mapping(name *string) (*Var, bool) {
+	nsLock.RLock()
+	defer nsLock.RUnlock()
+	vr, ok := ns.mappings[name]
+	return vr, ok
+}
+
+func (ns *Namespace) alias(name *string) *Namespace {
+	nsLock.RLock()
+	defer nsLock.RUnlock()
+	return ns.aliases[name]
+}
+
+func (ns *Namespace) unmap(name *string) {
+	nsLock.Lock()
+	defer nsLock.Unlock()
+	delete(ns.mappings, name)
 }
 
+func (ns *Namespace) unalias(name *string) {
+	nsLock.Lock()
+	defer nsLock.Unlock()
+	delete(ns.aliases, name)
+}
+
+// Mappings returns a copy of the mappings of ns.
 func (ns *Namespace) Mappings() map[*string]*Var {
-	return ns.mappings
+	nsLock.RLock()
+	defer nsLock.RUnlock()
+	res := make(map[*string]*Var, len(ns.mappings))
+	for k, v := range ns.mappings {
+		res[k] = v
+	}
+	return res
 }
 
+// Aliases returns a copy of the aliases of ns.
 func (ns *Namespace) Aliases() map[*string]*Namespace {
-	return ns.aliases
+	nsLock.RLock()
+	defer nsLock.RUnlock()
+	res := make(map[*string]*Namespace, len(ns.aliases))
+	for k, v := range ns.aliases {
+		res[k] = v
+	}
+	return res
 }
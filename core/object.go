@@ -1,5 +1,5 @@
-//go:generate go run gen/gen_types.go assert Comparable Vec Char String Symbol Keyword *Regex Boolean Time Number Seqable Callable *Type Meta Int Double Stack Map Set Associative Reversible Named Comparator *Ratio *BigFloat *BigInt *Namespace *Var Error *Fn Deref *Atom Ref KVReduce Reduce Pending *File io.Reader io.Writer StringReader io.RuneReader *Channel CountedIndexed
-//go:generate go run gen/gen_types.go info *List *ArrayMapSeq *ArrayMap *HashMap *ExInfo *Fn *Var Nil *Ratio *BigInt *BigFloat Char Double Int Boolean Time Keyword *Regex Symbol String Comment *LazySeq *MappingSeq *ArraySeq *ConsSeq *NodeSeq *ArrayNodeSeq *MapSet *Vector *ArrayVector *VectorSeq *VectorRSeq
+//go:generate go run gen/gen_types.go assert Comparable Vec Char String Symbol Keyword *Regex Boolean Time Number Seqable Callable *Type Meta Int Double Stack Map Set Associative Reversible Named Comparator *Ratio *BigFloat *BigInt *Namespace *Var Error *ExInfo *Fn Deref *Atom Ref KVReduce Reduce Pending *File io.Reader io.Writer StringReader io.RuneReader *Channel CountedIndexed Sorted *SortedMap *SortedSet *Protocol Transient TransientAssociative *TransientVector *TransientMap *TransientSet Watchable BlockingDeref *Future *Promise
+//go:generate go run gen/gen_types.go info *List *ArrayMapSeq *ArrayMap *HashMap *ExInfo *Fn *Var Nil *Ratio *BigInt *BigFloat Char Double Int Boolean Time Keyword *Regex Symbol String Comment *LazySeq *MappingSeq *ArraySeq *ConsSeq *NodeSeq *ArrayNodeSeq *MapSet *Vector *ArrayVector *VectorSeq *VectorRSeq *SortedMap *SortedMapSeq *SortedSet *Record
 //go:generate go run -tags gen_code gen_code/gen_code.go
 
 package core
@@ -19,6 +19,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 	"unicode/utf8"
 	"unsafe"
@@ -39,6 +41,8 @@ type (
 		MetaHolder
 		name        string
 		reflectType reflect.Type
+		// nil unless it's a record type.
+		record *recordType
 	}
 	Object interface {
 		Equality
@@ -151,9 +155,18 @@ type (
 	Var struct {
 		InfoHolder
 		MetaHolder
-		ns             *Namespace
-		name           Symbol
-		Value          Object
+		WatchHolder
+		ns   *Namespace
+		name Symbol
+		// The initial value. Use Resolve and SetRoot to get
+		// and set the value once the var is initialized.
+		Value Object
+		// *Object set by SetRoot, which takes precedence
+		// over Value.
+		root unsafe.Pointer
+		// Number of binding frames, on any goroutine,
+		// that bind the var.
+		bindingCount   int32
 		expr           Expr
 		isMacro        bool
 		isPrivate      bool
@@ -161,6 +174,7 @@ type (
 		isUsed         bool
 		isGloballyUsed bool
 		isFake         bool
+		isPredeclared  bool
 		taggedType     *Type
 	}
 	ProcFn func([]Object) Object
@@ -178,12 +192,15 @@ type (
 	}
 	ExInfo struct {
 		ArrayMap
-		rt *Runtime
+		callstack Callstack
 	}
 	RecurBindings []Object
 	Delay         struct {
 		fn    Callable
 		value Object
+		// Guard realization so that fn is called only once.
+		lock sync.Mutex
+		done uint32
 	}
 	Sequential interface {
 		sequential()
@@ -239,7 +256,11 @@ type (
 	}
 	Atom struct {
 		MetaHolder
-		value Object
+		WatchHolder
+		// The initial value, superseded by current (an *Object)
+		// once the atom is changed.
+		value   Object
+		current unsafe.Pointer
 	}
 	Deref interface {
 		Deref() Object
@@ -258,78 +279,95 @@ type (
 		IsRealized() bool
 	}
 	Types struct {
-		Associative    *Type
-		Callable       *Type
-		Collection     *Type
-		Comparable     *Type
-		Comparator     *Type
-		Counted        *Type
-		CountedIndexed *Type
-		Deref          *Type
-		Channel        *Type
-		Error          *Type
-		Gettable       *Type
-		Indexed        *Type
-		IOReader       *Type
-		IOWriter       *Type
-		KVReduce       *Type
-		Reduce         *Type
-		Map            *Type
-		Meta           *Type
-		Named          *Type
-		Number         *Type
-		Pending        *Type
-		Ref            *Type
-		Reversible     *Type
-		Seq            *Type
-		Seqable        *Type
-		Sequential     *Type
-		Set            *Type
-		Stack          *Type
-		ArrayMap       *Type
-		ArrayMapSeq    *Type
-		ArrayNodeSeq   *Type
-		ArraySeq       *Type
-		MapSet         *Type
-		Atom           *Type
-		BigFloat       *Type
-		BigInt         *Type
-		Boolean        *Type
-		Time           *Type
-		Buffer         *Type
-		Char           *Type
-		ConsSeq        *Type
-		Delay          *Type
-		Double         *Type
-		EvalError      *Type
-		ExInfo         *Type
-		Fn             *Type
-		File           *Type
-		BufferedReader *Type
-		HashMap        *Type
-		Int            *Type
-		Keyword        *Type
-		LazySeq        *Type
-		List           *Type
-		MappingSeq     *Type
-		Namespace      *Type
-		Nil            *Type
-		NodeSeq        *Type
-		ParseError     *Type
-		Proc           *Type
-		ProcFn         *Type
-		Ratio          *Type
-		RecurBindings  *Type
-		Regex          *Type
-		String         *Type
-		Symbol         *Type
-		Type           *Type
-		Var            *Type
-		Vector         *Type
-		Vec            *Type
-		ArrayVector    *Type
-		VectorRSeq     *Type
-		VectorSeq      *Type
+		Associative          *Type
+		Callable             *Type
+		Collection           *Type
+		Comparable           *Type
+		Comparator           *Type
+		Counted              *Type
+		CountedIndexed       *Type
+		Deref                *Type
+		BlockingDeref        *Type
+		Channel              *Type
+		Error                *Type
+		Gettable             *Type
+		Indexed              *Type
+		IOReader             *Type
+		IOWriter             *Type
+		KVReduce             *Type
+		Reduce               *Type
+		Map                  *Type
+		Meta                 *Type
+		Named                *Type
+		Number               *Type
+		Object               *Type
+		Pending              *Type
+		Ref                  *Type
+		Reversible           *Type
+		Seq                  *Type
+		Seqable              *Type
+		Sequential           *Type
+		Set                  *Type
+		Sorted               *Type
+		Stack                *Type
+		ArrayMap             *Type
+		ArrayMapSeq          *Type
+		ArrayNodeSeq         *Type
+		ArraySeq             *Type
+		MapSet               *Type
+		SortedMap            *Type
+		SortedMapSeq         *Type
+		SortedSet            *Type
+		Atom                 *Type
+		BigFloat             *Type
+		BigInt               *Type
+		Boolean              *Type
+		Time                 *Type
+		Buffer               *Type
+		Char                 *Type
+		ConsSeq              *Type
+		Delay                *Type
+		Future               *Type
+		Promise              *Type
+		Double               *Type
+		EvalError            *Type
+		ExInfo               *Type
+		Fn                   *Type
+		File                 *Type
+		BufferedReader       *Type
+		HashMap              *Type
+		Int                  *Type
+		Keyword              *Type
+		LazySeq              *Type
+		List                 *Type
+		MappingSeq           *Type
+		Namespace            *Type
+		Nil                  *Type
+		Protocol             *Type
+		Record               *Type
+		Reduced              *Type
+		Transient            *Type
+		TransientAssociative *Type
+		TransientMap         *Type
+		TransientSet         *Type
+		TransientVector      *Type
+		NodeSeq              *Type
+		ParseError           *Type
+		Proc                 *Type
+		ProcFn               *Type
+		Ratio                *Type
+		RecurBindings        *Type
+		Regex                *Type
+		String               *Type
+		Symbol               *Type
+		Type                 *Type
+		Var                  *Type
+		Vector               *Type
+		Watchable            *Type
+		Vec                  *Type
+		ArrayVector          *Type
+		VectorRSeq           *Type
+		VectorSeq            *Type
 	}
 )
 
@@ -340,6 +378,22 @@ func (pos Position) Filename() string {
 	return *pos.filename
 }
 
+func (pos Position) StartLine() int {
+	return pos.startLine
+}
+
+func (pos Position) StartColumn() int {
+	return pos.startColumn
+}
+
+func (pos Position) EndLine() int {
+	return pos.endLine
+}
+
+func (pos Position) EndColumn() int {
+	return pos.endColumn
+}
+
 func newIteratorError() error {
 	return errors.New("Iterator reached the end of collection")
 }
@@ -411,8 +465,9 @@ func MakeKeyword(nsname string) Keyword {
 }
 
 func PanicArity(n int) {
-	name := RT.currentExpr.(Traceable).Name()
-	panic(RT.NewError(fmt.Sprintf("Wrong number of args (%d) passed to %s", n, name)))
+	panic(RT.NewCallError(func(name string) string {
+		return fmt.Sprintf("Wrong number of args (%d) passed to %s", n, name)
+	}))
 }
 
 func rangeString(min, max int) string {
@@ -432,8 +487,9 @@ func rangeString(min, max int) string {
 }
 
 func PanicArityMinMax(n, min, max int) {
-	name := RT.currentExpr.(Traceable).Name()
-	panic(RT.NewError(fmt.Sprintf("Wrong number of args (%d) passed to %s; expects %s", n, name, rangeString(min, max))))
+	panic(RT.NewCallError(func(name string) string {
+		return fmt.Sprintf("Wrong number of args (%d) passed to %s; expects %s", n, name, rangeString(min, max))
+	}))
 }
 
 func CheckArity(args []Object, min int, max int) {
@@ -451,6 +507,11 @@ func getMap(k Object, args []Object) Object {
 		if ok {
 			return v
 		}
+	case *TransientMap:
+		ok, v := m.Get(k)
+		if ok {
+			return v
+		}
 	}
 	if len(args) == 2 {
 		return args[1]
@@ -505,7 +566,7 @@ func equalsNumbers(x Number, y interface{}) bool {
 }
 
 func (a *Atom) ToString(escape bool) string {
-	return "#object[Atom {:val " + a.value.ToString(escape) + "}]"
+	return "#object[Atom {:val " + a.Deref().ToString(escape) + "}]"
 }
 
 func (a *Atom) Equals(other interface{}) bool {
@@ -544,7 +605,8 @@ func (a *Atom) AlterMeta(fn *Fn, args []Object) Map {
 }
 
 func (a *Atom) Deref() Object {
-	return a.value
+	_, val := a.load()
+	return val
 }
 
 func (d *Delay) ToString(escape bool) string {
@@ -572,8 +634,13 @@ func (d *Delay) WithInfo(info *ObjectInfo) Object {
 }
 
 func (d *Delay) Force() Object {
-	if d.value == nil {
-		d.value = d.fn.Call([]Object{})
+	if atomic.LoadUint32(&d.done) == 0 {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		if d.done == 0 {
+			d.value = d.fn.Call([]Object{})
+			atomic.StoreUint32(&d.done, 1)
+		}
 	}
 	return d.value
 }
@@ -583,7 +650,7 @@ func (d *Delay) Deref() Object {
 }
 
 func (d *Delay) IsRealized() bool {
-	return d.value != nil
+	return atomic.LoadUint32(&d.done) == 1
 }
 
 func (t *Type) ToString(escape bool) string {
@@ -642,6 +709,10 @@ func (exInfo *ExInfo) Hash() uint32 {
 	return HashPtr(uintptr(unsafe.Pointer(exInfo)))
 }
 
+func (exInfo *ExInfo) Callstack() *Callstack {
+	return &exInfo.callstack
+}
+
 func (exInfo *ExInfo) Message() Object {
 	if ok, res := exInfo.Get(KEYWORDS.message); ok {
 		return res
@@ -663,8 +734,8 @@ func (exInfo *ExInfo) Error() string {
 		prefix = pr.ToString(false)
 	}
 	_, msg := exInfo.Get(KEYWORDS.message)
-	if len(exInfo.rt.callstack.frames) > 0 && !LINTER_MODE {
-		return fmt.Sprintf("%s:%d:%d: %s: %s\nStacktrace:\n%s", pos.Filename(), pos.startLine, pos.startColumn, prefix, msg.(String).S, exInfo.rt.stacktrace())
+	if len(exInfo.callstack.frames) > 0 && !LINTER_MODE {
+		return fmt.Sprintf("%s:%d:%d: %s: %s\nStacktrace:\n%s", pos.Filename(), pos.startLine, pos.startColumn, prefix, msg.(String).S, exInfo.callstack.stacktrace())
 	} else {
 		return fmt.Sprintf("%s:%d:%d: %s: %s", pos.Filename(), pos.startLine, pos.startColumn, prefix, msg.(String).S)
 	}
@@ -703,8 +774,7 @@ func (fn *Fn) Call(args []Object) Object {
 	for _, arity := range fn.fnExpr.arities {
 		a := len(arity.args)
 		if a == len(args) {
-			RT.pushFrame()
-			defer RT.popFrame()
+			defer leaveFn()
 			return evalLoop(arity.body, fn.env.addFrame(args))
 		}
 		if min > a {
@@ -739,8 +809,7 @@ func (fn *Fn) Call(args []Object) Object {
 		vargs[i] = args[i]
 	}
 	vargs[len(vargs)-1] = restArgs
-	RT.pushFrame()
-	defer RT.popFrame()
+	defer leaveFn()
 	return evalLoop(v.body, fn.env.addFrame(vargs))
 }
 
@@ -863,13 +932,30 @@ func (v *Var) Hash() uint32 {
 	return HashPtr(uintptr(unsafe.Pointer(v)))
 }
 
-func (v *Var) Resolve() Object {
-	if v.Value == nil {
-		return NIL
+// rootValue returns the root value of v, or nil if v is unbound.
+func (v *Var) rootValue() Object {
+	if root := atomic.LoadPointer(&v.root); root != nil {
+		return *(*Object)(root)
 	}
 	return v.Value
 }
 
+func (v *Var) Resolve() Object {
+	if val, ok := evaluationBinding(v); ok {
+		return val
+	}
+	if val := v.rootValue(); val != nil {
+		return val
+	}
+	return NIL
+}
+
+// SetRoot sets the root value of v. Unlike assigning to
+// Value, it's safe while other goroutines resolve v.
+func (v *Var) SetRoot(val Object) {
+	atomic.StorePointer(&v.root, unsafe.Pointer(&val))
+}
+
 func (v *Var) Call(args []Object) Object {
 	vl := v.Resolve()
 	return EnsureObjectIsCallable(
@@ -1634,6 +1720,9 @@ func (x RecurBindings) WithInfo(info *ObjectInfo) Object {
 func IsEqualOrImplements(abstractType *Type, concreteType *Type) bool {
 	if abstractType.reflectType.Kind() == reflect.Interface {
 		return concreteType.reflectType.Implements(abstractType.reflectType)
+	} else if abstractType.record != nil {
+		// All records share the same Go type.
+		return abstractType == concreteType
 	} else {
 		return concreteType.reflectType == abstractType.reflectType
 	}
@@ -1671,7 +1760,7 @@ func RegRefType(name string, inst interface{}, doc string) *Type {
 	}
 	meta := MakeMeta(nil, "(Concrete reference type)"+doc, "1.0")
 	meta.Add(KEYWORDS.name, MakeString(name))
-	t := &Type{MetaHolder{meta}, name, reflect.TypeOf(inst)}
+	t := &Type{MetaHolder{meta}, name, reflect.TypeOf(inst), nil}
 	TYPES[STRINGS.Intern(name)] = t
 	return t
 }
@@ -1682,7 +1771,7 @@ func RegType(name string, inst interface{}, doc string) *Type {
 	}
 	meta := MakeMeta(nil, "(Concrete type)"+doc, "1.0")
 	meta.Add(KEYWORDS.name, MakeString(name))
-	t := &Type{MetaHolder{meta}, name, reflect.TypeOf(inst).Elem()}
+	t := &Type{MetaHolder{meta}, name, reflect.TypeOf(inst).Elem(), nil}
 	TYPES[STRINGS.Intern(name)] = t
 	return t
 }
@@ -1693,7 +1782,7 @@ func RegInterface(name string, inst interface{}, doc string) *Type {
 	}
 	meta := MakeMeta(nil, "(Interface type)"+doc, "1.0")
 	meta.Add(KEYWORDS.name, MakeString(name))
-	t := &Type{MetaHolder{meta}, name, reflect.TypeOf(inst).Elem()}
+	t := &Type{MetaHolder{meta}, name, reflect.TypeOf(inst).Elem(), nil}
 	TYPES[STRINGS.Intern(name)] = t
 	return t
 }
@@ -1763,26 +1852,25 @@ func CountedIndexedKvreduce(v CountedIndexed, c Callable, init Object) Object {
 	res := init
 	for i := 0; i < v.Count(); i++ {
 		res = c.Call([]Object{res, Int{I: i}, v.At(i)})
+		if r, ok := unreduced(res); ok {
+			return r
+		}
 	}
 	return res
 }
 
 func CountedIndexedPprint(v CountedIndexed, w io.Writer, indent int) int {
-	ind := indent + 1
-	fmt.Fprint(w, "[")
-	if v.Count() > 0 {
-		for i := 0; i < v.Count()-1; i++ {
-			pprintObject(v.At(i), indent+1, w)
-			fmt.Fprint(w, "\n")
-			writeIndent(w, indent+1)
-		}
-		ind = pprintObject(v.At(v.Count()-1), indent+1, w)
-	}
-	fmt.Fprint(w, "]")
-	return ind + 1
+	return pprintLayoutObject(v.(Object), indent, w)
 }
 
 func CountedIndexedFormat(v CountedIndexed, w io.Writer, indent int) int {
+	objs := make([]Object, v.Count())
+	for i := range objs {
+		objs[i] = v.At(i)
+	}
+	if i, ok := formatOverflowing(v.(Object), objs, w, indent); ok {
+		return i
+	}
 	ind := indent + 1
 	fmt.Fprint(w, "[")
 	if v.Count() > 0 {
@@ -1816,10 +1904,14 @@ func CountedIndexedReduce(v CountedIndexed, c Callable) Object {
 		args[1] = v.At(1)
 		acc := c.Call(args)
 		for i := 2; i < v.Count(); i++ {
+			if res, ok := unreduced(acc); ok {
+				return res
+			}
 			args[0] = acc
 			args[1] = v.At(i)
 			acc = c.Call(args)
 		}
+		acc, _ = unreduced(acc)
 		return acc
 	}
 }
@@ -1834,10 +1926,14 @@ func CountedIndexedReduceInit(v CountedIndexed, c Callable, init Object) Object
 		args[1] = v.At(0)
 		acc := c.Call(args)
 		for i := 1; i < v.Count(); i++ {
+			if res, ok := unreduced(acc); ok {
+				return res
+			}
 			args[0] = acc
 			args[1] = v.At(i)
 			acc = c.Call(args)
 		}
+		acc, _ = unreduced(acc)
 		return acc
 	}
 }
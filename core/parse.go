@@ -125,6 +125,15 @@ type (
 		obj Object
 		msg string
 	}
+	// Diagnostic is a problem found while reading, parsing or linting.
+	// Kind is e.g. "Parse warning" or "Read error", Rule is a stable
+	// identifier of the check that produced it, e.g. "unused-binding".
+	Diagnostic struct {
+		Position
+		Kind    string
+		Rule    string
+		Message string
+	}
 	Callable interface {
 		Call(args []Object) Object
 	}
@@ -185,6 +194,7 @@ type (
 		unusedFnParameters Keyword
 		fnWithEmptyBody    Keyword
 		_prefix            Keyword
+		_rule              Keyword
 		pos                Keyword
 		startLine          Keyword
 		endLine            Keyword
@@ -211,6 +221,9 @@ type (
 		ascii              Keyword
 		unicode            Keyword
 		any                Keyword
+		jokerIgnore        Keyword
+		jokerLint          Keyword
+		ignore             Keyword
 	}
 	Symbols struct {
 		joker_core         Symbol
@@ -285,11 +298,15 @@ var (
 	REFER_VAR      *Var
 	CREATE_NS_VAR  *Var
 	IN_NS_VAR      *Var
-	WARNINGS       = Warnings{
+	WARNINGS       = defaultWarnings()
+)
+
+func defaultWarnings() Warnings {
+	return Warnings{
 		fnWithEmptyBody: true,
 		entryPoints:     EmptySet(),
 	}
-)
+}
 
 func (b *Bindings) ToMap() Map {
 	var res Map = EmptyArrayMap()
@@ -302,9 +319,13 @@ func (b *Bindings) ToMap() Map {
 	return res
 }
 
-func (localEnv *LocalEnv) addEmptyFrame(capacity int) *LocalEnv {
+// addEmptyFrame adds a frame of size bindings to be set one by one.
+// They're set in place rather than appended, since fns created
+// while they're being set may already be reading the earlier ones
+// from other goroutines.
+func (localEnv *LocalEnv) addEmptyFrame(size int) *LocalEnv {
 	res := LocalEnv{
-		bindings: make([]Object, 0, capacity),
+		bindings: make([]Object, size),
 		parent:   localEnv,
 	}
 	if localEnv != nil {
@@ -313,10 +334,6 @@ func (localEnv *LocalEnv) addEmptyFrame(capacity int) *LocalEnv {
 	return &res
 }
 
-func (localEnv *LocalEnv) addBinding(obj Object) {
-	localEnv.bindings = append(localEnv.bindings, obj)
-}
-
 func (localEnv *LocalEnv) addFrame(values []Object) *LocalEnv {
 	res := LocalEnv{
 		bindings: values,
@@ -373,7 +390,7 @@ func (b *Bindings) AddBinding(sym Symbol, index int, skipUnused bool, inferredTy
 	if LINTER_MODE && !skipUnused {
 		old := b.bindings[sym.name]
 		if old != nil && needsUnusedWarning(old) {
-			printParseWarning(GetPosition(old.name), "Unused binding: "+old.name.ToString(false))
+			printParseWarning(GetPosition(old.name), "unused-binding", "Unused binding: "+old.name.ToString(false))
 		}
 	}
 	b.bindings[sym.name] = &Binding{
@@ -421,35 +438,104 @@ func (pos Position) Pos() Position {
 	return pos
 }
 
-func printError(pos Position, msg string) {
+// DiagnosticHandler, if set, receives every problem reported while
+// reading, parsing or linting source code instead of it being printed
+// to Stderr.
+var DiagnosticHandler func(d Diagnostic)
+
+func reportDiagnostic(d Diagnostic) {
+	if DiagnosticHandler != nil {
+		DiagnosticHandler(d)
+		return
+	}
+	fmt.Fprintf(Stderr, "%s:%d:%d: %s: %s\n", d.Filename(), d.startLine, d.startColumn, d.Kind, d.Message)
+}
+
+func printError(pos Position, kind string, rule string, msg string) {
+	d := Diagnostic{Position: pos, Kind: kind, Rule: rule, Message: msg}
+	if isSuppressed(d) {
+		return
+	}
 	PROBLEM_COUNT++
-	fmt.Fprintf(Stderr, "%s:%d:%d: %s\n", pos.Filename(), pos.startLine, pos.startColumn, msg)
+	reportDiagnostic(d)
 }
 
-func printParseWarning(pos Position, msg string) {
-	printError(pos, "Parse warning: "+msg)
+func printParseWarning(pos Position, rule string, msg string) {
+	printError(pos, "Parse warning", rule, msg)
 }
 
-func printParseError(pos Position, msg string) {
-	printError(pos, "Parse error: "+msg)
+func printParseError(pos Position, rule string, msg string) {
+	printError(pos, "Parse error", rule, msg)
 }
 
-func printReadWarning(reader *Reader, msg string) {
+func printReadWarning(reader *Reader, rule string, msg string) {
 	pos := Position{
 		filename:    reader.filename,
 		startColumn: reader.column,
 		startLine:   reader.line,
 	}
-	printError(pos, "Read warning: "+msg)
+	printError(pos, "Read warning", rule, msg)
 }
 
-func printReadError(reader *Reader, msg string) {
+func printReadError(reader *Reader, rule string, msg string) {
 	pos := Position{
 		filename:    reader.filename,
 		startColumn: reader.column,
 		startLine:   reader.line,
 	}
-	printError(pos, "Read error: "+msg)
+	printError(pos, "Read error", rule, msg)
+}
+
+// IsError reports whether d is an error rather than a warning.
+func (d Diagnostic) IsError() bool {
+	return strings.HasSuffix(d.Kind, "error") || d.Kind == "Exception"
+}
+
+// ErrorDiagnostic converts an error returned by TryRead, TryParse
+// or TryEval to a Diagnostic.
+func ErrorDiagnostic(err error) Diagnostic {
+	switch err := err.(type) {
+	case ReadError:
+		return Diagnostic{
+			Position: Position{filename: err.filename, startLine: err.line, startColumn: err.column},
+			Kind:     "Read error",
+			Rule:     "syntax-error",
+			Message:  err.msg,
+		}
+	case *ParseError:
+		return Diagnostic{Position: GetPosition(err.obj), Kind: "Parse error", Rule: "parse-error", Message: err.msg}
+	case *EvalError:
+		pos := err.callstack.pos
+		if LINTER_MODE {
+			pos = err.callstack.outermostPos()
+		}
+		return Diagnostic{Position: pos, Kind: "Eval error", Rule: "eval-error", Message: err.message()}
+	case *ExInfo:
+		res := Diagnostic{Kind: "Exception", Rule: "exception", Message: err.Message().ToString(false)}
+		if _, data := err.Get(KEYWORDS.data); data != nil {
+			if m, ok := data.(Map); ok {
+				if ok, form := m.Get(KEYWORDS.form); ok {
+					res.Position = GetPosition(form)
+				}
+				if ok, pr := m.Get(KEYWORDS._prefix); ok {
+					res.Kind = pr.ToString(false)
+				}
+				if ok, rule := m.Get(KEYWORDS._rule); ok {
+					res.Rule = rule.ToString(false)
+				}
+			}
+		}
+		return res
+	}
+	return Diagnostic{Kind: "Error", Rule: "error", Message: err.Error()}
+}
+
+func printProcessError(err error) {
+	if DiagnosticHandler != nil {
+		DiagnosticHandler(ErrorDiagnostic(err))
+		return
+	}
+	fmt.Fprintln(Stderr, err)
 }
 
 func isIgnoredUnusedNamespace(ns *Namespace) bool {
@@ -472,6 +558,47 @@ func ResetUsage() {
 	}
 }
 
+// PredeclareVars is called between the two passes of linting
+// a directory. Vars defined by the first pass keep their arglists
+// and macro-ness, so that calls from other namespaces can be checked,
+// but are treated as not yet defined within their own namespace.
+func PredeclareVars() {
+	for _, ns := range GLOBAL_ENV.Namespaces {
+		if ns == GLOBAL_ENV.CoreNamespace {
+			continue
+		}
+		for name, vr := range ns.mappings {
+			if vr.isFake && vr.GetInfo() == nil {
+				// Made up for unresolved symbols; the second
+				// pass will make them up again if need be.
+				delete(ns.mappings, name)
+				continue
+			}
+			if vr.ns == ns && vr.rootValue() == nil && vr.GetInfo() != nil && !isDeclaredInConfig(vr) {
+				vr.isPredeclared = true
+				ns.isPredeclared = true
+			}
+		}
+	}
+}
+
+// isFullyKnown returns true if all vars of a namespace predeclared
+// by the first pass of linting a directory are known, i.e. none of
+// its top level forms calls a macro the linter can't expand.
+func isFullyKnown(ns *Namespace) bool {
+	if !ns.isPredeclared {
+		return false
+	}
+	for _, callees := range ns.topLevelCallees {
+		for vr := range callees {
+			if vr.isMacro || vr.expr == nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 func isEntryPointNs(ns *Namespace) bool {
 	ok, _ := WARNINGS.entryPoints.Get(ns.Name)
 	return ok
@@ -494,7 +621,7 @@ func WarnOnGloballyUnusedNamespaces() {
 
 	sort.Strings(names)
 	for _, name := range names {
-		printParseWarning(positions[name], "globally unused namespace "+name)
+		printParseWarning(positions[name], "globally-unused-namespace", "globally unused namespace "+name)
 	}
 }
 
@@ -515,7 +642,7 @@ func WarnOnUnusedNamespaces() {
 
 	sort.Strings(names)
 	for _, name := range names {
-		printParseWarning(positions[name], "unused namespace "+name)
+		printParseWarning(positions[name], "unused-namespace", "unused namespace "+name)
 	}
 }
 
@@ -542,7 +669,9 @@ func WarnOnGloballyUnusedVars() {
 		for _, vr := range ns.mappings {
 			if vr.ns == ns && !vr.isGloballyUsed && !vr.isPrivate && !isRecordConstructor(vr.name) && !isEntryPointVar(vr) {
 				pos := vr.GetInfo()
-				if pos != nil {
+				// Skip the vars of standard library namespaces
+				// loaded while linting, e.g. <joker.walk>.
+				if pos != nil && !strings.HasPrefix(pos.Filename(), "<") {
 					varName := vr.Name()
 					names = append(names, varName)
 					positions[varName] = pos.Position
@@ -553,7 +682,7 @@ func WarnOnGloballyUnusedVars() {
 
 	sort.Strings(names)
 	for _, name := range names {
-		printParseWarning(positions[name], "globally unused var "+name)
+		printParseWarning(positions[name], "globally-unused-var", "globally unused var "+name)
 	}
 }
 
@@ -578,7 +707,7 @@ func WarnOnUnusedVars() {
 
 	sort.Strings(names)
 	for _, name := range names {
-		printParseWarning(positions[name], "unused var "+name)
+		printParseWarning(positions[name], "unused-private-var", "unused var "+name)
 	}
 }
 
@@ -764,6 +893,11 @@ func parseDef(obj Object, ctx *ParseContext, isForLinter bool) *DefExpr {
 		updateVar(vr, obj.GetInfo(), res.value, sym)
 		if meta != nil {
 			res.meta = Parse(DeriveReadObject(obj, meta), ctx)
+			if LINTER_MODE && vr.meta == nil {
+				// Defs are not evaluated when linting, so keep
+				// the declared (unevaluated) meta for tooling.
+				vr.meta = meta
+			}
 		}
 		return res
 	default:
@@ -797,9 +931,9 @@ func parseBody(seq Seq, ctx *ParseContext) []Expr {
 		res = append(res, expr)
 		if LINTER_MODE {
 			if defExpr, ok := expr.(*DefExpr); ok && !defExpr.isCreatedByMacro {
-				printParseWarning(defExpr.Pos(), "inline def")
+				printParseWarning(defExpr.Pos(), "inline-def", "inline def")
 			} else if doExpr, ok := expr.(*DoExpr); ok && !doExpr.isCreatedByMacro && !skipRedundantDo(ro) {
-				printParseWarning(doExpr.Pos(), "redundant do form")
+				printParseWarning(doExpr.Pos(), "redundant-do", "redundant do form")
 			}
 		}
 	}
@@ -896,7 +1030,7 @@ func addArity(fn *FnExpr, sig Seq, ctx *ParseContext) {
 	if LINTER_MODE {
 		if WARNINGS.fnWithEmptyBody {
 			if len(arity.body) == 0 {
-				printParseWarning(arity.Position, "fn form with empty body")
+				printParseWarning(arity.Position, "fn-with-empty-body", "fn form with empty body")
 			}
 		}
 
@@ -909,7 +1043,7 @@ func addArity(fn *FnExpr, sig Seq, ctx *ParseContext) {
 			}
 			sort.Sort(BySymbolName(unused))
 			for _, u := range unused {
-				printParseWarning(GetPosition(u), "unused parameter: "+u.ToString(false))
+				printParseWarning(GetPosition(u), "unused-fn-parameter", "unused parameter: "+u.ToString(false))
 			}
 		}
 	}
@@ -1051,13 +1185,13 @@ func parseTry(obj Object, ctx *ParseContext) *TryExpr {
 	}
 	if LINTER_MODE {
 		if res.body == nil {
-			printParseWarning(res.Pos(), "try form with empty body")
+			printParseWarning(res.Pos(), "try-with-empty-body", "try form with empty body")
 		}
 		if res.catches == nil && res.finallyExpr == nil {
-			printParseWarning(res.Pos(), "try form without catch or finally")
+			printParseWarning(res.Pos(), "try-without-catch", "try form without catch or finally")
 		}
 		if res.finallyExpr != nil && len(res.finallyExpr) == 0 {
-			printParseWarning(GetPosition(obj), "finally form with empty body")
+			printParseWarning(GetPosition(obj), "finally-with-empty-body", "finally form with empty body")
 		}
 	}
 	return res
@@ -1097,7 +1231,7 @@ func parseLetLoop(obj Object, formName string, ctx *ParseContext) *LetExpr {
 		}
 		if LINTER_MODE && formName != "loop" && cnt == 0 {
 			pos := GetPosition(obj)
-			printParseWarning(pos, formName+" form with empty bindings vector")
+			printParseWarning(pos, "empty-bindings", formName+" form with empty bindings vector")
 		}
 		skipUnused := isSkipUnused(b)
 		res.names = make([]Symbol, cnt/2)
@@ -1112,7 +1246,7 @@ func parseLetLoop(obj Object, formName string, ctx *ParseContext) *LetExpr {
 				if sym.ns != nil {
 					msg := "Can't let qualified name: " + sym.ToString(false)
 					if LINTER_MODE {
-						printParseError(GetPosition(s), msg)
+						printParseError(GetPosition(s), "qualified-binding", msg)
 					} else {
 						panic(&ParseError{obj: s, msg: msg})
 					}
@@ -1155,7 +1289,7 @@ func parseLetLoop(obj Object, formName string, ctx *ParseContext) *LetExpr {
 		if LINTER_MODE {
 			if len(res.body) == 0 {
 				pos := GetPosition(obj)
-				printParseWarning(pos, formName+" form with empty body")
+				printParseWarning(pos, "form-with-empty-body", formName+" form with empty body")
 			}
 
 			if !skipUnused {
@@ -1167,7 +1301,7 @@ func parseLetLoop(obj Object, formName string, ctx *ParseContext) *LetExpr {
 				}
 				sort.Sort(BySymbolName(unused))
 				for _, u := range unused {
-					printParseWarning(GetPosition(u), "unused binding: "+u.ToString(false))
+					printParseWarning(GetPosition(u), "unused-binding", "unused binding: "+u.ToString(false))
 				}
 			}
 		}
@@ -1205,7 +1339,7 @@ func resolveMacro(obj Object, ctx *ParseContext) *Var {
 			return nil
 		}
 		vr, ok := ctx.GlobalEnv.Resolve(sym)
-		if !ok || !vr.isMacro || vr.Value == nil {
+		if !ok || !vr.isMacro || vr.rootValue() == nil {
 			return nil
 		}
 		vr.isUsed = true
@@ -1230,7 +1364,7 @@ func resolveMacro(obj Object, ctx *ParseContext) *Var {
 
 func fixInfo(obj Object, info *ObjectInfo) Object {
 	switch s := obj.(type) {
-	case Nil:
+	case Nil, *Record:
 		return obj
 	case Seq:
 		objs := make([]Object, 0, 8)
@@ -1283,7 +1417,7 @@ func macroexpand1(seq Seq, ctx *ParseContext) Object {
 	if vr != nil {
 		expr := &MacroCallExpr{
 			Position: GetPosition(seq),
-			macro:    vr.Value.(Callable),
+			macro:    vr.rootValue().(Callable),
 			args:     ToSlice(seq.Rest().Cons(ctx.localBindings.ToMap()).Cons(seq)),
 			name:     varCallableString(vr),
 		}
@@ -1294,7 +1428,7 @@ func macroexpand1(seq Seq, ctx *ParseContext) Object {
 }
 
 func reportNotAFunction(pos Position, name string) {
-	printParseWarning(pos, name+" is not a function")
+	printParseWarning(pos, "not-a-function", name+" is not a function")
 }
 
 func getTaggedType(obj Meta) *Type {
@@ -1359,7 +1493,7 @@ func checkTypes(declaredArgs []Symbol, call *CallExpr) bool {
 			passedType := call.args[i].InferType()
 			if passedType != nil {
 				if !isTypeOneOf(declaredTypes, passedType) {
-					printParseWarning(call.args[i].Pos(), fmt.Sprintf("arg[%d] of %s must have type %s, got %s", i, call.Name(), typesString(declaredTypes), passedType.ToString(false)))
+					printParseWarning(call.args[i].Pos(), "wrong-arg-type", fmt.Sprintf("arg[%d] of %s must have type %s, got %s", i, call.Name(), typesString(declaredTypes), passedType.ToString(false)))
 					res = true
 				}
 			}
@@ -1388,7 +1522,7 @@ func reportWrongArity(expr *FnExpr, isMacro bool, call *CallExpr, pos Position)
 	if v := selectArity(expr, passedArgsCount); v != nil {
 		return checkTypes(v.args, call)
 	}
-	printParseWarning(pos, fmt.Sprintf("Wrong number of args (%d) passed to %s", len(call.args), call.Name()))
+	printParseWarning(pos, "wrong-arity", fmt.Sprintf("Wrong number of args (%d) passed to %s", len(call.args), call.Name()))
 	return true
 }
 
@@ -1437,7 +1571,7 @@ func isKnownMacros(sym Symbol) (bool, Seq) {
 		}
 		KNOWN_MACROS = knownMacros
 	}
-	if ok, v := KNOWN_MACROS.Value.(Map).Get(sym); ok {
+	if ok, v := KNOWN_MACROS.Resolve().(Map).Get(sym); ok {
 		switch v := v.(type) {
 		case Seqable:
 			return true, v.Seq()
@@ -1530,11 +1664,11 @@ func checkCall(expr Expr, isMacro bool, call *CallExpr, pos Position) {
 		reportWrongArity(expr, isMacro, call, pos)
 	case *MapExpr:
 		if argsCount == 0 || argsCount > 2 {
-			printParseWarning(pos, fmt.Sprintf("Wrong number of args (%d) passed to a map", argsCount))
+			printParseWarning(pos, "wrong-arity", fmt.Sprintf("Wrong number of args (%d) passed to a map", argsCount))
 		}
 	case *SetExpr:
 		if argsCount == 0 || argsCount > 1 {
-			printParseWarning(pos, fmt.Sprintf("Wrong number of args (%d) passed to a set", argsCount))
+			printParseWarning(pos, "wrong-arity", fmt.Sprintf("Wrong number of args (%d) passed to a set", argsCount))
 		}
 	case *LiteralExpr:
 		if _, ok := expr.obj.(Callable); !ok && !expr.isSurrogate {
@@ -1544,7 +1678,7 @@ func checkCall(expr Expr, isMacro bool, call *CallExpr, pos Position) {
 		switch expr.obj.(type) {
 		case Keyword:
 			if argsCount == 0 || argsCount > 2 {
-				printParseWarning(pos, fmt.Sprintf("Wrong number of args (%d) passed to %s", argsCount, call.Name()))
+				printParseWarning(pos, "wrong-arity", fmt.Sprintf("Wrong number of args (%d) passed to %s", argsCount, call.Name()))
 			}
 		}
 	case *RecurExpr:
@@ -1555,6 +1689,11 @@ func checkCall(expr Expr, isMacro bool, call *CallExpr, pos Position) {
 }
 
 func parseList(obj Object, ctx *ParseContext) Expr {
+	if LINTER_MODE {
+		if expr := parseAnalysisForm(obj.(Seq), ctx); expr != nil {
+			return expr
+		}
+	}
 	expanded := macroexpand1(obj.(Seq), ctx)
 	if expanded != obj {
 		return Parse(expanded, ctx)
@@ -1580,7 +1719,7 @@ func parseList(obj Object, ctx *ParseContext) Expr {
 		case STR._if:
 			checkForm(obj, 3, 4)
 			if LINTER_MODE && SeqCount(seq) < 4 && WARNINGS.ifWithoutElse {
-				printParseWarning(pos, "missing else branch")
+				printParseWarning(pos, "if-without-else", "missing else branch")
 			}
 			return &IfExpr{
 				cond:     Parse(Second(seq), ctx),
@@ -1620,7 +1759,7 @@ func parseList(obj Object, ctx *ParseContext) Expr {
 					symNs := ctx.GlobalEnv.NamespaceFor(ctx.GlobalEnv.CurrentNamespace(), sym)
 					if !ctx.isUnknownCallableScope {
 						if symNs == nil || symNs == ctx.GlobalEnv.CurrentNamespace() {
-							printParseError(GetPosition(obj), "Unable to resolve symbol: "+sym.ToString(false))
+							printParseError(GetPosition(obj), "unresolved-symbol", "Unable to resolve symbol: "+sym.ToString(false))
 						}
 					}
 					vr = InternFakeSymbol(symNs, sym)
@@ -1644,9 +1783,9 @@ func parseList(obj Object, ctx *ParseContext) Expr {
 			}
 			if LINTER_MODE {
 				if len(res.body) == 0 {
-					printParseWarning(pos, "do form with empty body")
+					printParseWarning(pos, "do-with-empty-body", "do form with empty body")
 				} else if len(res.body) == 1 {
-					printParseWarning(pos, "redundant do form")
+					printParseWarning(pos, "redundant-do", "redundant do form")
 				}
 			}
 			return res
@@ -1662,6 +1801,9 @@ func parseList(obj Object, ctx *ParseContext) Expr {
 
 	ctx.isUnknownCallableScope = currentIsUnknownCallableScope
 	callable := Parse(first, ctx)
+	if c, ok := callable.(*VarRefExpr); ok && LINTER_MODE && ctx.localBindings == nil && c.vr.rootValue() == nil {
+		ctx.GlobalEnv.CurrentNamespace().addTopLevelCallee(pos.filename, c.vr)
+	}
 	unknown, syms := isUnknownCallable(callable)
 	if unknown {
 		ctx.isUnknownCallableScope = true
@@ -1688,8 +1830,8 @@ func parseList(obj Object, ctx *ParseContext) Expr {
 	if LINTER_MODE {
 		switch c := res.callable.(type) {
 		case *VarRefExpr:
-			if c.vr.Value != nil {
-				switch f := c.vr.Value.(type) {
+			if c.vr.rootValue() != nil {
+				switch f := c.vr.rootValue().(type) {
 				case *Fn:
 					if !reportWrongArity(f.fnExpr, c.vr.isMacro, res, pos) {
 						require := getRequireVar(ctx)
@@ -1697,11 +1839,11 @@ func parseList(obj Object, ctx *ParseContext) Expr {
 						alias := getAliasVar(ctx)
 						createNs := getCreateNsVar(ctx)
 						inNs := getInNsVar(ctx)
-						if (c.vr.Value.Equals(require.Value) ||
-							c.vr.Value.Equals(alias.Value) ||
-							c.vr.Value.Equals(refer.Value) ||
-							c.vr.Value.Equals(inNs.Value) ||
-							c.vr.Value.Equals(createNs.Value)) &&
+						if (c.vr.rootValue().Equals(require.rootValue()) ||
+							c.vr.rootValue().Equals(alias.rootValue()) ||
+							c.vr.rootValue().Equals(refer.rootValue()) ||
+							c.vr.rootValue().Equals(inNs.rootValue()) ||
+							c.vr.rootValue().Equals(createNs.rootValue())) &&
 							areAllLiteralExprs(res.args) {
 							Eval(res, nil)
 						}
@@ -1711,7 +1853,7 @@ func parseList(obj Object, ctx *ParseContext) Expr {
 						if ok, arglist := m.Get(KEYWORDS.arglist); ok {
 							if arglist, ok := arglist.(Seq); ok {
 								if !checkArglist(arglist, len(res.args)) {
-									printParseWarning(pos, fmt.Sprintf("Wrong number of args (%d) passed to %s", len(res.args), res.Name()))
+									printParseWarning(pos, "wrong-arity", fmt.Sprintf("Wrong number of args (%d) passed to %s", len(res.args), res.Name()))
 								}
 							}
 						}
@@ -1785,6 +1927,12 @@ func parseSymbol(obj Object, ctx *ParseContext) Expr {
 		}
 	}
 	if vr, ok := ctx.GlobalEnv.Resolve(sym); ok {
+		if LINTER_MODE && !ctx.isUnknownCallableScope {
+			if (vr.isPredeclared && vr.ns == ctx.GlobalEnv.CurrentNamespace() && ctx.linterBindings.GetBinding(sym) == nil) ||
+				(vr.isFake && vr.GetInfo() == nil && vr.ns != ctx.GlobalEnv.CurrentNamespace() && isFullyKnown(vr.ns)) {
+				printParseError(GetPosition(obj), "unresolved-symbol", "Unable to resolve symbol: "+sym.ToString(false))
+			}
+		}
 		return MakeVarRefExpr(vr, obj)
 	}
 	if sym.ns == nil && TYPES[sym.name] != nil {
@@ -1800,7 +1948,7 @@ func parseSymbol(obj Object, ctx *ParseContext) Expr {
 		// Check if this is a "callable namespace"
 		ns := ctx.GlobalEnv.FindNamespace(sym)
 		if ns == nil {
-			ns = ctx.GlobalEnv.CurrentNamespace().aliases[sym.name]
+			ns = ctx.GlobalEnv.CurrentNamespace().alias(sym.name)
 		}
 		if ns != nil {
 			ns.isUsed = true
@@ -1826,9 +1974,11 @@ func parseSymbol(obj Object, ctx *ParseContext) Expr {
 		}
 		if !ctx.isUnknownCallableScope {
 			if ctx.linterBindings.GetBinding(sym) == nil {
-				printParseError(GetPosition(obj), "Unable to resolve symbol: "+sym.ToString(false))
+				printParseError(GetPosition(obj), "unresolved-symbol", "Unable to resolve symbol: "+sym.ToString(false))
 			}
 		}
+	} else if !ctx.isUnknownCallableScope && isFullyKnown(symNs) {
+		printParseError(GetPosition(obj), "unresolved-symbol", "Unable to resolve symbol: "+sym.ToString(false))
 	}
 	return MakeVarRefExpr(InternFakeSymbol(symNs, sym), obj)
 }
@@ -1838,7 +1988,8 @@ func Parse(obj Object, ctx *ParseContext) Expr {
 	var res Expr
 	canHaveMeta := false
 	switch v := obj.(type) {
-	case Nil:
+	case Nil, *Record:
+		// Record literals are constants.
 		res = NewLiteralExpr(obj)
 	case Vec:
 		canHaveMeta = true
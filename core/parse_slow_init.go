@@ -28,6 +28,7 @@ var (
 		unusedFnParameters: MakeKeyword("unused-fn-parameters"),
 		fnWithEmptyBody:    MakeKeyword("fn-with-empty-body"),
 		_prefix:            MakeKeyword("_prefix"),
+		_rule:              MakeKeyword("_rule"),
 		pos:                MakeKeyword("pos"),
 		startLine:          MakeKeyword("start-line"),
 		endLine:            MakeKeyword("end-line"),
@@ -54,6 +55,9 @@ var (
 		ascii:              MakeKeyword("ascii"),
 		unicode:            MakeKeyword("unicode"),
 		any:                MakeKeyword("any"),
+		jokerIgnore:        MakeKeyword("joker/ignore"),
+		jokerLint:          MakeKeyword("joker/lint"),
+		ignore:             MakeKeyword("ignore"),
 	}
 	SYMBOLS = Symbols{
 		joker_core:         MakeSymbol("joker.core"),
@@ -11,8 +11,8 @@ import (
 	"math/rand"
 	"os"
 	"path/filepath"
-	"reflect"
 	"regexp"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
@@ -336,9 +336,7 @@ var procUnsignedBitShiftRight = func(args []Object) Object {
 
 var procExInfo = func(args []Object) Object {
 	CheckArity(args, 2, 3)
-	res := &ExInfo{
-		rt: RT.clone(),
-	}
+	res := &ExInfo{}
 	res.Add(KEYWORDS.message, EnsureArgIsString(args, 0))
 	res.Add(KEYWORDS.data, EnsureArgIsMap(args, 1))
 	if len(args) == 3 {
@@ -447,7 +445,7 @@ var procIntern = func(args []Object) Object {
 	sym := EnsureArgIsSymbol(args, 1)
 	vr := ns.Intern(sym)
 	if len(args) == 3 {
-		vr.Value = args[2]
+		vr.BindRoot(args[2])
 	}
 	return vr
 }
@@ -460,50 +458,87 @@ var procSetMeta = func(args []Object) Object {
 }
 
 var procAtom = func(args []Object) Object {
-	res := &Atom{
-		value: args[0],
-	}
+	res := NewAtom(args[0])
 	if len(args) > 1 {
 		m := NewHashMap(args[1:]...)
 		if ok, v := m.Get(KEYWORDS.meta); ok {
 			res.meta = EnsureObjectIsMap(v, "")
 		}
+		if ok, v := m.Get(MakeKeyword("validator")); ok && !v.Equals(NIL) {
+			res.SetValidator(res, EnsureObjectIsCallable(v, "validator: %s"))
+		}
 	}
 	return res
 }
 
 var procDeref = func(args []Object) Object {
+	if len(args) == 3 {
+		ref := EnsureArgIsBlockingDeref(args, 0)
+		timeout := time.Duration(EnsureArgIsNumber(args, 1).Int().I) * time.Millisecond
+		return ref.DerefTimeout(timeout, args[2])
+	}
 	return EnsureArgIsDeref(args, 0).Deref()
 }
 
 var procSwap = func(args []Object) Object {
 	a := EnsureArgIsAtom(args, 0)
 	f := EnsureArgIsCallable(args, 1)
-	fargs := append([]Object{a.value}, args[2:]...)
-	a.value = f.Call(fargs)
-	return a.value
+	_, newValue := a.Swap(func(old Object) Object {
+		return f.Call(append([]Object{old}, args[2:]...))
+	})
+	return newValue
 }
 
 var procSwapVals = func(args []Object) Object {
 	a := EnsureArgIsAtom(args, 0)
 	f := EnsureArgIsCallable(args, 1)
-	fargs := append([]Object{a.value}, args[2:]...)
-	oldValue := a.value
-	a.value = f.Call(fargs)
-	return NewVectorFrom(oldValue, a.value)
+	oldValue, newValue := a.Swap(func(old Object) Object {
+		return f.Call(append([]Object{old}, args[2:]...))
+	})
+	return NewVectorFrom(oldValue, newValue)
 }
 
 var procReset = func(args []Object) Object {
 	a := EnsureArgIsAtom(args, 0)
-	a.value = args[1]
-	return a.value
+	a.SetValue(args[1])
+	return args[1]
 }
 
 var procResetVals = func(args []Object) Object {
 	a := EnsureArgIsAtom(args, 0)
-	oldValue := a.value
-	a.value = args[1]
-	return NewVectorFrom(oldValue, a.value)
+	oldValue := a.SetValue(args[1])
+	return NewVectorFrom(oldValue, args[1])
+}
+
+var procCompareAndSet = func(args []Object) Object {
+	a := EnsureArgIsAtom(args, 0)
+	return Boolean{B: a.CompareAndSet(args[1], args[2])}
+}
+
+var procAddWatch = func(args []Object) Object {
+	ref := EnsureArgIsWatchable(args, 0)
+	ref.watchHolder().AddWatch(args[1], EnsureArgIsCallable(args, 2))
+	return ref
+}
+
+var procRemoveWatch = func(args []Object) Object {
+	ref := EnsureArgIsWatchable(args, 0)
+	ref.watchHolder().RemoveWatch(args[1])
+	return ref
+}
+
+var procSetValidator = func(args []Object) Object {
+	ref := EnsureArgIsWatchable(args, 0)
+	var fn Callable
+	if !args[1].Equals(NIL) {
+		fn = EnsureArgIsCallable(args, 1)
+	}
+	ref.watchHolder().SetValidator(ref, fn)
+	return NIL
+}
+
+var procGetValidator = func(args []Object) Object {
+	return EnsureArgIsWatchable(args, 0).watchHolder().Validator()
 }
 
 var procAlterMeta = func(args []Object) Object {
@@ -529,7 +564,7 @@ var procEmpty = func(args []Object) Object {
 
 var procIsBound = func(args []Object) Object {
 	vr := EnsureArgIsVar(args, 0)
-	return Boolean{B: vr.Value != nil}
+	return Boolean{B: vr.rootValue() != nil}
 }
 
 // Convert Joker object to native Go object. For those satisfying the
@@ -673,9 +708,7 @@ var procSubvec = func(args []Object) Object {
 
 var procCast = func(args []Object) Object {
 	t := EnsureArgIsType(args, 0)
-	if t.reflectType.Kind() == reflect.Interface &&
-		args[1].GetType().reflectType.Implements(t.reflectType) ||
-		args[1].GetType().reflectType == t.reflectType {
+	if IsEqualOrImplements(t, args[1].GetType()) {
 		return args[1]
 	}
 	panic(RT.NewError("Cannot cast " + args[1].GetType().ToString(false) + " to " + t.ToString(false)))
@@ -685,6 +718,44 @@ var procVec = func(args []Object) Object {
 	return NewVectorFromSeq(EnsureArgIsSeqable(args, 0).Seq())
 }
 
+var procTransient = func(args []Object) Object {
+	return NewTransient(args[0])
+}
+
+var procPersistent = func(args []Object) Object {
+	return EnsureArgIsTransient(args, 0).Persistent()
+}
+
+var procConjBang = func(args []Object) Object {
+	t := EnsureArgIsTransient(args, 0)
+	t.TransientConj(args[1])
+	return t
+}
+
+var procAssocBang = func(args []Object) Object {
+	t := EnsureArgIsTransientAssociative(args, 0)
+	t.TransientAssoc(args[1], args[2])
+	return t
+}
+
+var procDissocBang = func(args []Object) Object {
+	t := EnsureArgIsTransientMap(args, 0)
+	t.TransientDissoc(args[1])
+	return t
+}
+
+var procDisjBang = func(args []Object) Object {
+	t := EnsureArgIsTransientSet(args, 0)
+	t.TransientDisj(args[1])
+	return t
+}
+
+var procPopBang = func(args []Object) Object {
+	t := EnsureArgIsTransientVector(args, 0)
+	t.TransientPop()
+	return t
+}
+
 var procHashMap = func(args []Object) Object {
 	if len(args)%2 != 0 {
 		panic(RT.NewError("No value supplied for key " + args[len(args)-1].ToString(false)))
@@ -795,6 +866,109 @@ var procIdentical = func(args []Object) Object {
 	return Boolean{B: args[0] == args[1]}
 }
 
+var procSortedMap = func(args []Object) Object {
+	if len(args)%2 != 0 {
+		panic(RT.NewError("No value supplied for key " + args[len(args)-1].ToString(false)))
+	}
+	return NewSortedMap(nil, args...)
+}
+
+var procSortedMapBy = func(args []Object) Object {
+	cmp := EnsureArgIsComparator(args, 0)
+	if len(args)%2 != 1 {
+		panic(RT.NewError("No value supplied for key " + args[len(args)-1].ToString(false)))
+	}
+	return NewSortedMap(cmp, args[1:]...)
+}
+
+var procSortedSet = func(args []Object) Object {
+	return NewSortedSet(nil, args...)
+}
+
+var procSortedSetBy = func(args []Object) Object {
+	return NewSortedSet(EnsureArgIsComparator(args, 0), args[1:]...)
+}
+
+var procSortedSeqFrom = func(args []Object) Object {
+	return EnsureArgIsSorted(args, 0).SeqFrom(args[1], EnsureArgIsBoolean(args, 2).B)
+}
+
+var procSortedCompare = func(args []Object) Object {
+	sc := EnsureArgIsSorted(args, 0)
+	return Int{I: sc.CompareKeys(sc.EntryKey(args[1]), args[2])}
+}
+
+var procProtocol = func(args []Object) Object {
+	name := EnsureArgIsSymbol(args, 0)
+	var methods []Keyword
+	for s := EnsureArgIsSeqable(args, 1).Seq(); !s.IsEmpty(); s = s.Rest() {
+		methods = append(methods, EnsureObjectIsKeyword(s.First(), ""))
+	}
+	return NewProtocol(name.ToString(false), methods)
+}
+
+var procProtocolMethod = func(args []Object) Object {
+	return EnsureArgIsProtocol(args, 0).Method(EnsureArgIsKeyword(args, 1), args[2]).(Object)
+}
+
+// typeArg returns the type args[index], with nil standing for Nil.
+func typeArg(args []Object, index int) *Type {
+	if args[index].Equals(NIL) {
+		return TYPE.Nil
+	}
+	return EnsureArgIsType(args, index)
+}
+
+var procExtend = func(args []Object) Object {
+	t := typeArg(args, 0)
+	if len(args)%2 != 1 {
+		panic(RT.NewError("extend expects a type followed by protocol and method map pairs"))
+	}
+	for i := 1; i < len(args); i += 2 {
+		EnsureArgIsProtocol(args, i).Extend(t, EnsureArgIsMap(args, i+1))
+	}
+	return NIL
+}
+
+var procSatisfies = func(args []Object) Object {
+	return Boolean{B: EnsureArgIsProtocol(args, 0).Satisfies(args[1])}
+}
+
+var procExtends = func(args []Object) Object {
+	return Boolean{B: EnsureArgIsProtocol(args, 0).Extends(typeArg(args, 1))}
+}
+
+var procRecordType = func(args []Object) Object {
+	name := EnsureArgIsString(args, 0)
+	var fields []Keyword
+	for s := EnsureArgIsSeqable(args, 1).Seq(); !s.IsEmpty(); s = s.Rest() {
+		fields = append(fields, EnsureObjectIsKeyword(s.First(), ""))
+	}
+	return NewRecordType(name.S, fields)
+}
+
+func recordTypeArg(args []Object, index int) *Type {
+	t := EnsureArgIsType(args, index)
+	if t.record == nil {
+		panic(RT.NewError(t.ToString(false) + " is not a record type"))
+	}
+	return t
+}
+
+var procNewRecord = func(args []Object) Object {
+	t := recordTypeArg(args, 0)
+	if len(args)-1 != len(t.record.fields) {
+		panic(RT.NewError(fmt.Sprintf("Wrong number of args (%d) passed to %s constructor", len(args)-1, t.ToString(false))))
+	}
+	vals := make([]Object, len(args)-1)
+	copy(vals, args[1:])
+	return NewRecord(t, vals, nil)
+}
+
+var procMapToRecord = func(args []Object) Object {
+	return NewRecordFromMap(recordTypeArg(args, 0), EnsureArgIsMap(args, 1))
+}
+
 var procCompare = func(args []Object) Object {
 	k1, k2 := args[0], args[1]
 	if k1.Equals(k2) {
@@ -1090,14 +1264,14 @@ var procType = func(args []Object) Object {
 
 var procPprint = func(args []Object) Object {
 	obj := args[0]
-	w := EnsureObjectIsio_Writer(GLOBAL_ENV.stdout.Value, "")
+	w := EnsureObjectIsio_Writer(GLOBAL_ENV.stdout.Resolve(), "")
 	pprintObject(obj, 0, w)
 	fmt.Fprint(w, "\n")
 	return NIL
 }
 
 func PrintObject(obj Object, w io.Writer) {
-	printReadably := ToBool(GLOBAL_ENV.printReadably.Value)
+	printReadably := ToBool(GLOBAL_ENV.printReadably.Resolve())
 	switch obj := obj.(type) {
 	case Printer:
 		obj.Print(w, printReadably)
@@ -1109,7 +1283,7 @@ func PrintObject(obj Object, w io.Writer) {
 var procPr = func(args []Object) Object {
 	n := len(args)
 	if n > 0 {
-		f := EnsureObjectIsio_Writer(GLOBAL_ENV.stdout.Value, "")
+		f := EnsureObjectIsio_Writer(GLOBAL_ENV.stdout.Resolve(), "")
 		for _, arg := range args[:n-1] {
 			PrintObject(arg, f)
 			fmt.Fprint(f, " ")
@@ -1120,7 +1294,7 @@ var procPr = func(args []Object) Object {
 }
 
 var procNewline = func(args []Object) Object {
-	f := EnsureObjectIsio_Writer(GLOBAL_ENV.stdout.Value, "")
+	f := EnsureObjectIsio_Writer(GLOBAL_ENV.stdout.Resolve(), "")
 	fmt.Fprintln(f)
 	return NIL
 }
@@ -1177,7 +1351,7 @@ func readLine(r StringReader) (s string, e error) {
 
 var procReadLine = func(args []Object) Object {
 	CheckArity(args, 0, 0)
-	f := EnsureObjectIsStringReader(GLOBAL_ENV.stdin.Value, "")
+	f := EnsureObjectIsStringReader(GLOBAL_ENV.stdin.Resolve(), "")
 	line, err := readLine(f)
 	if err != nil {
 		return NIL
@@ -1284,8 +1458,9 @@ var procRemoveNamespace = func(args []Object) Object {
 }
 
 var procAllNamespaces = func(args []Object) Object {
-	s := make([]Object, 0, len(GLOBAL_ENV.Namespaces))
-	for _, ns := range GLOBAL_ENV.Namespaces {
+	namespaces := GLOBAL_ENV.AllNamespaces()
+	s := make([]Object, 0, len(namespaces))
+	for _, ns := range namespaces {
 		s = append(s, ns)
 	}
 	return &ArraySeq{arr: s}
@@ -1297,7 +1472,7 @@ var procNamespaceName = func(args []Object) Object {
 
 var procNamespaceMap = func(args []Object) Object {
 	r := &ArrayMap{}
-	for k, v := range EnsureArgIsNamespace(args, 0).mappings {
+	for k, v := range EnsureArgIsNamespace(args, 0).Mappings() {
 		r.Add(MakeSymbol(*k), v)
 	}
 	return r
@@ -1309,7 +1484,7 @@ var procNamespaceUnmap = func(args []Object) Object {
 	if sym.ns != nil {
 		panic(RT.NewError("Can't unintern namespace-qualified symbol"))
 	}
-	delete(ns.mappings, sym.name)
+	ns.unmap(sym.name)
 	return NIL
 }
 
@@ -1332,7 +1507,7 @@ var procAlias = func(args []Object) Object {
 
 var procNamespaceAliases = func(args []Object) Object {
 	r := &ArrayMap{}
-	for k, v := range EnsureArgIsNamespace(args, 0).aliases {
+	for k, v := range EnsureArgIsNamespace(args, 0).Aliases() {
 		r.Add(MakeSymbol(*k), v)
 	}
 	return r
@@ -1344,7 +1519,7 @@ var procNamespaceUnalias = func(args []Object) Object {
 	if sym.ns != nil {
 		panic(RT.NewError("Alias can't be namespace-qualified"))
 	}
-	delete(ns.aliases, sym.name)
+	ns.unalias(sym.name)
 	return NIL
 }
 
@@ -1353,10 +1528,39 @@ var procVarGet = func(args []Object) Object {
 }
 
 var procVarSet = func(args []Object) Object {
-	EnsureArgIsVar(args, 0).Value = args[1]
+	vr := EnsureArgIsVar(args, 0)
+	vr.validate(args[1])
+	vr.Set(args[1])
 	return args[1]
 }
 
+var procPushBindings = func(args []Object) Object {
+	CheckArity(args, 1, 1)
+	bindings := map[*Var]Object{}
+	for iter := EnsureArgIsMap(args, 0).Iter(); iter.HasNext(); {
+		p := iter.Next()
+		vr := EnsureObjectIsVar(p.Key, "binding: %s")
+		vr.validate(p.Value)
+		bindings[vr] = p.Value
+	}
+	pushBindings(bindings)
+	return NIL
+}
+
+var procPopBindings = func(args []Object) Object {
+	CheckArity(args, 0, 0)
+	popBindings()
+	return NIL
+}
+
+var procAlterVarRoot = func(args []Object) Object {
+	vr := EnsureArgIsVar(args, 0)
+	f := EnsureArgIsCallable(args, 1)
+	return vr.AlterRoot(func(old Object) Object {
+		return f.Call(append([]Object{old}, args[2:]...))
+	})
+}
+
 var procNsResolve = func(args []Object) Object {
 	ns := EnsureArgIsNamespace(args, 0)
 	sym := EnsureArgIsSymbol(args, 1)
@@ -1488,7 +1692,7 @@ var procLoadFile = func(args []Object) Object {
 var procLoadLibFromPath = func(args []Object) Object {
 	libname := EnsureArgIsSymbol(args, 0).Name()
 	pathname := EnsureArgIsString(args, 1).S
-	cp := GLOBAL_ENV.classPath.Value
+	cp := GLOBAL_ENV.classPath.Resolve()
 	cpvec := EnsureObjectIsVec(cp, "*classpath*: %s")
 	count := cpvec.Count()
 	var f *os.File
@@ -1538,6 +1742,10 @@ var procReduce = func(args []Object) Object {
 	return coll.reduceInit(f, init)
 }
 
+var procReduced = func(args []Object) Object {
+	return &Reduced{value: args[0]}
+}
+
 var procIndexOf = func(args []Object) Object {
 	s := EnsureArgIsString(args, 0)
 	ch := EnsureArgIsChar(args, 1)
@@ -1551,7 +1759,7 @@ var procIndexOf = func(args []Object) Object {
 
 func libExternalPath(sym Symbol) (path string, ok bool) {
 	nsSourcesVar, _ := GLOBAL_ENV.Resolve(MakeSymbol("joker.core/*ns-sources*"))
-	nsSources := ToSlice(nsSourcesVar.Value.(Vec).Seq())
+	nsSources := ToSlice(nsSourcesVar.rootValue().(Vec).Seq())
 
 	var sourceKey string
 	var sourceMap Map
@@ -1582,12 +1790,12 @@ var procLibPath = func(args []Object) Object {
 
 	if !ok {
 		var file string
-		if GLOBAL_ENV.file.Value == nil {
+		if GLOBAL_ENV.file.rootValue() == nil {
 			var err error
 			file, err = filepath.Abs("user")
 			PanicOnErr(err)
 		} else {
-			file = EnsureObjectIsString(GLOBAL_ENV.file.Value, "").S
+			file = EnsureObjectIsString(GLOBAL_ENV.file.rootValue(), "").S
 			if linkDest, err := os.Readlink(file); err == nil {
 				file = linkDest
 			}
@@ -1618,11 +1826,11 @@ var procInternFakeVar = func(args []Object) Object {
 
 var procParse = func(args []Object) Object {
 	lm, _ := GLOBAL_ENV.Resolve(MakeSymbol("joker.core/*linter-mode*"))
-	lm.Value = Boolean{B: true}
+	lm.SetRoot(Boolean{B: true})
 	LINTER_MODE = true
 	defer func() {
 		LINTER_MODE = false
-		lm.Value = Boolean{B: false}
+		lm.SetRoot(Boolean{B: false})
 	}()
 	parseContext := &ParseContext{GlobalEnv: GLOBAL_ENV}
 	res := Parse(args[0], parseContext)
@@ -1651,72 +1859,66 @@ var procCloseChan = func(args []Object) Object {
 	return NIL
 }
 
-var procSend = func(args []Object) (obj Object) {
+var procSend = func(args []Object) Object {
 	CheckArity(args, 2, 2)
 	ch := EnsureArgIsChannel(args, 0)
 	v := args[1]
 	if v.Equals(NIL) {
 		panic(RT.NewError("Can't put nil on channel"))
 	}
-	if ch.isClosed {
-		return MakeBoolean(false)
-	}
-	obj = MakeBoolean(true)
-	defer func() {
-		if r := recover(); r != nil {
-			RT.GIL.Lock()
-			obj = MakeBoolean(false)
-		}
-	}()
-	RT.GIL.Unlock()
-	ch.ch <- MakeFutureResult(v, nil)
-	RT.GIL.Lock()
-	return
+	return MakeBoolean(ch.Put(v))
 }
 
 var procReceive = func(args []Object) Object {
 	CheckArity(args, 1, 1)
-	ch := EnsureArgIsChannel(args, 0)
-	RT.GIL.Unlock()
-	res, ok := <-ch.ch
-	RT.GIL.Lock()
-	if !ok {
-		return NIL
-	}
-	if res.err != nil {
-		panic(res.err)
-	}
-	return res.value
+	v, _ := EnsureArgIsChannel(args, 0).Take()
+	return v
 }
 
 var procGo = func(args []Object) Object {
 	CheckArity(args, 1, 1)
 	f := EnsureArgIsCallable(args, 0)
 	ch := MakeChannel(make(chan FutureResult, 1))
-	go func() {
-
+	goConveying(func() {
 		defer func() {
 			if r := recover(); r != nil {
-				switch r := r.(type) {
-				case Error:
-					ch.ch <- MakeFutureResult(NIL, r)
-					ch.Close()
-				default:
-					RT.GIL.Unlock()
-					panic(r)
-				}
+				err := recoveredError(r)
+				sealError(err)
+				ch.ch <- MakeFutureResult(NIL, err)
+				ch.Close()
 			}
-			RT.GIL.Unlock()
 		}()
-
-		RT.GIL.Lock()
 		res := f.Call([]Object{})
 		ch.ch <- MakeFutureResult(res, nil)
 		ch.Close()
-	}()
+	})
 	return ch
 }
 
+var procFutureCall = func(args []Object) Object {
+	CheckArity(args, 1, 1)
+	return MakeFuture(EnsureArgIsCallable(args, 0))
+}
+
+var procPromise = func(args []Object) Object {
+	CheckArity(args, 0, 0)
+	return MakePromise()
+}
+
+var procDeliver = func(args []Object) Object {
+	CheckArity(args, 2, 2)
+	p := EnsureArgIsPromise(args, 0)
+	if p.Deliver(args[1]) {
+		return p
+	}
+	return NIL
+}
+
+var procCpuCount = func(args []Object) Object {
+	CheckArity(args, 0, 0)
+	return MakeInt(runtime.NumCPU())
+}
+
 var procVerbosityLevel = func(args []Object) Object {
 	CheckArity(args, 0, 0)
 	return MakeInt(VerbosityLevel)
@@ -1732,7 +1934,7 @@ func PackReader(reader *Reader, filename string) ([]byte, error) {
 	packEnv := NewPackEnv()
 	parseContext := &ParseContext{GlobalEnv: GLOBAL_ENV}
 	if filename != "" {
-		currentFilename := parseContext.GlobalEnv.file.Value
+		currentFilename := parseContext.GlobalEnv.file.rootValue()
 		defer func() {
 			parseContext.GlobalEnv.SetFilename(currentFilename)
 		}()
@@ -1770,6 +1972,16 @@ var procIncProblemCount = func(args []Object) Object {
 	return NIL
 }
 
+var procReportLinterProblem = func(args []Object) Object {
+	CheckArity(args, 1, 1)
+	d := ErrorDiagnostic(EnsureArgIsExInfo(args, 0))
+	if !isSuppressed(d) {
+		PROBLEM_COUNT++
+		reportDiagnostic(d)
+	}
+	return NIL
+}
+
 func ProcessReader(reader *Reader, filename string, phase Phase) error {
 	if phase == FORMAT {
 		FORMAT_MODE = true
@@ -1777,7 +1989,7 @@ func ProcessReader(reader *Reader, filename string, phase Phase) error {
 	}
 	parseContext := &ParseContext{GlobalEnv: GLOBAL_ENV}
 	if filename != "" {
-		currentFilename := parseContext.GlobalEnv.file.Value
+		currentFilename := parseContext.GlobalEnv.file.rootValue()
 		defer func() {
 			parseContext.GlobalEnv.SetFilename(currentFilename)
 		}()
@@ -1795,7 +2007,7 @@ func ProcessReader(reader *Reader, filename string, phase Phase) error {
 			return nil
 		}
 		if err != nil {
-			fmt.Fprintln(Stderr, err)
+			printProcessError(err)
 			return err
 		}
 		if phase == READ {
@@ -1804,6 +2016,9 @@ func ProcessReader(reader *Reader, filename string, phase Phase) error {
 		if phase == FORMAT {
 			if prevObj != nil {
 				cnt := newLineCount(prevObj, obj)
+				if cnt > 0 && FORMAT_STYLE.blankLines >= 0 && !isComment(prevObj) {
+					cnt = FORMAT_STYLE.blankLines + 1
+				}
 				for i := 0; i < cnt; i++ {
 					fmt.Fprint(Stdout, "\n")
 				}
@@ -1817,9 +2032,12 @@ func ProcessReader(reader *Reader, filename string, phase Phase) error {
 		}
 		expr, err := TryParse(obj, parseContext)
 		if err != nil {
-			fmt.Fprintln(Stderr, err)
+			printProcessError(err)
 		}
 		if phase == PARSE {
+			if err == nil && LINTER_MODE {
+				applyLintRules(obj, filename)
+			}
 			continue
 		}
 		if err != nil {
@@ -1827,7 +2045,7 @@ func ProcessReader(reader *Reader, filename string, phase Phase) error {
 		}
 		obj, err = TryEval(expr)
 		if err != nil {
-			fmt.Fprintln(Stderr, err)
+			printProcessError(err)
 			return err
 		}
 		if phase == EVAL {
@@ -1842,7 +2060,7 @@ func ProcessReader(reader *Reader, filename string, phase Phase) error {
 func ProcessReaderFromEval(reader *Reader, filename string) {
 	parseContext := &ParseContext{GlobalEnv: GLOBAL_ENV}
 	if filename != "" {
-		currentFilename := parseContext.GlobalEnv.file.Value
+		currentFilename := parseContext.GlobalEnv.file.rootValue()
 		defer func() {
 			parseContext.GlobalEnv.SetFilename(currentFilename)
 		}()
@@ -1884,16 +2102,16 @@ func setCoreNamespaces() {
 	ns.MaybeLazy("joker.core")
 
 	vr := ns.Resolve("*core-namespaces*")
-	set := vr.Value.(*MapSet)
+	set := vr.rootValue().(*MapSet)
 	for _, ns := range coreNamespaces {
 		set = set.Conj(MakeSymbol(ns)).(*MapSet)
 	}
-	vr.Value = set
+	vr.SetRoot(set)
 
 	// Add 'joker.core to *loaded-libs*, now that it's loaded.
 	vr = ns.Resolve("*loaded-libs*")
-	set = vr.Value.(*MapSet).Conj(ns.Name).(*MapSet)
-	vr.Value = set
+	set = vr.rootValue().(*MapSet).Conj(ns.Name).(*MapSet)
+	vr.SetRoot(set)
 }
 
 var procIsNamespaceInitialized = func(args []Object) Object {
@@ -1902,8 +2120,13 @@ var procIsNamespaceInitialized = func(args []Object) Object {
 		panic(RT.NewError("Can't ask for namespace info on namespace-qualified symbol"))
 	}
 	// First look for registered (e.g. std) libs
-	ns, found := GLOBAL_ENV.Namespaces[sym.name]
-	return MakeBoolean(found && ns.Lazy == nil)
+	ns := GLOBAL_ENV.namespace(sym.name)
+	if ns == nil {
+		return MakeBoolean(false)
+	}
+	lazyNsLock.Lock()
+	defer lazyNsLock.Unlock()
+	return MakeBoolean(ns.Lazy == nil)
 }
 
 func findConfigFile(filename string, workingDir string, findDir bool) string {
@@ -1978,27 +2201,64 @@ func knownMacrosToMap(km Object) (Map, error) {
 	return res, nil
 }
 
-func ReadConfig(filename string, workingDir string) {
-	LINTER_CONFIG = GLOBAL_ENV.CoreNamespace.Intern(MakeSymbol("*linter-config*"))
-	LINTER_CONFIG.Value = EmptyArrayMap()
+// readConfigFile reads the .joker file that applies to filename
+// (or workingDir). It returns nil if there is no such file or
+// it can't be read.
+func readConfigFile(filename string, workingDir string) (Map, string) {
 	configFileName := findConfigFile(filename, workingDir, false)
 	if configFileName == "" {
-		return
+		return nil, ""
 	}
 	f, err := os.Open(configFileName)
 	if err != nil {
 		printConfigError(configFileName, err.Error())
-		return
+		return nil, configFileName
 	}
+	defer f.Close()
+	formatMode := FORMAT_MODE
+	FORMAT_MODE = false
+	defer func() {
+		FORMAT_MODE = formatMode
+	}()
 	r := NewReader(bufio.NewReader(f), configFileName)
 	config, err := TryRead(r)
 	if err != nil {
 		printConfigError(configFileName, err.Error())
-		return
+		return nil, configFileName
 	}
 	configMap, ok := config.(Map)
 	if !ok {
 		printConfigError(configFileName, "config root object must be a map, got "+config.GetType().ToString(false))
+		return nil, configFileName
+	}
+	return configMap, configFileName
+}
+
+// ReadFormatConfig reads :format section of the .joker file
+// that applies to filename (or workingDir).
+func ReadFormatConfig(filename string, workingDir string) {
+	FORMAT_STYLE = defaultFormatStyle()
+	configMap, configFileName := readConfigFile(filename, workingDir)
+	if configMap == nil {
+		return
+	}
+	if msg := readFormatStyle(configMap); msg != "" {
+		printConfigError(configFileName, msg)
+	}
+}
+
+func ReadConfig(filename string, workingDir string) {
+	WARNINGS = defaultWarnings()
+	lintAs = nil
+	LINTER_CONFIG = GLOBAL_ENV.CoreNamespace.Intern(MakeSymbol("*linter-config*"))
+	LINTER_CONFIG.SetRoot(EmptyArrayMap())
+	FORMAT_STYLE = defaultFormatStyle()
+	configMap, configFileName := readConfigFile(filename, workingDir)
+	if configMap == nil {
+		return
+	}
+	if msg := readFormatStyle(configMap); msg != "" {
+		printConfigError(configFileName, msg)
 		return
 	}
 	ok, ignoredUnusedNamespaces := configMap.Get(MakeKeyword("ignored-unused-namespaces"))
@@ -2085,6 +2345,10 @@ func ReadConfig(filename string, workingDir string) {
 			WARNINGS.fnWithEmptyBody = ToBool(v)
 		}
 	}
+	if msg := readLintAs(configMap); msg != "" {
+		printConfigError(configFileName, msg)
+		return
+	}
 	if ok, valid := configMap.Get(KEYWORDS.validIdent); ok {
 		m, ok := valid.(Map)
 		if !ok {
@@ -2120,7 +2384,7 @@ func ReadConfig(filename string, workingDir string) {
 			}
 		}
 	}
-	LINTER_CONFIG.Value = configMap
+	LINTER_CONFIG.SetRoot(configMap)
 }
 
 func RemoveJokerNamespaces() {
@@ -2159,6 +2423,8 @@ func ProcessLinterFile(configDir string, filename string) {
 }
 
 func ProcessLinterFiles(dialect Dialect, filename string, workingDir string) {
+	lintRules = nil
+	lintHooks = nil
 	if dialect == EDN {
 		return
 	}
@@ -2166,6 +2432,8 @@ func ProcessLinterFiles(dialect Dialect, filename string, workingDir string) {
 	if configDir == "" {
 		return
 	}
+	loadLintRules(configDir)
+	loadLintHooks(configDir)
 	if dialect == JOKER {
 		ProcessLinterFile(configDir, "linter.joke")
 		return
@@ -2179,6 +2447,24 @@ func ProcessLinterFiles(dialect Dialect, filename string, workingDir string) {
 	}
 }
 
+// LinterConfigFiles returns the .joker file and the files in the .jokerd
+// directory that linting filename (or workingDir) is configured by.
+func LinterConfigFiles(filename string, workingDir string) []string {
+	var res []string
+	if configFile := findConfigFile(filename, workingDir, false); configFile != "" {
+		res = append(res, configFile)
+	}
+	if configDir := findConfigFile(filename, workingDir, true); configDir != "" {
+		filepath.Walk(configDir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				res = append(res, path)
+			}
+			return nil
+		})
+	}
+	return res
+}
+
 func init() {
 	rand.Seed(time.Now().UnixNano())
 }
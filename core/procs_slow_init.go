@@ -33,7 +33,28 @@ func init() {
 	intern("cast__", procCast, "procCast")
 	intern("vec__", procVec, "procVec")
 	intern("hash-map__", procHashMap, "procHashMap")
+	intern("transient__", procTransient, "procTransient")
+	intern("persistent!__", procPersistent, "procPersistent")
+	intern("conj!__", procConjBang, "procConjBang")
+	intern("assoc!__", procAssocBang, "procAssocBang")
+	intern("dissoc!__", procDissocBang, "procDissocBang")
+	intern("disj!__", procDisjBang, "procDisjBang")
+	intern("pop!__", procPopBang, "procPopBang")
 	intern("hash-set__", procHashSet, "procHashSet")
+	intern("sorted-map__", procSortedMap, "procSortedMap")
+	intern("sorted-map-by__", procSortedMapBy, "procSortedMapBy")
+	intern("sorted-set__", procSortedSet, "procSortedSet")
+	intern("sorted-set-by__", procSortedSetBy, "procSortedSetBy")
+	intern("sorted-seq-from__", procSortedSeqFrom, "procSortedSeqFrom")
+	intern("sorted-compare__", procSortedCompare, "procSortedCompare")
+	intern("protocol__", procProtocol, "procProtocol")
+	intern("protocol-method__", procProtocolMethod, "procProtocolMethod")
+	intern("extend__", procExtend, "procExtend")
+	intern("satisfies__", procSatisfies, "procSatisfies")
+	intern("extends__", procExtends, "procExtends")
+	intern("record-type__", procRecordType, "procRecordType")
+	intern("new-record__", procNewRecord, "procNewRecord")
+	intern("map->record__", procMapToRecord, "procMapToRecord")
 	intern("str__", procStr, "procStr")
 	intern("symbol__", procSymbol, "procSymbol")
 	intern("gensym__", procGensym, "procGensym")
@@ -133,6 +154,9 @@ func init() {
 	intern("ns-unalias__", procNamespaceUnalias, "procNamespaceUnalias")
 	intern("var-get__", procVarGet, "procVarGet")
 	intern("var-set__", procVarSet, "procVarSet")
+	intern("alter-var-root__", procAlterVarRoot, "procAlterVarRoot")
+	intern("push-bindings__", procPushBindings, "procPushBindings")
+	intern("pop-bindings__", procPopBindings, "procPopBindings")
 	intern("ns-resolve__", procNsResolve, "procNsResolve")
 	intern("array-map__", procArrayMap, "procArrayMap")
 	intern("buffer__", procBuffer, "procBuffer")
@@ -155,6 +179,11 @@ func init() {
 	intern("swap-vals__", procSwapVals, "procSwapVals")
 	intern("reset__", procReset, "procReset")
 	intern("reset-vals__", procResetVals, "procResetVals")
+	intern("compare-and-set__", procCompareAndSet, "procCompareAndSet")
+	intern("add-watch__", procAddWatch, "procAddWatch")
+	intern("remove-watch__", procRemoveWatch, "procRemoveWatch")
+	intern("set-validator__", procSetValidator, "procSetValidator")
+	intern("get-validator__", procGetValidator, "procGetValidator")
 	intern("alter-meta__", procAlterMeta, "procAlterMeta")
 	intern("reset-meta__", procResetMeta, "procResetMeta")
 	intern("empty__", procEmpty, "procEmpty")
@@ -164,6 +193,7 @@ func init() {
 	intern("load-lib-from-path__", procLoadLibFromPath, "procLoadLibFromPath")
 	intern("reduce-kv__", procReduceKv, "procReduceKv")
 	intern("reduce__", procReduce, "procReduce")
+	intern("reduced__", procReduced, "procReduced")
 	intern("slurp__", procSlurp, "procSlurp")
 	intern("spit__", procSpit, "procSpit")
 	intern("shuffle__", procShuffle, "procShuffle")
@@ -178,8 +208,13 @@ func init() {
 	intern("intern-fake-var__", procInternFakeVar, "procInternFakeVar")
 	intern("parse__", procParse, "procParse")
 	intern("inc-problem-count__", procIncProblemCount, "procIncProblemCount")
+	intern("report-linter-problem__", procReportLinterProblem, "procReportLinterProblem")
 	intern("types__", procTypes, "procTypes")
 	intern("go__", procGo, "procGo")
+	intern("future-call__", procFutureCall, "procFutureCall")
+	intern("promise__", procPromise, "procPromise")
+	intern("deliver__", procDeliver, "procDeliver")
+	intern("cpu-count__", procCpuCount, "procCpuCount")
 	intern("<!__", procReceive, "procReceive")
 	intern(">!__", procSend, "procSend")
 	intern("chan__", procCreateChan, "procCreateChan")
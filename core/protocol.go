@@ -0,0 +1,141 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+/*
+   Protocols, defined by defprotocol. A protocol maps types from
+   the Types registry (including record types) to the implementations
+   of its methods. A method dispatches on the type of its first
+   argument: an implementation for the type itself is used first,
+   then one for an interface the type implements (in the order
+   the protocol was extended to them), then one for Object.
+   nil only gets the implementation for Nil.
+*/
+
+type (
+	Protocol struct {
+		name    string
+		methods []Keyword
+		// Method name -> implementation, by type.
+		impls map[*Type]Map
+		// Types in the order the protocol was extended to them.
+		types []*Type
+		cache map[*Type]Map
+		// Guards impls, types and cache.
+		lock sync.RWMutex
+	}
+)
+
+func NewProtocol(name string, methods []Keyword) *Protocol {
+	return &Protocol{
+		name:    name,
+		methods: methods,
+		impls:   make(map[*Type]Map),
+		cache:   make(map[*Type]Map),
+	}
+}
+
+func (p *Protocol) ToString(escape bool) string {
+	return "#object[Protocol " + p.name + "]"
+}
+
+func (p *Protocol) Equals(other interface{}) bool {
+	return p == other
+}
+
+func (p *Protocol) GetInfo() *ObjectInfo {
+	return nil
+}
+
+func (p *Protocol) WithInfo(info *ObjectInfo) Object {
+	return p
+}
+
+func (p *Protocol) GetType() *Type {
+	return TYPE.Protocol
+}
+
+func (p *Protocol) Hash() uint32 {
+	return HashPtr(uintptr(unsafe.Pointer(p)))
+}
+
+func (p *Protocol) isMethod(key Object) bool {
+	for _, m := range p.methods {
+		if m.Equals(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Extend sets the implementations of the methods for type t.
+func (p *Protocol) Extend(t *Type, impl Map) {
+	for iter := impl.Iter(); iter.HasNext(); {
+		pair := iter.Next()
+		if !p.isMethod(pair.Key) {
+			panic(RT.NewError(fmt.Sprintf("%s is not a method of protocol %s", pair.Key.ToString(true), p.name)))
+		}
+		if _, ok := pair.Value.(Callable); !ok {
+			panic(RT.NewError(fmt.Sprintf("Implementation of %s for %s must be a function, got %s",
+				pair.Key.ToString(true), t.ToString(false), pair.Value.GetType().ToString(false))))
+		}
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if _, ok := p.impls[t]; !ok {
+		p.types = append(p.types, t)
+	}
+	p.impls[t] = impl
+	p.cache = make(map[*Type]Map)
+}
+
+// implFor returns the implementation of the protocol
+// for objects of type t, or nil.
+func (p *Protocol) implFor(t *Type) Map {
+	p.lock.RLock()
+	impl, ok := p.cache[t]
+	p.lock.RUnlock()
+	if ok {
+		return impl
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	impl = p.impls[t]
+	if impl == nil && t != TYPE.Nil {
+		for _, it := range p.types {
+			if it != TYPE.Object && it.reflectType.Kind() == reflect.Interface && IsEqualOrImplements(it, t) {
+				impl = p.impls[it]
+				break
+			}
+		}
+		if impl == nil {
+			impl = p.impls[TYPE.Object]
+		}
+	}
+	p.cache[t] = impl
+	return impl
+}
+
+func (p *Protocol) Satisfies(obj Object) bool {
+	return p.implFor(obj.GetType()) != nil
+}
+
+func (p *Protocol) Extends(t *Type) bool {
+	return p.implFor(t) != nil
+}
+
+// Method returns the implementation of method for obj.
+func (p *Protocol) Method(method Keyword, obj Object) Callable {
+	if impl := p.implFor(obj.GetType()); impl != nil {
+		if ok, fn := impl.Get(method); ok {
+			return fn.(Callable)
+		}
+	}
+	panic(RT.NewError(fmt.Sprintf("No implementation of method: %s of protocol: %s found for type: %s",
+		method.ToString(true), p.name, obj.GetType().ToString(false))))
+}
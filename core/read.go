@@ -9,6 +9,7 @@ import (
 	"math/rand"
 	"regexp"
 	"strconv"
+	"sync/atomic"
 	"unicode"
 	"unicode/utf8"
 )
@@ -35,24 +36,19 @@ var (
 	PROBLEM_COUNT      = 0
 	DIALECT       Dialect
 	LINTER_CONFIG *Var
-	SUPPRESS_READ bool = false
 )
 
-var (
-	ARGS   map[int]Symbol
-	GENSYM int
-)
+var GENSYM int64
 
 var NIL = Nil{}
-var posStack = make([]pos, 0, 8)
 
 func pushPos(reader *Reader) {
-	posStack = append(posStack, pos{line: reader.line, column: reader.column})
+	reader.posStack = append(reader.posStack, pos{line: reader.line, column: reader.column})
 }
 
-func popPos() pos {
-	p := posStack[len(posStack)-1]
-	posStack = posStack[:len(posStack)-1]
+func popPos(reader *Reader) pos {
+	p := reader.posStack[len(reader.posStack)-1]
+	reader.posStack = reader.posStack[:len(reader.posStack)-1]
 	return p
 }
 
@@ -112,7 +108,7 @@ func MakeReadError(reader *Reader, msg string) ReadError {
 }
 
 func MakeReadObject(reader *Reader, obj Object) Object {
-	p := popPos()
+	p := popPos(reader)
 	return obj.WithInfo(&ObjectInfo{Position: Position{
 		startColumn: p.column,
 		startLine:   p.line,
@@ -219,7 +215,12 @@ func eatWhitespace(reader *Reader) {
 		}
 		if r == '#' && reader.Peek() == '_' && !FORMAT_MODE {
 			reader.Get()
-			Read(reader)
+			obj, _ := Read(reader)
+			if LINTER_MODE {
+				if ok, rules := ignoredRules(obj, KEYWORDS.jokerIgnore); ok {
+					reader.suppression = &suppression{directive: GetPosition(obj), rules: rules}
+				}
+			}
 			r = reader.Get()
 			continue
 		}
@@ -455,7 +456,7 @@ func readIdent(reader *Reader, first rune) Object {
 			if ns == nil {
 				msg := fmt.Sprintf("Unable to resolve namespace %s in keyword %s", *sym.ns, ":"+str)
 				if LINTER_MODE {
-					printReadWarning(reader, msg)
+					printReadWarning(reader, "unresolved-namespace", msg)
 					return MakeReadObject(reader, MakeKeyword(*sym.name))
 				}
 				panic(MakeReadError(reader, msg))
@@ -558,7 +559,7 @@ func warnInvalidIdent(reader *Reader, s *string) {
 				explain = identValidationSetWhy + "; " + identValidationRangeWhy
 			}
 			msg := fmt.Sprintf("Impermissible character %q at %d in %q (%s)", r, k, *s, explain)
-			printReadWarning(reader, msg)
+			printReadWarning(reader, "invalid-identifier", msg)
 		}
 		k++
 	}
@@ -734,16 +735,16 @@ func readMulti(reader *Reader, previouslyRead []Object) (Object, []Object) {
 
 func readError(reader *Reader, msg string) {
 	if LINTER_MODE {
-		printReadError(reader, msg)
+		printReadError(reader, "syntax-error", msg)
 	} else {
 		panic(MakeReadError(reader, msg))
 	}
 }
 
 func readCondList(reader *Reader) Object {
-	previousSuppressRead := SUPPRESS_READ
+	previousSuppressRead := reader.suppressRead
 	defer func() {
-		SUPPRESS_READ = previousSuppressRead
+		reader.suppressRead = previousSuppressRead
 	}()
 
 	var forms []Object
@@ -768,14 +769,14 @@ func readCondList(reader *Reader) Object {
 			if ok, _ := GLOBAL_ENV.Features.Get(feature); ok {
 				res, forms = readMulti(reader, forms)
 			} else {
-				SUPPRESS_READ = true
+				reader.suppressRead = true
 				_, forms = readMulti(reader, forms)
-				SUPPRESS_READ = false
+				reader.suppressRead = false
 			}
 		} else {
-			SUPPRESS_READ = true
+			reader.suppressRead = true
 			_, forms = readMulti(reader, forms)
-			SUPPRESS_READ = false
+			reader.suppressRead = false
 		}
 		eatWhitespace(reader)
 		r = reader.Peek()
@@ -807,6 +808,9 @@ func readList(reader *Reader) Object {
 		list = list.conj(s[i])
 	}
 	res := MakeReadObject(reader, list)
+	if LINTER_MODE && len(s) > 1 {
+		widenDefSuppression(s[0], s[1], res)
+	}
 	return res
 }
 
@@ -1005,34 +1009,33 @@ func isTerminatingMacro(r rune) bool {
 }
 
 func genSym(prefix string, postfix string) Symbol {
-	GENSYM++
-	return MakeSymbol(fmt.Sprintf("%s%d%s", prefix, GENSYM, postfix))
+	return MakeSymbol(fmt.Sprintf("%s%d%s", prefix, atomic.AddInt64(&GENSYM, 1), postfix))
 }
 
 func generateSymbol(prefix string) Symbol {
 	return genSym(prefix, "#")
 }
 
-func registerArg(index int) Symbol {
-	if s, ok := ARGS[index]; ok {
+func registerArg(reader *Reader, index int) Symbol {
+	if s, ok := reader.args[index]; ok {
 		return s
 	}
-	ARGS[index] = generateSymbol("p__")
-	return ARGS[index]
+	reader.args[index] = generateSymbol("p__")
+	return reader.args[index]
 }
 
 func readArgSymbol(reader *Reader) Object {
 	r := reader.Peek()
 	if isWhitespace(r) || isTerminatingMacro(r) {
-		return MakeReadObject(reader, registerArg(1))
+		return MakeReadObject(reader, registerArg(reader, 1))
 	}
 	obj := readFirst(reader)
 	if obj.Equals(SYMBOLS.amp) {
-		return MakeReadObject(reader, registerArg(-1))
+		return MakeReadObject(reader, registerArg(reader, -1))
 	}
 	switch n := obj.(type) {
 	case Int:
-		return MakeReadObject(reader, registerArg(n.I))
+		return MakeReadObject(reader, registerArg(reader, n.I))
 	default:
 		panic(MakeReadError(reader, "Arg literal must be %, %& or %integer"))
 	}
@@ -1133,12 +1136,12 @@ func filename(f *string) string {
 }
 
 func handleNoReaderError(reader *Reader, s Symbol) Object {
-	if SUPPRESS_READ {
+	if reader.suppressRead {
 		return readFirst(reader)
 	}
 	if LINTER_MODE {
 		if DIALECT != EDN {
-			printReadWarning(reader, "No reader function for tag "+s.ToString(false))
+			printReadWarning(reader, "unknown-tag", "No reader function for tag "+s.ToString(false))
 		}
 		return readFirst(reader)
 	}
@@ -1153,11 +1156,18 @@ func readTagged(reader *Reader) Object {
 	}
 	switch s := obj.(type) {
 	case Symbol:
-		readersVar, ok := GLOBAL_ENV.CoreNamespace.mappings[SYMBOLS.defaultDataReaders.name]
+		if t := recordTypeForTag(s); t != nil {
+			m, ok := readFirst(reader).(Map)
+			if !ok {
+				panic(MakeReadError(reader, "Record literal must be a map"))
+			}
+			return NewRecordFromMap(t, m)
+		}
+		readersVar, ok := GLOBAL_ENV.CoreNamespace.mapping(SYMBOLS.defaultDataReaders.name)
 		if !ok {
 			return handleNoReaderError(reader, s)
 		}
-		readersMap, ok := readersVar.Value.(Map)
+		readersMap, ok := readersVar.Resolve().(Map)
 		if !ok {
 			return handleNoReaderError(reader, s)
 		}
@@ -1261,9 +1271,9 @@ func readNamespacedMap(reader *Reader) Object {
 			if !ok || sym.ns != nil {
 				panic(MakeReadError(reader, "Namespaced map must specify a valid namespace: "+sym.ToString(false)))
 			}
-			ns := GLOBAL_ENV.CurrentNamespace().aliases[sym.name]
+			ns := GLOBAL_ENV.CurrentNamespace().alias(sym.name)
 			if ns == nil {
-				ns = GLOBAL_ENV.Namespaces[sym.name]
+				ns = GLOBAL_ENV.namespace(sym.name)
 			}
 			if ns == nil {
 				panic(MakeReadError(reader, "Unknown auto-resolved namespace alias: "+sym.ToString(false)))
@@ -1310,7 +1320,7 @@ func readDispatch(reader *Reader) (Object, bool) {
 	case '"':
 		return readRegex(reader), false
 	case '\'':
-		popPos()
+		popPos(reader)
 		nextObj := readFirst(reader)
 		if FORMAT_MODE {
 			addPrefix(nextObj, "#'")
@@ -1320,12 +1330,12 @@ func readDispatch(reader *Reader) (Object, bool) {
 	case '_':
 		// Only possible in FORMAT mode, otherwise
 		// eatWhitespaces eats #_
-		popPos()
+		popPos(reader)
 		nextObj := readFirst(reader)
 		addPrefix(nextObj, "#_")
 		return nextObj, false
 	case '^':
-		popPos()
+		popPos(reader)
 		if FORMAT_MODE {
 			nextObj := readFirst(reader)
 			addPrefix(nextObj, "#^")
@@ -1335,17 +1345,17 @@ func readDispatch(reader *Reader) (Object, bool) {
 	case '{':
 		return readSet(reader), false
 	case '(':
-		popPos()
+		popPos(reader)
 		reader.Unget()
 		if FORMAT_MODE {
 			nextObj := readFirst(reader)
 			addPrefix(nextObj, "#")
 			return nextObj, false
 		}
-		ARGS = make(map[int]Symbol)
+		reader.args = make(map[int]Symbol)
 		fn := readFirst(reader)
-		res := makeFnForm(ARGS, fn)
-		ARGS = nil
+		res := makeFnForm(reader.args, fn)
+		reader.args = nil
 		return res, false
 	case '?':
 		return readConditional(reader)
@@ -1354,13 +1364,20 @@ func readDispatch(reader *Reader) (Object, bool) {
 	case '#':
 		return readSymbolicValue(reader), false
 	}
-	popPos()
+	popPos(reader)
 	reader.Unget()
 	return readTagged(reader), false
 }
 
 func readWithMeta(reader *Reader) Object {
 	meta := readMeta(reader)
+	if LINTER_MODE {
+		if ok, lint := meta.Get(KEYWORDS.jokerLint); ok {
+			if ok, rules := ignoredRules(lint, KEYWORDS.ignore); ok {
+				reader.suppression = &suppression{directive: GetPosition(lint), rules: rules}
+			}
+		}
+	}
 	nextObj := readFirst(reader)
 	switch v := nextObj.(type) {
 	case Meta:
@@ -1399,6 +1416,12 @@ func Read(reader *Reader) (Object, bool) {
 		reader.Unget()
 		return readComment(reader), false
 	}
+	if reader.suppression != nil && r != EOF {
+		s := reader.suppression
+		reader.suppression = nil
+		s.begin(reader)
+		defer s.end(reader)
+	}
 
 	switch {
 	case r == '\\':
@@ -1418,7 +1441,7 @@ func Read(reader *Reader) (Object, bool) {
 			return readNumber(reader), false
 		}
 		return readIdentFn(reader, r), false
-	case r == '%' && ARGS != nil:
+	case r == '%' && reader.args != nil:
 		if FORMAT_MODE {
 			return readIdentFn(reader, r), false
 		}
@@ -1434,7 +1457,7 @@ func Read(reader *Reader) (Object, bool) {
 	case r == '/' && isDelimiter(reader.Peek()):
 		return MakeReadObject(reader, SYMBOLS.backslash), false
 	case r == '\'':
-		popPos()
+		popPos(reader)
 		nextObj := readFirst(reader)
 		if FORMAT_MODE {
 			addPrefix(nextObj, "'")
@@ -1442,7 +1465,7 @@ func Read(reader *Reader) (Object, bool) {
 		}
 		return makeQuote(nextObj, SYMBOLS.quote), false
 	case r == '@':
-		popPos()
+		popPos(reader)
 		nextObj := readFirst(reader)
 		if FORMAT_MODE {
 			addPrefix(nextObj, "@")
@@ -1450,7 +1473,7 @@ func Read(reader *Reader) (Object, bool) {
 		}
 		return DeriveReadObject(nextObj, NewListFrom(DeriveReadObject(nextObj, SYMBOLS.deref), nextObj)), false
 	case r == '~':
-		popPos()
+		popPos(reader)
 		if reader.Peek() == '@' {
 			reader.Get()
 			nextObj := readFirst(reader)
@@ -1467,7 +1490,7 @@ func Read(reader *Reader) (Object, bool) {
 		}
 		return makeQuote(nextObj, SYMBOLS.unquote), false
 	case r == '`':
-		popPos()
+		popPos(reader)
 		nextObj := readFirst(reader)
 		if FORMAT_MODE {
 			addPrefix(nextObj, "`")
@@ -1475,7 +1498,7 @@ func Read(reader *Reader) (Object, bool) {
 		}
 		return makeSyntaxQuote(nextObj, make(map[*string]Symbol), reader), false
 	case r == '^':
-		popPos()
+		popPos(reader)
 		if FORMAT_MODE {
 			nextObj := readFirst(reader)
 			addPrefix(nextObj, "^")
@@ -14,6 +14,16 @@ type (
 		isEof          bool
 		rewind         int
 		filename       *string
+		// Set by #_:joker/ignore and ^{:joker/lint ...}
+		// until the next form is read.
+		suppression *suppression
+		// Args of the #() form being read, if any.
+		args map[int]Symbol
+		// Positions of the forms being read.
+		posStack []pos
+		// Set while reading the branches of a reader
+		// conditional that aren't selected.
+		suppressRead bool
 	}
 )
 
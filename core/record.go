@@ -0,0 +1,302 @@
+package core
+
+import (
+	"io"
+	"reflect"
+	"strings"
+)
+
+/*
+   Records, defined by defrecord. A record is a map with a fixed set
+   of keyword fields and a type of its own that protocols can be
+   extended to. Keys other than the fields are kept in a map
+   next to them.
+*/
+
+type (
+	recordType struct {
+		fields []Keyword
+	}
+	Record struct {
+		InfoHolder
+		MetaHolder
+		typ  *Type
+		vals []Object
+		// Keys that aren't fields, nil if there are none.
+		ext Map
+	}
+	RecordIterator struct {
+		r       *Record
+		current int
+		ext     MapIterator
+	}
+)
+
+// NewRecordType returns a new record type with the given
+// (qualified) name and fields.
+func NewRecordType(name string, fields []Keyword) *Type {
+	meta := MakeMeta(nil, "(Record type)", "1.0")
+	meta.Add(KEYWORDS.name, MakeString(name))
+	return &Type{MetaHolder{meta}, name, reflect.TypeOf((*Record)(nil)), &recordType{fields: fields}}
+}
+
+// recordTypeForTag returns the record type a reader tag names,
+// like user.Circle for record Circle defined in namespace user,
+// or nil if there is no such type.
+func recordTypeForTag(tag Symbol) *Type {
+	if tag.ns != nil {
+		return nil
+	}
+	i := strings.LastIndexByte(*tag.name, '.')
+	if i <= 0 {
+		return nil
+	}
+	ns := GLOBAL_ENV.FindNamespace(MakeSymbol((*tag.name)[:i]))
+	if ns == nil {
+		return nil
+	}
+	vr, ok := ns.mapping(MakeSymbol((*tag.name)[i+1:]).name)
+	if !ok {
+		return nil
+	}
+	if t, ok := vr.Resolve().(*Type); ok && t.record != nil && t.name == *tag.name {
+		return t
+	}
+	return nil
+}
+
+func NewRecord(t *Type, vals []Object, ext Map) *Record {
+	if ext != nil && ext.Count() == 0 {
+		ext = nil
+	}
+	return &Record{typ: t, vals: vals, ext: ext}
+}
+
+// NewRecordFromMap returns a record of type t with the entries of m.
+func NewRecordFromMap(t *Type, m Map) *Record {
+	fields := t.record.fields
+	vals := make([]Object, len(fields))
+	var ext Map
+	for iter := m.Iter(); iter.HasNext(); {
+		p := iter.Next()
+		if i := recordFieldIndex(fields, p.Key); i >= 0 {
+			vals[i] = p.Value
+		} else {
+			if ext == nil {
+				ext = EmptyArrayMap()
+			}
+			ext = ext.Assoc(p.Key, p.Value).(Map)
+		}
+	}
+	for i := range vals {
+		if vals[i] == nil {
+			vals[i] = NIL
+		}
+	}
+	return NewRecord(t, vals, ext)
+}
+
+func recordFieldIndex(fields []Keyword, key Object) int {
+	if k, ok := key.(Keyword); ok {
+		for i, f := range fields {
+			if f.Equals(k) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func (r *Record) fields() []Keyword {
+	return r.typ.record.fields
+}
+
+func (r *Record) with(vals []Object, ext Map) *Record {
+	res := NewRecord(r.typ, vals, ext)
+	res.meta = r.meta
+	return res
+}
+
+func (r *Record) WithMeta(meta Map) Object {
+	res := *r
+	res.meta = SafeMerge(res.meta, meta)
+	return &res
+}
+
+func (r *Record) Get(key Object) (bool, Object) {
+	if i := recordFieldIndex(r.fields(), key); i >= 0 {
+		return true, r.vals[i]
+	}
+	if r.ext != nil {
+		return r.ext.Get(key)
+	}
+	return false, nil
+}
+
+func (r *Record) EntryAt(key Object) *ArrayVector {
+	if ok, v := r.Get(key); ok {
+		return NewArrayVectorFrom(key, v)
+	}
+	return nil
+}
+
+func (r *Record) Assoc(key, val Object) Associative {
+	if i := recordFieldIndex(r.fields(), key); i >= 0 {
+		vals := make([]Object, len(r.vals))
+		copy(vals, r.vals)
+		vals[i] = val
+		return r.with(vals, r.ext)
+	}
+	ext := r.ext
+	if ext == nil {
+		ext = EmptyArrayMap()
+	}
+	return r.with(r.vals, ext.Assoc(key, val).(Map))
+}
+
+// Without returns a plain map if key is a field,
+// since the result isn't a record anymore.
+func (r *Record) Without(key Object) Map {
+	if recordFieldIndex(r.fields(), key) < 0 {
+		if r.ext == nil {
+			return r
+		}
+		return r.with(r.vals, r.ext.Without(key))
+	}
+	var res Map = EmptyArrayMap()
+	for iter := r.Iter(); iter.HasNext(); {
+		p := iter.Next()
+		if !p.Key.Equals(key) {
+			res = res.Assoc(p.Key, p.Value).(Map)
+		}
+	}
+	if r.meta != nil {
+		res = res.(Meta).WithMeta(r.meta).(Map)
+	}
+	return res
+}
+
+func (r *Record) Merge(other Map) Map {
+	var res Map = r
+	for iter := other.Iter(); iter.HasNext(); {
+		p := iter.Next()
+		res = res.Assoc(p.Key, p.Value).(Map)
+	}
+	return res
+}
+
+func (r *Record) Conj(obj Object) Conjable {
+	return mapConj(r, obj)
+}
+
+func (r *Record) Count() int {
+	if r.ext == nil {
+		return len(r.vals)
+	}
+	return len(r.vals) + r.ext.Count()
+}
+
+func (r *Record) Iter() MapIterator {
+	return &RecordIterator{r: r}
+}
+
+func (r *Record) Seq() Seq {
+	var res []Object
+	for iter := r.Iter(); iter.HasNext(); {
+		p := iter.Next()
+		res = append(res, NewVectorFrom(p.Key, p.Value))
+	}
+	if len(res) == 0 {
+		return EmptyList
+	}
+	return &ArraySeq{arr: res}
+}
+
+func (r *Record) Keys() Seq {
+	var res []Object
+	for iter := r.Iter(); iter.HasNext(); {
+		res = append(res, iter.Next().Key)
+	}
+	return &ArraySeq{arr: res}
+}
+
+func (r *Record) Vals() Seq {
+	var res []Object
+	for iter := r.Iter(); iter.HasNext(); {
+		res = append(res, iter.Next().Value)
+	}
+	return &ArraySeq{arr: res}
+}
+
+func (r *Record) ToString(escape bool) string {
+	return "#" + r.typ.name + mapToString(r, escape)
+}
+
+// Equals returns true if other is a record of the same type
+// with the same entries. Records are never equal to plain maps.
+func (r *Record) Equals(other interface{}) bool {
+	o, ok := other.(*Record)
+	if !ok || o.typ != r.typ || o.Count() != r.Count() {
+		return false
+	}
+	for iter := r.Iter(); iter.HasNext(); {
+		p := iter.Next()
+		if ok, v := o.Get(p.Key); !ok || !v.Equals(p.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Record) GetType() *Type {
+	return r.typ
+}
+
+func (r *Record) Hash() uint32 {
+	return hashUnordered(r.Seq(), 1) + r.typ.Hash()
+}
+
+func (r *Record) Empty() Collection {
+	panic(RT.NewError("Can't create empty: " + r.typ.name))
+}
+
+func (r *Record) Pprint(w io.Writer, indent int) int {
+	return pprintMap(r, w, indent)
+}
+
+func (r *Record) kvreduce(c Callable, init Object) Object {
+	res := init
+	for iter := r.Iter(); iter.HasNext(); {
+		kv := iter.Next()
+		res = c.Call([]Object{res, kv.Key, kv.Value})
+		if r, ok := unreduced(res); ok {
+			return r
+		}
+	}
+	return res
+}
+
+func (iter *RecordIterator) HasNext() bool {
+	if iter.current < len(iter.r.vals) {
+		return true
+	}
+	if iter.r.ext == nil {
+		return false
+	}
+	if iter.ext == nil {
+		iter.ext = iter.r.ext.Iter()
+	}
+	return iter.ext.HasNext()
+}
+
+func (iter *RecordIterator) Next() *Pair {
+	if iter.current < len(iter.r.vals) {
+		i := iter.current
+		iter.current++
+		return &Pair{Key: iter.r.fields()[i], Value: iter.r.vals[i]}
+	}
+	if !iter.HasNext() {
+		panic(newIteratorError())
+	}
+	return iter.ext.Next()
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"unsafe"
+)
+
+type (
+	// Reduced wraps the value of a reduction that should stop early.
+	Reduced struct {
+		value Object
+	}
+)
+
+func (r *Reduced) ToString(escape bool) string {
+	return "#object[Reduced {:val " + r.value.ToString(escape) + "}]"
+}
+
+func (r *Reduced) Equals(other interface{}) bool {
+	return r == other
+}
+
+func (r *Reduced) GetInfo() *ObjectInfo {
+	return nil
+}
+
+func (r *Reduced) WithInfo(info *ObjectInfo) Object {
+	return r
+}
+
+func (r *Reduced) GetType() *Type {
+	return TYPE.Reduced
+}
+
+func (r *Reduced) Hash() uint32 {
+	return HashPtr(uintptr(unsafe.Pointer(r)))
+}
+
+func (r *Reduced) Deref() Object {
+	return r.value
+}
+
+// unreduced returns the value wrapped by obj if it's Reduced
+// and true, or obj and false otherwise.
+func unreduced(obj Object) (Object, bool) {
+	if r, ok := obj.(*Reduced); ok {
+		return r.value, true
+	}
+	return obj, false
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"sync"
+	"sync/atomic"
 )
 
 type (
@@ -38,6 +40,9 @@ type (
 		MetaHolder
 		fn  Callable
 		seq Seq
+		// Guard realization so that fn is called only once.
+		lock sync.Mutex
+		done uint32
 	}
 	MappingSeq struct {
 		InfoHolder
@@ -131,13 +136,18 @@ func (seq *LazySeq) Seq() Seq {
 }
 
 func (seq *LazySeq) realize() {
-	if seq.seq == nil {
-		seq.seq = EnsureObjectIsSeqable(seq.fn.Call([]Object{}), "").Seq()
+	if atomic.LoadUint32(&seq.done) == 0 {
+		seq.lock.Lock()
+		defer seq.lock.Unlock()
+		if seq.done == 0 {
+			seq.seq = EnsureObjectIsSeqable(seq.fn.Call([]Object{}), "").Seq()
+			atomic.StoreUint32(&seq.done, 1)
+		}
 	}
 }
 
 func (seq *LazySeq) IsRealized() bool {
-	return seq.seq != nil
+	return atomic.LoadUint32(&seq.done) == 1
 }
 
 func (seq *LazySeq) Equals(other interface{}) bool {
@@ -157,9 +167,10 @@ func (seq *LazySeq) Format(w io.Writer, indent int) int {
 }
 
 func (seq *LazySeq) WithMeta(meta Map) Object {
-	res := *seq
-	res.meta = SafeMerge(res.meta, meta)
-	return &res
+	seq.realize()
+	res := &LazySeq{InfoHolder: seq.InfoHolder, seq: seq.seq, done: 1}
+	res.meta = SafeMerge(seq.meta, meta)
+	return res
 }
 
 func (seq *LazySeq) GetType() *Type {
@@ -423,15 +434,5 @@ func hashOrdered(seq Seq) uint32 {
 }
 
 func pprintSeq(seq Seq, w io.Writer, indent int) int {
-	i := indent + 1
-	fmt.Fprint(w, "(")
-	for iter := iter(seq); iter.HasNext(); {
-		i = pprintObject(iter.Next(), indent+1, w)
-		if iter.HasNext() {
-			fmt.Fprint(w, "\n")
-			writeIndent(w, indent+1)
-		}
-	}
-	fmt.Fprint(w, ")")
-	return i + 1
+	return pprintLayoutObject(seq, indent, w)
 }
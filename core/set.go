@@ -66,12 +66,31 @@ func (set *MapSet) ToString(escape bool) string {
 }
 
 func (set *MapSet) Equals(other interface{}) bool {
-	switch otherSet := other.(type) {
-	case *MapSet:
-		return set.m.Equals(otherSet.m)
-	default:
+	return setEquals(set, other)
+}
+
+// setEquals returns true if other is a set of the same elements.
+func setEquals(set interface {
+	Set
+	Counted
+	Seqable
+}, other interface{}) bool {
+	if set == other {
+		return true
+	}
+	otherSet, ok := other.(Set)
+	if _, isNil := other.(Nil); !ok || isNil {
 		return false
 	}
+	if c, ok := otherSet.(Counted); !ok || c.Count() != set.Count() {
+		return false
+	}
+	for s := set.Seq(); !s.IsEmpty(); s = s.Rest() {
+		if ok, _ := otherSet.Get(s.First()); !ok {
+			return false
+		}
+	}
+	return true
 }
 
 func (set *MapSet) Get(key Object) (bool, Object) {
@@ -119,20 +138,13 @@ func NewSetFromSeq(s Seq) *MapSet {
 }
 
 func (set *MapSet) Pprint(w io.Writer, indent int) int {
-	i := indent + 1
-	fmt.Fprint(w, "#{")
-	for iter := iter(set.m.Keys()); iter.HasNext(); {
-		i = pprintObject(iter.Next(), indent+2, w)
-		if iter.HasNext() {
-			fmt.Fprint(w, "\n")
-			writeIndent(w, indent+2)
-		}
-	}
-	fmt.Fprint(w, "}")
-	return i + 1
+	return pprintLayoutObject(set, indent, w)
 }
 
 func (set *MapSet) Format(w io.Writer, indent int) int {
+	if i, ok := formatOverflowing(set, ToSlice(set.m.Keys()), w, indent); ok {
+		return i
+	}
 	i := indent + 2
 	fmt.Fprint(w, "#{")
 	var prevObj Object
@@ -0,0 +1,436 @@
+package core
+
+import (
+	"io"
+)
+
+/*
+   Persistent sorted map: an AVL tree ordered by a comparator
+   (compare by default). Updates copy the path from the root
+   to the changed node and share the rest of the tree.
+*/
+
+type (
+	Sorted interface {
+		Reversible
+		// SeqFrom returns the seq of entries starting from the first
+		// one whose key is >= key (<= key if not ascending).
+		SeqFrom(key Object, ascending bool) Seq
+		EntryKey(entry Object) Object
+		CompareKeys(a, b Object) int
+	}
+	sortedNode struct {
+		key    Object
+		val    Object
+		left   *sortedNode
+		right  *sortedNode
+		height int
+	}
+	SortedMap struct {
+		InfoHolder
+		MetaHolder
+		root  *sortedNode
+		count int
+		// nil for compare.
+		cmp Comparator
+	}
+	// Nodes yet to be visited, the next one first.
+	sortedStack struct {
+		node *sortedNode
+		next *sortedStack
+	}
+	SortedMapSeq struct {
+		InfoHolder
+		MetaHolder
+		stack     *sortedStack
+		ascending bool
+		// Set if the seq is of keys rather than entries.
+		keys bool
+	}
+	SortedMapIterator struct {
+		seq Seq
+	}
+)
+
+func nodeHeight(n *sortedNode) int {
+	if n == nil {
+		return 0
+	}
+	return n.height
+}
+
+func newSortedNode(key, val Object, left, right *sortedNode) *sortedNode {
+	h := nodeHeight(left)
+	if r := nodeHeight(right); r > h {
+		h = r
+	}
+	return &sortedNode{key: key, val: val, left: left, right: right, height: h + 1}
+}
+
+// balanceNode returns the node with key and val and subtrees
+// left and right, rotated if their heights differ by more than one.
+func balanceNode(key, val Object, left, right *sortedNode) *sortedNode {
+	lh, rh := nodeHeight(left), nodeHeight(right)
+	switch {
+	case lh > rh+1:
+		if nodeHeight(left.left) >= nodeHeight(left.right) {
+			return newSortedNode(left.key, left.val, left.left, newSortedNode(key, val, left.right, right))
+		}
+		lr := left.right
+		return newSortedNode(lr.key, lr.val,
+			newSortedNode(left.key, left.val, left.left, lr.left),
+			newSortedNode(key, val, lr.right, right))
+	case rh > lh+1:
+		if nodeHeight(right.right) >= nodeHeight(right.left) {
+			return newSortedNode(right.key, right.val, newSortedNode(key, val, left, right.left), right.right)
+		}
+		rl := right.left
+		return newSortedNode(rl.key, rl.val,
+			newSortedNode(key, val, left, rl.left),
+			newSortedNode(right.key, right.val, rl.right, right.right))
+	}
+	return newSortedNode(key, val, left, right)
+}
+
+func EmptySortedMap(cmp Comparator) *SortedMap {
+	return &SortedMap{cmp: cmp}
+}
+
+func NewSortedMap(cmp Comparator, keyvals ...Object) *SortedMap {
+	res := EmptySortedMap(cmp)
+	for i := 0; i < len(keyvals); i += 2 {
+		res = res.Assoc(keyvals[i], keyvals[i+1]).(*SortedMap)
+	}
+	return res
+}
+
+func (m *SortedMap) CompareKeys(a, b Object) int {
+	if m.cmp == nil {
+		return procCompare([]Object{a, b}).(Int).I
+	}
+	return m.cmp.Compare(a, b)
+}
+
+func (m *SortedMap) EntryKey(entry Object) Object {
+	return EnsureObjectIsVec(entry, "").At(0)
+}
+
+func (m *SortedMap) find(key Object) *sortedNode {
+	for n := m.root; n != nil; {
+		c := m.CompareKeys(key, n.key)
+		switch {
+		case c < 0:
+			n = n.left
+		case c > 0:
+			n = n.right
+		default:
+			return n
+		}
+	}
+	return nil
+}
+
+// insert returns n with key mapped to val and true if key is new.
+func (m *SortedMap) insert(n *sortedNode, key, val Object) (*sortedNode, bool) {
+	if n == nil {
+		return newSortedNode(key, val, nil, nil), true
+	}
+	c := m.CompareKeys(key, n.key)
+	switch {
+	case c < 0:
+		left, added := m.insert(n.left, key, val)
+		return balanceNode(n.key, n.val, left, n.right), added
+	case c > 0:
+		right, added := m.insert(n.right, key, val)
+		return balanceNode(n.key, n.val, n.left, right), added
+	}
+	return newSortedNode(n.key, val, n.left, n.right), false
+}
+
+// removeMin returns n without its leftmost node, and that node.
+func removeMin(n *sortedNode) (*sortedNode, *sortedNode) {
+	if n.left == nil {
+		return n.right, n
+	}
+	left, min := removeMin(n.left)
+	return balanceNode(n.key, n.val, left, n.right), min
+}
+
+// remove returns n without key and true if key was there.
+func (m *SortedMap) remove(n *sortedNode, key Object) (*sortedNode, bool) {
+	if n == nil {
+		return nil, false
+	}
+	c := m.CompareKeys(key, n.key)
+	switch {
+	case c < 0:
+		left, removed := m.remove(n.left, key)
+		if !removed {
+			return n, false
+		}
+		return balanceNode(n.key, n.val, left, n.right), true
+	case c > 0:
+		right, removed := m.remove(n.right, key)
+		if !removed {
+			return n, false
+		}
+		return balanceNode(n.key, n.val, n.left, right), true
+	}
+	if n.left == nil {
+		return n.right, true
+	}
+	if n.right == nil {
+		return n.left, true
+	}
+	right, min := removeMin(n.right)
+	return balanceNode(min.key, min.val, n.left, right), true
+}
+
+func (m *SortedMap) WithMeta(meta Map) Object {
+	res := *m
+	res.meta = SafeMerge(res.meta, meta)
+	return &res
+}
+
+func (m *SortedMap) Get(key Object) (bool, Object) {
+	if n := m.find(key); n != nil {
+		return true, n.val
+	}
+	return false, nil
+}
+
+func (m *SortedMap) EntryAt(key Object) *ArrayVector {
+	if n := m.find(key); n != nil {
+		return NewArrayVectorFrom(n.key, n.val)
+	}
+	return nil
+}
+
+func (m *SortedMap) Assoc(key, val Object) Associative {
+	root, added := m.insert(m.root, key, val)
+	res := &SortedMap{root: root, count: m.count, cmp: m.cmp}
+	res.meta = m.meta
+	if added {
+		res.count++
+	}
+	return res
+}
+
+func (m *SortedMap) Without(key Object) Map {
+	root, removed := m.remove(m.root, key)
+	if !removed {
+		return m
+	}
+	res := &SortedMap{root: root, count: m.count - 1, cmp: m.cmp}
+	res.meta = m.meta
+	return res
+}
+
+func (m *SortedMap) Merge(other Map) Map {
+	res := m
+	for iter := other.Iter(); iter.HasNext(); {
+		p := iter.Next()
+		res = res.Assoc(p.Key, p.Value).(*SortedMap)
+	}
+	return res
+}
+
+func (m *SortedMap) Conj(obj Object) Conjable {
+	return mapConj(m, obj)
+}
+
+func (m *SortedMap) Count() int {
+	return m.count
+}
+
+// pushLeft pushes n and its left (right if not ascending) descendants.
+func pushLeft(stack *sortedStack, n *sortedNode, ascending bool) *sortedStack {
+	for n != nil {
+		stack = &sortedStack{node: n, next: stack}
+		if ascending {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	return stack
+}
+
+func (m *SortedMap) seq(ascending bool, keys bool) Seq {
+	if m.count == 0 {
+		return EmptyList
+	}
+	return &SortedMapSeq{stack: pushLeft(nil, m.root, ascending), ascending: ascending, keys: keys}
+}
+
+func (m *SortedMap) seqFrom(key Object, ascending bool, keys bool) Seq {
+	var stack *sortedStack
+	for n := m.root; n != nil; {
+		c := m.CompareKeys(key, n.key)
+		switch {
+		case c == 0:
+			stack = &sortedStack{node: n, next: stack}
+			n = nil
+		case (c < 0) == ascending:
+			stack = &sortedStack{node: n, next: stack}
+			if ascending {
+				n = n.left
+			} else {
+				n = n.right
+			}
+		case ascending:
+			n = n.right
+		default:
+			n = n.left
+		}
+	}
+	if stack == nil {
+		return EmptyList
+	}
+	return &SortedMapSeq{stack: stack, ascending: ascending, keys: keys}
+}
+
+func (m *SortedMap) Seq() Seq {
+	return m.seq(true, false)
+}
+
+func (m *SortedMap) Rseq() Seq {
+	return m.seq(false, false)
+}
+
+func (m *SortedMap) SeqFrom(key Object, ascending bool) Seq {
+	return m.seqFrom(key, ascending, false)
+}
+
+func (m *SortedMap) Keys() Seq {
+	return m.seq(true, true)
+}
+
+func (m *SortedMap) Vals() Seq {
+	res := make([]Object, 0, m.count)
+	for iter := m.Iter(); iter.HasNext(); {
+		res = append(res, iter.Next().Value)
+	}
+	return &ArraySeq{arr: res}
+}
+
+func (m *SortedMap) Iter() MapIterator {
+	return &SortedMapIterator{seq: m.Seq()}
+}
+
+func (m *SortedMap) ToString(escape bool) string {
+	return mapToString(m, escape)
+}
+
+func (m *SortedMap) Equals(other interface{}) bool {
+	return mapEquals(m, other)
+}
+
+func (m *SortedMap) GetType() *Type {
+	return TYPE.SortedMap
+}
+
+func (m *SortedMap) Hash() uint32 {
+	return hashUnordered(m.Seq(), 1)
+}
+
+func (m *SortedMap) Call(args []Object) Object {
+	return callMap(m, args)
+}
+
+func (m *SortedMap) Empty() Collection {
+	res := EmptySortedMap(m.cmp)
+	res.meta = m.meta
+	return res
+}
+
+func (m *SortedMap) Pprint(w io.Writer, indent int) int {
+	return pprintMap(m, w, indent)
+}
+
+func (m *SortedMap) kvreduce(c Callable, init Object) Object {
+	res := init
+	for iter := m.Iter(); iter.HasNext(); {
+		kv := iter.Next()
+		res = c.Call([]Object{res, kv.Key, kv.Value})
+		if r, ok := unreduced(res); ok {
+			return r
+		}
+	}
+	return res
+}
+
+func (iter *SortedMapIterator) HasNext() bool {
+	return !iter.seq.IsEmpty()
+}
+
+func (iter *SortedMapIterator) Next() *Pair {
+	if iter.seq.IsEmpty() {
+		panic(newIteratorError())
+	}
+	s := iter.seq.(*SortedMapSeq)
+	n := s.stack.node
+	iter.seq = s.Rest()
+	return &Pair{Key: n.key, Value: n.val}
+}
+
+func (seq *SortedMapSeq) sequential() {}
+
+func (seq *SortedMapSeq) Equals(other interface{}) bool {
+	return IsSeqEqual(seq, other)
+}
+
+func (seq *SortedMapSeq) ToString(escape bool) string {
+	return SeqToString(seq, escape)
+}
+
+func (seq *SortedMapSeq) Pprint(w io.Writer, indent int) int {
+	return pprintSeq(seq, w, indent)
+}
+
+func (seq *SortedMapSeq) WithMeta(meta Map) Object {
+	res := *seq
+	res.meta = SafeMerge(res.meta, meta)
+	return &res
+}
+
+func (seq *SortedMapSeq) GetType() *Type {
+	return TYPE.SortedMapSeq
+}
+
+func (seq *SortedMapSeq) Hash() uint32 {
+	return hashOrdered(seq)
+}
+
+func (seq *SortedMapSeq) Seq() Seq {
+	return seq
+}
+
+func (seq *SortedMapSeq) First() Object {
+	n := seq.stack.node
+	if seq.keys {
+		return n.key
+	}
+	return NewVectorFrom(n.key, n.val)
+}
+
+func (seq *SortedMapSeq) Rest() Seq {
+	n := seq.stack.node
+	next := n.right
+	if !seq.ascending {
+		next = n.left
+	}
+	stack := pushLeft(seq.stack.next, next, seq.ascending)
+	if stack == nil {
+		return EmptyList
+	}
+	return &SortedMapSeq{stack: stack, ascending: seq.ascending, keys: seq.keys}
+}
+
+func (seq *SortedMapSeq) IsEmpty() bool {
+	return false
+}
+
+func (seq *SortedMapSeq) Cons(obj Object) Seq {
+	return &ConsSeq{first: obj, rest: seq}
+}
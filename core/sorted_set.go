@@ -0,0 +1,123 @@
+package core
+
+import (
+	"bytes"
+	"io"
+)
+
+type (
+	SortedSet struct {
+		InfoHolder
+		MetaHolder
+		m *SortedMap
+	}
+)
+
+func EmptySortedSet(cmp Comparator) *SortedSet {
+	return &SortedSet{m: EmptySortedMap(cmp)}
+}
+
+func NewSortedSet(cmp Comparator, keys ...Object) *SortedSet {
+	res := EmptySortedSet(cmp)
+	for _, key := range keys {
+		res = res.Conj(key).(*SortedSet)
+	}
+	return res
+}
+
+func (set *SortedSet) WithMeta(meta Map) Object {
+	res := *set
+	res.meta = SafeMerge(res.meta, meta)
+	return &res
+}
+
+func (set *SortedSet) with(m Map) *SortedSet {
+	res := &SortedSet{m: m.(*SortedMap)}
+	res.meta = set.meta
+	return res
+}
+
+func (set *SortedSet) Disjoin(key Object) Set {
+	return set.with(set.m.Without(key))
+}
+
+func (set *SortedSet) Conj(obj Object) Conjable {
+	if ok, _ := set.m.Get(obj); ok {
+		return set
+	}
+	return set.with(set.m.Assoc(obj, Boolean{B: true}).(Map))
+}
+
+func (set *SortedSet) Get(key Object) (bool, Object) {
+	if n := set.m.find(key); n != nil {
+		return true, n.key
+	}
+	return false, nil
+}
+
+func (set *SortedSet) CompareKeys(a, b Object) int {
+	return set.m.CompareKeys(a, b)
+}
+
+func (set *SortedSet) EntryKey(entry Object) Object {
+	return entry
+}
+
+func (set *SortedSet) Seq() Seq {
+	return set.m.seq(true, true)
+}
+
+func (set *SortedSet) Rseq() Seq {
+	return set.m.seq(false, true)
+}
+
+func (set *SortedSet) SeqFrom(key Object, ascending bool) Seq {
+	return set.m.seqFrom(key, ascending, true)
+}
+
+func (set *SortedSet) Count() int {
+	return set.m.Count()
+}
+
+func (set *SortedSet) ToString(escape bool) string {
+	var b bytes.Buffer
+	b.WriteString("#{")
+	for s := set.Seq(); !s.IsEmpty(); s = s.Rest() {
+		if b.Len() > 2 {
+			b.WriteRune(' ')
+		}
+		b.WriteString(s.First().ToString(escape))
+	}
+	b.WriteRune('}')
+	return b.String()
+}
+
+func (set *SortedSet) Equals(other interface{}) bool {
+	return setEquals(set, other)
+}
+
+func (set *SortedSet) GetType() *Type {
+	return TYPE.SortedSet
+}
+
+func (set *SortedSet) Hash() uint32 {
+	return hashUnordered(set.Seq(), 2)
+}
+
+func (set *SortedSet) Call(args []Object) Object {
+	CheckArity(args, 1, 1)
+	if ok, key := set.Get(args[0]); ok {
+		return key
+	}
+	return NIL
+}
+
+func (set *SortedSet) Empty() Collection {
+	res := EmptySortedSet(set.m.cmp)
+	res.meta = set.meta
+	return res
+}
+
+func (set *SortedSet) Pprint(w io.Writer, indent int) int {
+	return pprintLayoutObject(set, indent, w)
+}
@@ -0,0 +1,145 @@
+package core
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+/*
+   Inline suppression of linter warnings.
+
+     #_:joker/ignore <form>                     suppresses everything in <form>
+     #_{:joker/ignore [:rule ...]} <form>       suppresses the listed rules
+     ^{:joker/lint {:ignore [:rule ...]}} form  same, as metadata
+
+   Metadata on the name of a def-like form applies to the whole form.
+*/
+
+type suppression struct {
+	Position
+	directive Position
+	rules     Set // nil means all rules
+	used      bool
+}
+
+var suppressions = map[*string][]*suppression{}
+
+// ignoredRules returns the rules suppressed by obj, which is either
+// the object following #_ (with key :joker/ignore) or the value of
+// :joker/lint metadata (with key :ignore). Discarded maps without
+// the namespaced key are just commented out code.
+// nil rules mean all of them.
+func ignoredRules(obj Object, key Keyword) (bool, Set) {
+	switch obj := obj.(type) {
+	case Keyword:
+		return obj.Equals(KEYWORDS.jokerIgnore), nil
+	case Map:
+		ok, v := obj.Get(key)
+		if !ok {
+			return false, nil
+		}
+		if seq, ok := v.(Seqable); ok {
+			return true, NewSetFromSeq(seq.Seq())
+		}
+		return ToBool(v), nil
+	}
+	return false, nil
+}
+
+func (s *suppression) begin(reader *Reader) {
+	s.filename = reader.filename
+	s.startLine = reader.line
+	s.startColumn = reader.column
+	// Read warnings are reported while the form is still being read.
+	s.endLine = math.MaxInt32
+	suppressions[s.filename] = append(suppressions[s.filename], s)
+}
+
+func (s *suppression) end(reader *Reader) {
+	s.endLine = reader.line
+	s.endColumn = reader.column
+}
+
+func (s *suppression) covers(d Diagnostic) bool {
+	if d.startLine == 0 || !s.rulesCover(d.Rule) {
+		return false
+	}
+	if d.startLine < s.startLine || (d.startLine == s.startLine && d.startColumn < s.startColumn) {
+		return false
+	}
+	return d.startLine < s.endLine || (d.startLine == s.endLine && d.startColumn <= s.endColumn)
+}
+
+func (s *suppression) rulesCover(rule string) bool {
+	if s.rules == nil {
+		return true
+	}
+	ok, _ := s.rules.Get(MakeKeyword(rule))
+	return ok
+}
+
+func isSuppressed(d Diagnostic) bool {
+	for _, s := range suppressions[d.filename] {
+		if s.covers(d) {
+			s.used = true
+			return true
+		}
+	}
+	return false
+}
+
+// widenDefSuppression makes :joker/lint metadata on the name
+// of a def-like form apply to the whole form.
+func widenDefSuppression(head Object, name Object, form Object) {
+	sym, ok := head.(Symbol)
+	if !ok || !strings.HasPrefix(sym.Name(), "def") {
+		return
+	}
+	m, ok := name.(Meta)
+	if !ok || m.GetMeta() == nil {
+		return
+	}
+	if ok, _ := m.GetMeta().Get(KEYWORDS.jokerLint); !ok {
+		return
+	}
+	pos := GetPosition(name)
+	for _, s := range suppressions[pos.filename] {
+		if s.startLine == pos.startLine && s.startColumn == pos.startColumn {
+			formPos := GetPosition(form)
+			s.startLine, s.startColumn = formPos.startLine, formPos.startColumn
+			s.endLine, s.endColumn = formPos.endLine, formPos.endColumn
+		}
+	}
+}
+
+// ResetSuppressions forgets the suppressions read from filename,
+// e.g. when the file failed to parse or is about to be linted again.
+func ResetSuppressions(filename string) {
+	delete(suppressions, STRINGS.Intern(filename))
+}
+
+func WarnOnUnusedSuppressions() {
+	var unused []*suppression
+	for _, ss := range suppressions {
+		for _, s := range ss {
+			if !s.used {
+				unused = append(unused, s)
+			}
+		}
+	}
+	suppressions = map[*string][]*suppression{}
+	sort.Slice(unused, func(i, j int) bool {
+		a, b := unused[i].directive, unused[j].directive
+		if a.Filename() != b.Filename() {
+			return a.Filename() < b.Filename()
+		}
+		if a.startLine != b.startLine {
+			return a.startLine < b.startLine
+		}
+		return a.startColumn < b.startColumn
+	})
+	for _, s := range unused {
+		printParseWarning(s.directive, "unused-suppression", "unused suppression")
+	}
+}
@@ -0,0 +1,565 @@
+package core
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+/*
+   Transients: mutable versions of vectors, maps and sets used to
+   build collections in batches. A transient changes the tree nodes
+   it owns (the ones it created or copied) in place and copies the
+   nodes it shares with the persistent collection it was made from
+   before changing them. persistent! ends the editing mode, after
+   which the transient can't be used anymore.
+   dissoc!, disj! and pop! use the persistent operations,
+   which is slower but still correct.
+*/
+
+type (
+	Transient interface {
+		Object
+		Counted
+		Persistent() Object
+		TransientConj(obj Object)
+	}
+	TransientAssociative interface {
+		Transient
+		TransientAssoc(key, val Object)
+	}
+	// The nodes a transient owns, allocated on first use.
+	// Vector nodes are keyed by the address of their first element.
+	ownedNodes      map[interface{}]struct{}
+	TransientVector struct {
+		owned ownedNodes
+		root  []interface{}
+		tail  []interface{}
+		count int
+		shift uint
+		done  bool
+	}
+	// A transient map is an array map until it grows over
+	// HASHMAP_THRESHOLD, then it switches to a hash map.
+	TransientMap struct {
+		arr   []Object
+		hash  bool
+		owned ownedNodes
+		root  Node
+		count int
+		done  bool
+	}
+	TransientSet struct {
+		m *TransientMap
+	}
+)
+
+func (owned ownedNodes) owns(node interface{}) bool {
+	_, ok := owned[node]
+	return ok
+}
+
+func (owned *ownedNodes) own(node interface{}) {
+	if *owned == nil {
+		*owned = make(ownedNodes)
+	}
+	(*owned)[node] = struct{}{}
+}
+
+func ensureEditable(done bool) {
+	if done {
+		panic(RT.NewError("Transient used after persistent! call"))
+	}
+}
+
+// NewTransient returns a transient version of coll.
+func NewTransient(coll Object) Transient {
+	switch coll := coll.(type) {
+	case *Vector:
+		return NewTransientVector(coll)
+	case *ArrayVector:
+		res := NewTransientVector(EmptyVector())
+		for _, obj := range coll.arr {
+			res.TransientConj(obj)
+		}
+		return res
+	case *ArrayMap:
+		return NewTransientMap(coll)
+	case *HashMap:
+		return NewTransientMap(coll)
+	case *MapSet:
+		return &TransientSet{m: NewTransientMap(coll.m)}
+	}
+	panic(RT.NewError("Can't create transient from " + coll.GetType().ToString(false)))
+}
+
+func NewTransientVector(v *Vector) *TransientVector {
+	tail := make([]interface{}, len(v.tail), 32)
+	copy(tail, v.tail)
+	return &TransientVector{
+		root:  v.root,
+		tail:  tail,
+		count: v.count,
+		shift: v.shift,
+	}
+}
+
+func (t *TransientVector) ToString(escape bool) string {
+	return "#object[TransientVector]"
+}
+
+func (t *TransientVector) Equals(other interface{}) bool {
+	return t == other
+}
+
+func (t *TransientVector) GetInfo() *ObjectInfo {
+	return nil
+}
+
+func (t *TransientVector) WithInfo(info *ObjectInfo) Object {
+	return t
+}
+
+func (t *TransientVector) GetType() *Type {
+	return TYPE.TransientVector
+}
+
+func (t *TransientVector) Hash() uint32 {
+	return HashPtr(uintptr(unsafe.Pointer(t)))
+}
+
+// vector returns a persistent vector sharing t's nodes.
+func (t *TransientVector) vector() *Vector {
+	return &Vector{root: t.root, tail: t.tail, count: t.count, shift: t.shift}
+}
+
+func (t *TransientVector) tailoff() int {
+	return t.vector().tailoff()
+}
+
+func (t *TransientVector) editable(node []interface{}) []interface{} {
+	if t.owned.owns(&node[0]) {
+		return node
+	}
+	res := clone(node)
+	t.owned.own(&res[0])
+	return res
+}
+
+func (t *TransientVector) pushTail(level uint, parent []interface{}, tailNode []interface{}) []interface{} {
+	parent = t.editable(parent)
+	subidx := ((t.count - 1) >> level) & 0x01F
+	var nodeToInsert []interface{}
+	if level == 5 {
+		nodeToInsert = tailNode
+	} else if child := parent[subidx]; child != nil {
+		nodeToInsert = t.pushTail(level-5, child.([]interface{}), tailNode)
+	} else {
+		nodeToInsert = newPath(level-5, tailNode)
+	}
+	parent[subidx] = nodeToInsert
+	return parent
+}
+
+func (t *TransientVector) doAssoc(level uint, node []interface{}, i int, val Object) []interface{} {
+	node = t.editable(node)
+	if level == 0 {
+		node[i&0x01f] = val
+	} else {
+		subidx := (i >> level) & 0x01f
+		node[subidx] = t.doAssoc(level-5, node[subidx].([]interface{}), i, val)
+	}
+	return node
+}
+
+func (t *TransientVector) TransientConj(obj Object) {
+	ensureEditable(t.done)
+	if t.count-t.tailoff() < 32 {
+		t.tail = append(t.tail, obj)
+		t.count++
+		return
+	}
+	tailNode := t.tail
+	t.owned.own(&tailNode[0])
+	if (t.count >> 5) > (1 << t.shift) {
+		newRoot := make([]interface{}, 32)
+		newRoot[0] = t.root
+		newRoot[1] = newPath(t.shift, tailNode)
+		t.owned.own(&newRoot[0])
+		t.root = newRoot
+		t.shift += 5
+	} else {
+		t.root = t.pushTail(t.shift, t.root, tailNode)
+	}
+	t.tail = make([]interface{}, 1, 32)
+	t.tail[0] = obj
+	t.count++
+}
+
+func (t *TransientVector) TransientAssoc(key, val Object) {
+	ensureEditable(t.done)
+	i := assertInteger(key)
+	if i < 0 || i > t.count {
+		panic(RT.NewError((fmt.Sprintf("Index %d is out of bounds [0..%d]", i, t.count))))
+	}
+	switch {
+	case i == t.count:
+		t.TransientConj(val)
+	case i >= t.tailoff():
+		t.tail[i&0x01f] = val
+	default:
+		t.root = t.doAssoc(t.shift, t.root, i, val)
+	}
+}
+
+func (t *TransientVector) TransientPop() {
+	ensureEditable(t.done)
+	v := t.vector().Pop().(*Vector)
+	tail := make([]interface{}, len(v.tail), 32)
+	copy(tail, v.tail)
+	t.root, t.tail, t.count, t.shift = v.root, tail, v.count, v.shift
+}
+
+func (t *TransientVector) Persistent() Object {
+	ensureEditable(t.done)
+	t.done = true
+	return t.vector()
+}
+
+func (t *TransientVector) Count() int {
+	ensureEditable(t.done)
+	return t.count
+}
+
+func (t *TransientVector) Nth(i int) Object {
+	ensureEditable(t.done)
+	return t.vector().at(i)
+}
+
+func (t *TransientVector) TryNth(i int, d Object) Object {
+	ensureEditable(t.done)
+	return t.vector().TryNth(i, d)
+}
+
+func (t *TransientVector) Get(key Object) (bool, Object) {
+	ensureEditable(t.done)
+	return CountedIndexedGet(t.vector(), key)
+}
+
+func (t *TransientVector) Call(args []Object) Object {
+	ensureEditable(t.done)
+	return t.vector().Call(args)
+}
+
+func NewTransientMap(m Map) *TransientMap {
+	switch m := m.(type) {
+	case *ArrayMap:
+		arr := make([]Object, len(m.arr))
+		copy(arr, m.arr)
+		return &TransientMap{arr: arr}
+	case *HashMap:
+		return &TransientMap{hash: true, owned: make(ownedNodes), root: m.root, count: m.count}
+	}
+	panic(RT.NewError("Can't create transient from " + m.GetType().ToString(false)))
+}
+
+func (t *TransientMap) ToString(escape bool) string {
+	return "#object[TransientMap]"
+}
+
+func (t *TransientMap) Equals(other interface{}) bool {
+	return t == other
+}
+
+func (t *TransientMap) GetInfo() *ObjectInfo {
+	return nil
+}
+
+func (t *TransientMap) WithInfo(info *ObjectInfo) Object {
+	return t
+}
+
+func (t *TransientMap) GetType() *Type {
+	return TYPE.TransientMap
+}
+
+func (t *TransientMap) Hash() uint32 {
+	return HashPtr(uintptr(unsafe.Pointer(t)))
+}
+
+func (t *TransientMap) indexOf(key Object) int {
+	for i := 0; i < len(t.arr); i += 2 {
+		if t.arr[i].Equals(key) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *TransientMap) TransientAssoc(key, val Object) {
+	ensureEditable(t.done)
+	if !t.hash {
+		if i := t.indexOf(key); i != -1 {
+			t.arr[i+1] = val
+			return
+		}
+		if int64(len(t.arr)) < HASHMAP_THRESHOLD {
+			t.arr = append(t.arr, key, val)
+			return
+		}
+		arr := t.arr
+		t.arr = nil
+		t.hash = true
+		t.owned = make(ownedNodes)
+		for i := 0; i < len(arr); i += 2 {
+			t.hashAssoc(arr[i], arr[i+1])
+		}
+	}
+	t.hashAssoc(key, val)
+}
+
+func (t *TransientMap) hashAssoc(key, val Object) {
+	addedLeaf := &Box{}
+	root := t.root
+	if root == nil {
+		root = emptyIndexedNode
+	}
+	t.root = root.editAssoc(t.owned, 0, key.Hash(), key, val, addedLeaf)
+	if addedLeaf.val != nil {
+		t.count++
+	}
+}
+
+func (t *TransientMap) TransientConj(obj Object) {
+	ensureEditable(t.done)
+	switch obj := obj.(type) {
+	case Vec:
+		if obj.Count() != 2 {
+			panic(RT.NewError("Vector argument to map's conj must be a vector with two elements"))
+		}
+		t.TransientAssoc(obj.At(0), obj.At(1))
+	case Map:
+		for iter := obj.Iter(); iter.HasNext(); {
+			p := iter.Next()
+			t.TransientAssoc(p.Key, p.Value)
+		}
+	default:
+		panic(RT.NewError("Argument to map's conj must be a vector with two elements or a map"))
+	}
+}
+
+func (t *TransientMap) TransientDissoc(key Object) {
+	ensureEditable(t.done)
+	if !t.hash {
+		if i := t.indexOf(key); i != -1 {
+			t.arr = append(t.arr[:i], t.arr[i+2:]...)
+		}
+		return
+	}
+	if t.root == nil {
+		return
+	}
+	if root := t.root.without(0, key.Hash(), key); root != t.root {
+		t.root = root
+		t.count--
+	}
+}
+
+func (t *TransientMap) persistentMap() Map {
+	ensureEditable(t.done)
+	t.done = true
+	if !t.hash {
+		return &ArrayMap{arr: t.arr}
+	}
+	return &HashMap{root: t.root, count: t.count}
+}
+
+func (t *TransientMap) Persistent() Object {
+	return t.persistentMap()
+}
+
+func (t *TransientMap) Count() int {
+	ensureEditable(t.done)
+	if !t.hash {
+		return len(t.arr) / 2
+	}
+	return t.count
+}
+
+func (t *TransientMap) Get(key Object) (bool, Object) {
+	ensureEditable(t.done)
+	if !t.hash {
+		if i := t.indexOf(key); i != -1 {
+			return true, t.arr[i+1]
+		}
+		return false, nil
+	}
+	if t.root != nil {
+		if res := t.root.find(0, key.Hash(), key); res != nil {
+			return true, res.Value
+		}
+	}
+	return false, nil
+}
+
+func (t *TransientMap) Call(args []Object) Object {
+	CheckArity(args, 1, 2)
+	if ok, v := t.Get(args[0]); ok {
+		return v
+	}
+	if len(args) == 2 {
+		return args[1]
+	}
+	return NIL
+}
+
+func (t *TransientSet) ToString(escape bool) string {
+	return "#object[TransientSet]"
+}
+
+func (t *TransientSet) Equals(other interface{}) bool {
+	return t == other
+}
+
+func (t *TransientSet) GetInfo() *ObjectInfo {
+	return nil
+}
+
+func (t *TransientSet) WithInfo(info *ObjectInfo) Object {
+	return t
+}
+
+func (t *TransientSet) GetType() *Type {
+	return TYPE.TransientSet
+}
+
+func (t *TransientSet) Hash() uint32 {
+	return HashPtr(uintptr(unsafe.Pointer(t)))
+}
+
+func (t *TransientSet) TransientConj(obj Object) {
+	t.m.TransientAssoc(obj, Boolean{B: true})
+}
+
+func (t *TransientSet) TransientDisj(key Object) {
+	t.m.TransientDissoc(key)
+}
+
+func (t *TransientSet) Persistent() Object {
+	return &MapSet{m: t.m.persistentMap()}
+}
+
+func (t *TransientSet) Count() int {
+	return t.m.Count()
+}
+
+func (t *TransientSet) Get(key Object) (bool, Object) {
+	ensureEditable(t.m.done)
+	if !t.m.hash {
+		if i := t.m.indexOf(key); i != -1 {
+			return true, t.m.arr[i]
+		}
+		return false, nil
+	}
+	if t.m.root != nil {
+		if res := t.m.root.find(0, key.Hash(), key); res != nil {
+			return true, res.Key
+		}
+	}
+	return false, nil
+}
+
+func (t *TransientSet) Call(args []Object) Object {
+	CheckArity(args, 1, 1)
+	if ok, key := t.Get(args[0]); ok {
+		return key
+	}
+	return NIL
+}
+
+func (b *BitmapIndexedNode) editable(owned ownedNodes) *BitmapIndexedNode {
+	if owned.owns(b) {
+		return b
+	}
+	res := &BitmapIndexedNode{bitmap: b.bitmap, array: clone(b.array)}
+	owned.own(res)
+	return res
+}
+
+func (b *BitmapIndexedNode) editAssoc(owned ownedNodes, shift uint, hash uint32, key Object, val Object, addedLeaf *Box) Node {
+	bit := bitpos(hash, shift)
+	idx := b.index(bit)
+	if b.bitmap&bit != 0 {
+		keyOrNull := b.array[2*idx]
+		valOrNode := b.array[2*idx+1]
+		if keyOrNull == nil {
+			n := valOrNode.(Node).editAssoc(owned, shift+5, hash, key, val, addedLeaf)
+			if n == valOrNode {
+				return b
+			}
+			e := b.editable(owned)
+			e.array[2*idx+1] = n
+			return e
+		}
+		if key.Equals(keyOrNull) {
+			if val == valOrNode {
+				return b
+			}
+			e := b.editable(owned)
+			e.array[2*idx+1] = val
+			return e
+		}
+		addedLeaf.val = addedLeaf
+		e := b.editable(owned)
+		e.array[2*idx] = nil
+		e.array[2*idx+1] = createNode(shift+5, keyOrNull.(Object), valOrNode.(Object), hash, key, val)
+		return e
+	}
+	if bitCount(b.bitmap) >= 16 {
+		// Becomes an ArrayNode, same as in the persistent case.
+		res := b.assoc(shift, hash, key, val, addedLeaf)
+		owned.own(res)
+		return res
+	}
+	e := b.editable(owned)
+	e.array = append(e.array, nil, nil)
+	copy(e.array[2*idx+2:], e.array[2*idx:])
+	e.array[2*idx] = key
+	e.array[2*idx+1] = val
+	e.bitmap |= bit
+	addedLeaf.val = addedLeaf
+	return e
+}
+
+func (n *ArrayNode) editable(owned ownedNodes) *ArrayNode {
+	if owned.owns(n) {
+		return n
+	}
+	array := make([]Node, len(n.array))
+	copy(array, n.array)
+	res := &ArrayNode{count: n.count, array: array}
+	owned.own(res)
+	return res
+}
+
+func (n *ArrayNode) editAssoc(owned ownedNodes, shift uint, hash uint32, key Object, val Object, addedLeaf *Box) Node {
+	idx := mask(hash, shift)
+	node := n.array[idx]
+	if node == nil {
+		e := n.editable(owned)
+		e.array[idx] = emptyIndexedNode.editAssoc(owned, shift+5, hash, key, val, addedLeaf)
+		e.count++
+		return e
+	}
+	nn := node.editAssoc(owned, shift+5, hash, key, val, addedLeaf)
+	if nn == node {
+		return n
+	}
+	e := n.editable(owned)
+	e.array[idx] = nn
+	return e
+}
+
+// Collisions are rare, so HashCollisionNode is always copied.
+func (n *HashCollisionNode) editAssoc(owned ownedNodes, shift uint, hash uint32, key Object, val Object, addedLeaf *Box) Node {
+	return n.assoc(shift, hash, key, val, addedLeaf)
+}
@@ -441,6 +441,21 @@ func EnsureArgIsError(args []Object, index int) Error {
 	panic(FailArg(obj, "Error", index))
 }
 
+func EnsureObjectIsExInfo(obj Object, pattern string) *ExInfo {
+	if c, yes := obj.(*ExInfo); yes {
+		return c
+	}
+	panic(FailObject(obj, "ExInfo", pattern))
+}
+
+func EnsureArgIsExInfo(args []Object, index int) *ExInfo {
+	obj := args[index]
+	if c, yes := obj.(*ExInfo); yes {
+		return c
+	}
+	panic(FailArg(obj, "ExInfo", index))
+}
+
 func EnsureObjectIsFn(obj Object, pattern string) *Fn {
 	if c, yes := obj.(*Fn); yes {
 		return c
@@ -650,3 +665,198 @@ func EnsureArgIsCountedIndexed(args []Object, index int) CountedIndexed {
 	}
 	panic(FailArg(obj, "CountedIndexed", index))
 }
+
+func EnsureObjectIsSorted(obj Object, pattern string) Sorted {
+	if c, yes := obj.(Sorted); yes {
+		return c
+	}
+	panic(FailObject(obj, "Sorted", pattern))
+}
+
+func EnsureArgIsSorted(args []Object, index int) Sorted {
+	obj := args[index]
+	if c, yes := obj.(Sorted); yes {
+		return c
+	}
+	panic(FailArg(obj, "Sorted", index))
+}
+
+func EnsureObjectIsSortedMap(obj Object, pattern string) *SortedMap {
+	if c, yes := obj.(*SortedMap); yes {
+		return c
+	}
+	panic(FailObject(obj, "SortedMap", pattern))
+}
+
+func EnsureArgIsSortedMap(args []Object, index int) *SortedMap {
+	obj := args[index]
+	if c, yes := obj.(*SortedMap); yes {
+		return c
+	}
+	panic(FailArg(obj, "SortedMap", index))
+}
+
+func EnsureObjectIsSortedSet(obj Object, pattern string) *SortedSet {
+	if c, yes := obj.(*SortedSet); yes {
+		return c
+	}
+	panic(FailObject(obj, "SortedSet", pattern))
+}
+
+func EnsureArgIsSortedSet(args []Object, index int) *SortedSet {
+	obj := args[index]
+	if c, yes := obj.(*SortedSet); yes {
+		return c
+	}
+	panic(FailArg(obj, "SortedSet", index))
+}
+
+func EnsureObjectIsProtocol(obj Object, pattern string) *Protocol {
+	if c, yes := obj.(*Protocol); yes {
+		return c
+	}
+	panic(FailObject(obj, "Protocol", pattern))
+}
+
+func EnsureArgIsProtocol(args []Object, index int) *Protocol {
+	obj := args[index]
+	if c, yes := obj.(*Protocol); yes {
+		return c
+	}
+	panic(FailArg(obj, "Protocol", index))
+}
+
+func EnsureObjectIsTransient(obj Object, pattern string) Transient {
+	if c, yes := obj.(Transient); yes {
+		return c
+	}
+	panic(FailObject(obj, "Transient", pattern))
+}
+
+func EnsureArgIsTransient(args []Object, index int) Transient {
+	obj := args[index]
+	if c, yes := obj.(Transient); yes {
+		return c
+	}
+	panic(FailArg(obj, "Transient", index))
+}
+
+func EnsureObjectIsTransientAssociative(obj Object, pattern string) TransientAssociative {
+	if c, yes := obj.(TransientAssociative); yes {
+		return c
+	}
+	panic(FailObject(obj, "TransientAssociative", pattern))
+}
+
+func EnsureArgIsTransientAssociative(args []Object, index int) TransientAssociative {
+	obj := args[index]
+	if c, yes := obj.(TransientAssociative); yes {
+		return c
+	}
+	panic(FailArg(obj, "TransientAssociative", index))
+}
+
+func EnsureObjectIsTransientVector(obj Object, pattern string) *TransientVector {
+	if c, yes := obj.(*TransientVector); yes {
+		return c
+	}
+	panic(FailObject(obj, "TransientVector", pattern))
+}
+
+func EnsureArgIsTransientVector(args []Object, index int) *TransientVector {
+	obj := args[index]
+	if c, yes := obj.(*TransientVector); yes {
+		return c
+	}
+	panic(FailArg(obj, "TransientVector", index))
+}
+
+func EnsureObjectIsTransientMap(obj Object, pattern string) *TransientMap {
+	if c, yes := obj.(*TransientMap); yes {
+		return c
+	}
+	panic(FailObject(obj, "TransientMap", pattern))
+}
+
+func EnsureArgIsTransientMap(args []Object, index int) *TransientMap {
+	obj := args[index]
+	if c, yes := obj.(*TransientMap); yes {
+		return c
+	}
+	panic(FailArg(obj, "TransientMap", index))
+}
+
+func EnsureObjectIsTransientSet(obj Object, pattern string) *TransientSet {
+	if c, yes := obj.(*TransientSet); yes {
+		return c
+	}
+	panic(FailObject(obj, "TransientSet", pattern))
+}
+
+func EnsureArgIsTransientSet(args []Object, index int) *TransientSet {
+	obj := args[index]
+	if c, yes := obj.(*TransientSet); yes {
+		return c
+	}
+	panic(FailArg(obj, "TransientSet", index))
+}
+
+func EnsureObjectIsWatchable(obj Object, pattern string) Watchable {
+	if c, yes := obj.(Watchable); yes {
+		return c
+	}
+	panic(FailObject(obj, "Watchable", pattern))
+}
+
+func EnsureArgIsWatchable(args []Object, index int) Watchable {
+	obj := args[index]
+	if c, yes := obj.(Watchable); yes {
+		return c
+	}
+	panic(FailArg(obj, "Watchable", index))
+}
+
+func EnsureObjectIsBlockingDeref(obj Object, pattern string) BlockingDeref {
+	if c, yes := obj.(BlockingDeref); yes {
+		return c
+	}
+	panic(FailObject(obj, "BlockingDeref", pattern))
+}
+
+func EnsureArgIsBlockingDeref(args []Object, index int) BlockingDeref {
+	obj := args[index]
+	if c, yes := obj.(BlockingDeref); yes {
+		return c
+	}
+	panic(FailArg(obj, "BlockingDeref", index))
+}
+
+func EnsureObjectIsFuture(obj Object, pattern string) *Future {
+	if c, yes := obj.(*Future); yes {
+		return c
+	}
+	panic(FailObject(obj, "Future", pattern))
+}
+
+func EnsureArgIsFuture(args []Object, index int) *Future {
+	obj := args[index]
+	if c, yes := obj.(*Future); yes {
+		return c
+	}
+	panic(FailArg(obj, "Future", index))
+}
+
+func EnsureObjectIsPromise(obj Object, pattern string) *Promise {
+	if c, yes := obj.(*Promise); yes {
+		return c
+	}
+	panic(FailObject(obj, "Promise", pattern))
+}
+
+func EnsureArgIsPromise(args []Object, index int) *Promise {
+	obj := args[index]
+	if c, yes := obj.(*Promise); yes {
+		return c
+	}
+	panic(FailArg(obj, "Promise", index))
+}
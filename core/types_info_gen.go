@@ -161,3 +161,23 @@ func (x *VectorRSeq) WithInfo(info *ObjectInfo) Object {
 	x.info = info
 	return x
 }
+
+func (x *SortedMap) WithInfo(info *ObjectInfo) Object {
+	x.info = info
+	return x
+}
+
+func (x *SortedMapSeq) WithInfo(info *ObjectInfo) Object {
+	x.info = info
+	return x
+}
+
+func (x *SortedSet) WithInfo(info *ObjectInfo) Object {
+	x.info = info
+	return x
+}
+
+func (x *Record) WithInfo(info *ObjectInfo) Object {
+	x.info = info
+	return x
+}
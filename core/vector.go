@@ -442,20 +442,20 @@ func EmptyVector() *Vector {
 }
 
 func NewVectorFrom(objs ...Object) *Vector {
-	res := EmptyVector()
+	res := NewTransientVector(EmptyVector())
 	for i := 0; i < len(objs); i++ {
-		res = res.Conjoin(objs[i])
+		res.TransientConj(objs[i])
 	}
-	return res
+	return res.Persistent().(*Vector)
 }
 
 func NewVectorFromSeq(seq Seq) *Vector {
-	res := EmptyVector()
+	res := NewTransientVector(EmptyVector())
 	for !seq.IsEmpty() {
-		res = res.Conjoin(seq.First())
+		res.TransientConj(seq.First())
 		seq = seq.Rest()
 	}
-	return res
+	return res.Persistent().(*Vector)
 }
 
 func (v *Vector) Empty() Collection {
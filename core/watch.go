@@ -0,0 +1,240 @@
+package core
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+/*
+   Validators and watches of reference types (atoms and vars).
+   A validator is called with the proposed new state before it's
+   set and rejects it by returning a logical false value or by
+   throwing. Watches are called after the state has changed, in the
+   order they were added, with the key, the reference, the old
+   state and the new state.
+*/
+
+type (
+	Watchable interface {
+		Object
+		Deref
+		watchHolder() *WatchHolder
+	}
+	WatchHolder struct {
+		// *watchState, replaced as a whole so that references
+		// can be watched and updated from several goroutines.
+		state unsafe.Pointer
+	}
+	watchState struct {
+		validator Callable
+		// Key and fn of each watch in the order they were added.
+		watches []*Pair
+	}
+)
+
+func (w *WatchHolder) watchHolder() *WatchHolder {
+	return w
+}
+
+func (w *WatchHolder) loadState() *watchState {
+	if st := (*watchState)(atomic.LoadPointer(&w.state)); st != nil {
+		return st
+	}
+	return &watchState{}
+}
+
+// updateState replaces the state with the result of calling f
+// on a copy of it, retrying if another goroutine got there first.
+func (w *WatchHolder) updateState(f func(st *watchState)) {
+	for {
+		old := atomic.LoadPointer(&w.state)
+		st := &watchState{}
+		if old != nil {
+			*st = *(*watchState)(old)
+		}
+		f(st)
+		if atomic.CompareAndSwapPointer(&w.state, old, unsafe.Pointer(st)) {
+			return
+		}
+	}
+}
+
+func validateState(validator Callable, val Object) {
+	if validator != nil && !ToBool(validator.Call([]Object{val})) {
+		panic(RT.NewError("Invalid reference state"))
+	}
+}
+
+func (w *WatchHolder) validate(val Object) {
+	validateState(w.loadState().validator, val)
+}
+
+func (w *WatchHolder) notifyWatches(ref, oldVal, newVal Object) {
+	for _, p := range w.loadState().watches {
+		p.Value.(Callable).Call([]Object{p.Key, ref, oldVal, newVal})
+	}
+}
+
+// SetValidator validates the current state of ref with fn,
+// which is nil to remove the validator, and then sets it.
+func (w *WatchHolder) SetValidator(ref Deref, fn Callable) {
+	validateState(fn, ref.Deref())
+	w.updateState(func(st *watchState) {
+		st.validator = fn
+	})
+}
+
+func (w *WatchHolder) Validator() Object {
+	validator := w.loadState().validator
+	if validator == nil {
+		return NIL
+	}
+	return validator.(Object)
+}
+
+// AddWatch adds the watch fn under key, replacing
+// the watch with the same key if there is one.
+func (w *WatchHolder) AddWatch(key Object, fn Callable) {
+	w.updateState(func(st *watchState) {
+		watch := &Pair{Key: key, Value: fn.(Object)}
+		for i, p := range st.watches {
+			if p.Key.Equals(key) {
+				watches := make([]*Pair, len(st.watches))
+				copy(watches, st.watches)
+				watches[i] = watch
+				st.watches = watches
+				return
+			}
+		}
+		st.watches = append(st.watches[:len(st.watches):len(st.watches)], watch)
+	})
+}
+
+func (w *WatchHolder) RemoveWatch(key Object) {
+	w.updateState(func(st *watchState) {
+		var watches []*Pair
+		for _, p := range st.watches {
+			if !p.Key.Equals(key) {
+				watches = append(watches, p)
+			}
+		}
+		st.watches = watches
+	})
+}
+
+func NewAtom(val Object) *Atom {
+	return &Atom{value: val}
+}
+
+func (a *Atom) load() (unsafe.Pointer, Object) {
+	p := atomic.LoadPointer(&a.current)
+	if p == nil {
+		return nil, a.value
+	}
+	return p, *(*Object)(p)
+}
+
+// Swap sets the atom's value to the result of calling f on the
+// current value, validates it and notifies the watches. f may be
+// called more than once if other goroutines change the atom
+// at the same time, so it should be free of side effects.
+func (a *Atom) Swap(f func(old Object) Object) (oldVal, newVal Object) {
+	for {
+		p, old := a.load()
+		val := f(old)
+		a.validate(val)
+		if atomic.CompareAndSwapPointer(&a.current, p, unsafe.Pointer(&val)) {
+			a.notifyWatches(a, old, val)
+			return old, val
+		}
+	}
+}
+
+// SetValue validates val, sets the atom's value to it
+// and notifies the watches. It returns the old value.
+func (a *Atom) SetValue(val Object) Object {
+	old, _ := a.Swap(func(Object) Object { return val })
+	return old
+}
+
+// identical reports whether a and b are the same object. Immutable
+// scalars such as ints, strings and keywords are copied around along
+// with their reader position info, so they are identical if they are
+// equal and of the same type.
+func identical(a, b Object) bool {
+	switch a := a.(type) {
+	case Nil:
+		_, ok := b.(Nil)
+		return ok
+	case Boolean:
+		b, ok := b.(Boolean)
+		return ok && a.B == b.B
+	case Keyword:
+		return a.Equals(b)
+	case Int:
+		b, ok := b.(Int)
+		return ok && a.I == b.I
+	case Char:
+		b, ok := b.(Char)
+		return ok && a.Ch == b.Ch
+	case String:
+		b, ok := b.(String)
+		return ok && a.S == b.S
+	case Double:
+		b, ok := b.(Double)
+		return ok && a.D == b.D
+	case *BigInt:
+		b, ok := b.(*BigInt)
+		return ok && a.b.Cmp(b.b) == 0
+	case *BigFloat:
+		b, ok := b.(*BigFloat)
+		return ok && a.b.Cmp(b.b) == 0
+	case *Ratio:
+		b, ok := b.(*Ratio)
+		return ok && a.r.Cmp(b.r) == 0
+	}
+	return a == b
+}
+
+// CompareAndSet sets the atom's value to newVal if, and only
+// if, the current value is identical to oldVal.
+func (a *Atom) CompareAndSet(oldVal, newVal Object) bool {
+	a.validate(newVal)
+	for {
+		p, old := a.load()
+		if !identical(old, oldVal) {
+			return false
+		}
+		if atomic.CompareAndSwapPointer(&a.current, p, unsafe.Pointer(&newVal)) {
+			a.notifyWatches(a, old, newVal)
+			return true
+		}
+	}
+}
+
+// BindRoot validates val, sets the var's root value to it
+// and notifies the watches.
+func (v *Var) BindRoot(val Object) {
+	v.validate(val)
+	old := v.Resolve()
+	v.SetRoot(val)
+	v.notifyWatches(v, old, val)
+}
+
+// AlterRoot sets the var's root value to the result of calling f
+// on the current one. Like Atom.Swap, f may be called more than once.
+func (v *Var) AlterRoot(f func(old Object) Object) Object {
+	for {
+		p := atomic.LoadPointer(&v.root)
+		old := v.Resolve()
+		if p != nil {
+			old = *(*Object)(p)
+		}
+		val := f(old)
+		v.validate(val)
+		if atomic.CompareAndSwapPointer(&v.root, p, unsafe.Pointer(&val)) {
+			v.notifyWatches(v, old, val)
+			return val
+		}
+	}
+}
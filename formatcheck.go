@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/candid82/joker/core"
+)
+
+/*
+   --format --check and formatting of directories.
+
+   With --check, nothing is written: a unified diff is printed for
+   every file whose formatted source differs, and Joker exits with
+   a non-zero code if there is any. With --working-dir, all Clojure
+   and Joker files in the directory are formatted (or checked), except
+   those matching :ignored-file-regexes of the directory's .joker file.
+*/
+
+var formatExtensions = []string{".clj", ".cljs", ".cljc", ".joke", ".edn"}
+
+func formatDirFiles(dirname string) []string {
+	var files []string
+	filepath.Walk(dirname, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			fmt.Fprintln(Stderr, "Error: ", err)
+			return nil
+		}
+		if info.IsDir() || isIgnored(path) {
+			return nil
+		}
+		for _, ext := range formatExtensions {
+			if strings.HasSuffix(path, ext) {
+				files = append(files, path)
+				break
+			}
+		}
+		return nil
+	})
+	return files
+}
+
+// formatSource returns src, read from filename, formatted.
+func formatSource(src string, filename string) (string, error) {
+	var b bytes.Buffer
+	stdout := Stdout
+	Stdout = &b
+	defer func() {
+		Stdout = stdout
+	}()
+	reader := NewReader(bufio.NewReader(strings.NewReader(src)), filename)
+	if err := ProcessReader(reader, filename, FORMAT); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// formatChecked formats filename ("-" for stdin), only the lines of
+// formatLineRange if it's set. With check set it prints a diff if the
+// file isn't formatted, with write set it rewrites the file, otherwise
+// it prints the result. It returns false if the file isn't formatted
+// or can't be.
+func formatChecked(filename string, check bool, write bool) bool {
+	var content []byte
+	var err error
+	name := filename
+	if filename == "-" {
+		name = "<stdin>"
+		content, err = io.ReadAll(Stdin)
+	} else {
+		content, err = os.ReadFile(filename)
+	}
+	if err != nil {
+		fmt.Fprintln(Stderr, "Error: ", err)
+		return false
+	}
+	src := string(content)
+	var formatted string
+	ok := true
+	if formatLineRange != nil {
+		formatted, ok = formatRange(src, name, formatLineRange)
+	} else if formatted, err = formatSource(src, name); err != nil {
+		return false
+	}
+	if !check && !write {
+		fmt.Fprint(Stdout, formatted)
+		return ok
+	}
+	if formatted == src {
+		return ok
+	}
+	if check {
+		writeUnifiedDiff(Stdout, name, src, formatted)
+		return false
+	}
+	info, err := os.Stat(filename)
+	if err == nil {
+		err = os.WriteFile(filename, []byte(formatted), info.Mode())
+	}
+	if err != nil {
+		fmt.Fprintln(Stderr, "Error: ", err)
+		return false
+	}
+	return ok
+}
+
+// formatFiles formats (or checks) filename or the files in dirname.
+// It returns false if any of them isn't formatted or can't be.
+func formatFiles(filename string, dirname string, check bool, write bool) bool {
+	var files []string
+	if filename != "" {
+		if filename == "-" {
+			ReadFormatConfig("", dirname)
+		} else {
+			ReadFormatConfig(filename, dirname)
+		}
+		files = []string{filename}
+	} else {
+		ReadConfig("", dirname)
+		files = formatDirFiles(dirname)
+	}
+	ok := true
+	for _, path := range files {
+		if !formatChecked(path, check, write) {
+			ok = false
+		}
+	}
+	return ok
+}
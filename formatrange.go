@@ -0,0 +1,318 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+   --format --range <startLine>:<endLine>.
+
+   Only the top-level forms that overlap the range of lines are
+   formatted; everything else is kept byte for byte. Top-level forms
+   are found by scanning the source for balanced brackets rather than
+   reading it, so a syntax error elsewhere in the file doesn't prevent
+   formatting the selection: a form that isn't closed by the end of the
+   file ends before the next line that doesn't start with whitespace,
+   a comment or a closing bracket, and scanning resumes there.
+*/
+
+type (
+	lineRange struct {
+		start int
+		end   int
+	}
+	topLevelForm struct {
+		start     int // byte offsets
+		end       int
+		startLine int
+		endLine   int
+	}
+	formScanner struct {
+		src   string
+		pos   int
+		line  int
+		forms []topLevelForm
+	}
+)
+
+// The lines of the file to format, nil to format all of it.
+var formatLineRange *lineRange
+
+// parseLineRange parses <startLine>:<endLine>.
+func parseLineRange(s string) (*lineRange, bool) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return nil, false
+	}
+	start, err1 := strconv.Atoi(parts[0])
+	end, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || start < 1 || end < start {
+		return nil, false
+	}
+	return &lineRange{start: start, end: end}, true
+}
+
+// topLevelForms returns the top-level forms of src, comments excluded.
+func topLevelForms(src string) []topLevelForm {
+	s := &formScanner{src: src, line: 1}
+	s.scan()
+	return s.forms
+}
+
+func isFormDelimiter(r rune) bool {
+	switch r {
+	case '(', ')', '[', ']', '{', '}', '"', ';', ',':
+		return true
+	}
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f'
+}
+
+func (s *formScanner) peek(offset int) byte {
+	if s.pos+offset < len(s.src) {
+		return s.src[s.pos+offset]
+	}
+	return 0
+}
+
+// skip advances past n bytes, counting lines.
+func (s *formScanner) skip(n int) {
+	for i := 0; i < n && s.pos < len(s.src); i++ {
+		if s.src[s.pos] == '\n' {
+			s.line++
+		}
+		s.pos++
+	}
+}
+
+func (s *formScanner) skipToken() {
+	for s.pos < len(s.src) {
+		r, size := utf8.DecodeRuneInString(s.src[s.pos:])
+		if isFormDelimiter(r) {
+			return
+		}
+		s.pos += size
+	}
+}
+
+// skipString advances past a string (or a regex) starting at the quote.
+func (s *formScanner) skipString() {
+	s.skip(1)
+	for s.pos < len(s.src) {
+		switch s.src[s.pos] {
+		case '\\':
+			s.skip(2)
+			continue
+		case '"':
+			s.skip(1)
+			return
+		}
+		s.skip(1)
+	}
+}
+
+// skipChar advances past a character literal like \a or \newline.
+func (s *formScanner) skipChar() {
+	s.pos++
+	if s.pos < len(s.src) {
+		_, size := utf8.DecodeRuneInString(s.src[s.pos:])
+		if s.src[s.pos] == '\n' {
+			s.line++
+		}
+		s.pos += size
+	}
+	s.skipToken()
+}
+
+func (s *formScanner) scan() {
+	// The number of forms each pending prefix (like ' or ^) still needs.
+	var need []int
+	depth := 0
+	start, startLine := -1, 0
+	end, endLine := 0, 0
+	begin := func() {
+		if start < 0 {
+			start, startLine = s.pos, s.line
+		}
+	}
+	finish := func() {
+		if start >= 0 {
+			if end < start {
+				end, endLine = start+1, startLine
+			}
+			s.forms = append(s.forms, topLevelForm{start: start, end: end, startLine: startLine, endLine: endLine})
+		}
+		start, need, depth = -1, nil, 0
+	}
+	// completed is called after a form has been scanned.
+	completed := func() {
+		end, endLine = s.pos, s.line
+		if s.pos > 0 && s.src[s.pos-1] == '\n' {
+			endLine--
+		}
+		if depth > 0 {
+			return
+		}
+		for len(need) > 0 {
+			need[len(need)-1]--
+			if need[len(need)-1] > 0 {
+				return
+			}
+			need = need[:len(need)-1]
+		}
+		finish()
+	}
+	prefix := func(n, size int) {
+		if depth == 0 {
+			begin()
+			need = append(need, n)
+		}
+		s.skip(size)
+	}
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		switch c {
+		case ' ', '\t', '\n', '\r', '\f', ',':
+			s.skip(1)
+		case ';':
+			for s.pos < len(s.src) && s.src[s.pos] != '\n' {
+				s.pos++
+			}
+		case '(', '[', '{':
+			begin()
+			depth++
+			s.skip(1)
+		case ')', ']', '}':
+			if depth == 0 {
+				// A stray closing bracket is a form of its own.
+				begin()
+				s.skip(1)
+				completed()
+				continue
+			}
+			depth--
+			s.skip(1)
+			completed()
+		case '"':
+			begin()
+			s.skipString()
+			completed()
+		case '\\':
+			begin()
+			s.skipChar()
+			completed()
+		case '\'', '`', '@':
+			prefix(1, 1)
+		case '~':
+			if s.peek(1) == '@' {
+				prefix(1, 2)
+			} else {
+				prefix(1, 1)
+			}
+		case '^':
+			// Metadata and the form it's attached to.
+			prefix(2, 1)
+		case '#':
+			switch s.peek(1) {
+			case '(', '{':
+				begin()
+				depth++
+				s.skip(2)
+			case '"':
+				begin()
+				s.pos++
+				s.skipString()
+				completed()
+			case '_', '\'', '=':
+				prefix(1, 2)
+			case '?':
+				if s.peek(2) == '@' {
+					prefix(1, 3)
+				} else {
+					prefix(1, 2)
+				}
+			case '#':
+				begin()
+				s.skipToken()
+				completed()
+			default:
+				// Tagged literal or namespaced map: #tag form, #:ns{...}.
+				if depth == 0 {
+					begin()
+					need = append(need, 1)
+				}
+				s.pos++
+				s.skipToken()
+			}
+		default:
+			begin()
+			s.skipToken()
+			completed()
+		}
+	}
+	if start < 0 {
+		return
+	}
+	// The form isn't closed: it ends before the next line that
+	// looks like the start of a top-level form.
+	next := s.nextFormLine(start)
+	end, endLine = next, s.lineAt(next)
+	for end > start && strings.ContainsRune(" \t\r\n\f,", rune(s.src[end-1])) {
+		if s.src[end-1] == '\n' {
+			endLine--
+		}
+		end--
+	}
+	finish()
+	if next < len(s.src) {
+		s.pos, s.line = next, s.lineAt(next)
+		s.scan()
+	}
+}
+
+// nextFormLine returns the offset of the first line after pos
+// that doesn't start with whitespace, a comment or a closing bracket.
+func (s *formScanner) nextFormLine(pos int) int {
+	for {
+		i := strings.IndexByte(s.src[pos:], '\n')
+		if i < 0 {
+			return len(s.src)
+		}
+		pos += i + 1
+		if pos < len(s.src) && !strings.ContainsRune(" \t\r\n\f,;)]}", rune(s.src[pos])) {
+			return pos
+		}
+	}
+}
+
+func (s *formScanner) lineAt(pos int) int {
+	return strings.Count(s.src[:pos], "\n") + 1
+}
+
+// formatRange returns src with the top-level forms that overlap
+// lines r formatted. It returns false if any of them can't be read.
+func formatRange(src string, filename string, r *lineRange) (string, bool) {
+	var b strings.Builder
+	ok := true
+	prev := 0
+	for _, form := range topLevelForms(src) {
+		if form.startLine > r.end || form.endLine < r.start {
+			continue
+		}
+		text := src[form.start:form.end]
+		// Pad the form so that read errors have the positions of the file.
+		lineStart := strings.LastIndexByte(src[:form.start], '\n') + 1
+		padding := strings.Repeat("\n", form.startLine-1) + strings.Repeat(" ", utf8.RuneCountInString(src[lineStart:form.start]))
+		formatted, err := formatSource(padding+text, filename)
+		if err != nil {
+			ok = false
+			continue
+		}
+		b.WriteString(src[prev:form.start])
+		b.WriteString(strings.TrimSuffix(formatted, "\n"))
+		prev = form.end
+	}
+	b.WriteString(src[prev:])
+	return b.String(), ok
+}
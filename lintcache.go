@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	. "github.com/candid82/joker/core"
+)
+
+/*
+   Persistent lint cache (--lint --cache).
+
+   For every file of a linted directory the cache keeps the hash of
+   its content, what it declared, what it used and what was reported
+   for it. On the next run a file whose content hasn't changed is not
+   parsed in the first pass (its declarations are restored instead),
+   and is not linted in the second pass either unless one of the
+   namespaces it depends on declares something different now.
+
+   The cache is only valid for the same Joker version, dialect,
+   options and linter configuration (.joker file and .jokerd directory).
+*/
+
+type lintCacheEntry struct {
+	Hash         string            `json:"hash"`
+	Declarations []CachedNamespace `json:"declarations,omitempty"`
+	// What parsing the file in the first pass used.
+	DeclarationUsage CachedUsage       `json:"declarationUsage"`
+	Deps             map[string]string `json:"deps,omitempty"`
+	// Namespaces whose name was last given in the file.
+	Namespaces   []CachedNamespace   `json:"namespaces,omitempty"`
+	Usage        CachedUsage         `json:"usage"`
+	Output       string              `json:"output,omitempty"`
+	Diagnostics  []lintDiagnostic    `json:"diagnostics,omitempty"`
+	Problems     int                 `json:"problems,omitempty"`
+	Failed       bool                `json:"failed,omitempty"`
+	Suppressions []CachedSuppression `json:"suppressions,omitempty"`
+}
+
+type lintCache struct {
+	Config string                     `json:"config"`
+	Files  map[string]*lintCacheEntry `json:"files"`
+	path   string
+	next   map[string]*lintCacheEntry
+	fresh  map[string]bool
+}
+
+// lintCacheRecorder records what linting a file reports and uses.
+type lintCacheRecorder struct {
+	entry       *lintCacheEntry
+	stderr      io.Writer
+	stderrObj   Object
+	output      bytes.Buffer
+	problems    int
+	diagnostics int
+	usage       *GlobalUsage
+}
+
+var errCachedFailure = errors.New("file failed to lint")
+
+func hashFile(filename string) string {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func lintCacheConfig(dirname string, dialect Dialect, reportGloballyUnused bool) string {
+	h := sha256.New()
+	fmt.Fprintln(h, VERSION, dialect, reportGloballyUnused, lintFormat)
+	for _, f := range LinterConfigFiles("", dirname) {
+		fmt.Fprintln(h, f, hashFile(f))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func defaultLintCachePath(dirname string, dialect Dialect) string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	dir, err := filepath.Abs(dirname)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(dir + "\x00" + strconv.Itoa(int(dialect))))
+	return filepath.Join(cacheDir, "joker", "lint-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+func openLintCache(path string, dirname string, dialect Dialect, reportGloballyUnused bool) *lintCache {
+	if path == "" {
+		path = defaultLintCachePath(dirname, dialect)
+	}
+	c := newLintCache(path, lintCacheConfig(dirname, dialect, reportGloballyUnused))
+	c.load(path)
+	return c
+}
+
+// newLintCache returns an empty cache. If path is empty
+// the cache is only kept in memory.
+func newLintCache(path string, config string) *lintCache {
+	return &lintCache{
+		Config: config,
+		path:   path,
+		next:   map[string]*lintCacheEntry{},
+		fresh:  map[string]bool{},
+	}
+}
+
+func (c *lintCache) load(path string) {
+	config := c.Config
+	if content, err := os.ReadFile(path); err == nil {
+		if json.Unmarshal(content, c) != nil || c.Config != config {
+			c.Files = nil
+		}
+	}
+	c.Config = config
+}
+
+func (c *lintCache) save() {
+	if c.path == "" {
+		return
+	}
+	c.Files = c.next
+	if err := c.write(c.path); err != nil {
+		fmt.Fprintln(Stderr, "Error writing lint cache: ", err)
+	}
+}
+
+// write writes the entries of this run to path.
+func (c *lintCache) write(path string) error {
+	content, err := json.Marshal(&lintCache{Config: c.Config, Files: c.next})
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0777)
+	}
+	if err == nil {
+		tmp := path + ".tmp"
+		if err = os.WriteFile(tmp, content, 0666); err == nil {
+			err = os.Rename(tmp, path)
+		}
+	}
+	return err
+}
+
+// entry returns the cache entry for filename in this run, which is
+// the one from the previous run if the content hasn't changed.
+func (c *lintCache) entry(filename string) *lintCacheEntry {
+	if e := c.next[filename]; e != nil {
+		return e
+	}
+	hash := hashFile(filename)
+	e := c.Files[filename]
+	if e != nil && hash != "" && e.Hash == hash {
+		c.fresh[filename] = true
+	} else {
+		e = &lintCacheEntry{Hash: hash}
+	}
+	c.next[filename] = e
+	return e
+}
+
+// upToDate returns true if what was reported for filename
+// in the previous run can be reported again.
+func (c *lintCache) upToDate(filename string, nsHashes map[string]string) bool {
+	e := c.entry(filename)
+	if !c.fresh[filename] {
+		return false
+	}
+	for ns, hash := range e.Deps {
+		if nsHashes[ns] != hash {
+			return false
+		}
+	}
+	return true
+}
+
+// replay reports again what was reported for filename.
+func (c *lintCache) replay(filename string) error {
+	e := c.entry(filename)
+	ReplayDeclarations(filename, e.Declarations, e.Namespaces)
+	MarkCachedUsage(e.Usage)
+	RestoreSuppressions(filename, e.Suppressions)
+	fmt.Fprint(Stderr, e.Output)
+	collectedDiagnostics = append(collectedDiagnostics, e.Diagnostics...)
+	PROBLEM_COUNT += e.Problems
+	if e.Failed {
+		return errCachedFailure
+	}
+	return nil
+}
+
+func (c *lintCache) record(filename string) *lintCacheRecorder {
+	r := &lintCacheRecorder{
+		entry:       c.entry(filename),
+		stderr:      Stderr,
+		problems:    PROBLEM_COUNT,
+		diagnostics: len(collectedDiagnostics),
+		usage:       TakeGlobalUsage(),
+	}
+	Stderr = io.MultiWriter(r.stderr, &r.output)
+	stdin, stdout, stderrObj := GLOBAL_ENV.StdIO()
+	r.stderrObj = stderrObj
+	GLOBAL_ENV.SetStdIO(stdin, stdout, MakeIOWriter(Stderr))
+	return r
+}
+
+// finish completes the entry of the file just linted.
+// It must be called while its namespace is still current.
+func (r *lintCacheRecorder) finish(filename string, nsHashes map[string]string, err error) {
+	Stderr = r.stderr
+	stdin, stdout, _ := GLOBAL_ENV.StdIO()
+	GLOBAL_ENV.SetStdIO(stdin, stdout, r.stderrObj)
+	usage := TakeGlobalUsage()
+	usage.Mark()
+	r.usage.Mark()
+	e := r.entry
+	e.Usage = usage.Cached()
+	e.Deps = map[string]string{}
+	for _, ns := range Dependencies(e.Usage) {
+		e.Deps[ns] = nsHashes[ns]
+	}
+	e.Namespaces = NamespacePositions(filename)
+	e.Output = r.output.String()
+	e.Diagnostics = append([]lintDiagnostic(nil), collectedDiagnostics[r.diagnostics:]...)
+	e.Problems = PROBLEM_COUNT - r.problems
+	e.Failed = err != nil
+	e.Suppressions = Suppressions(filename)
+}
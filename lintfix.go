@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	. "github.com/candid82/joker/core"
+)
+
+/*
+   --lint --fix: rewrite linted files to fix mechanical warnings.
+
+   While linting, diagnostics are held back until the file they
+   belong to is done. Then the fixable ones are fixed (or shown as
+   a diff with --dry-run) and the rest are reported as usual.
+*/
+
+var pendingDiagnostics []Diagnostic
+
+func collectPendingDiagnostic(d Diagnostic) {
+	pendingDiagnostics = append(pendingDiagnostics, d)
+}
+
+func flushPendingDiagnostics() {
+	for _, d := range pendingDiagnostics {
+		collectDiagnostic(d)
+	}
+	pendingDiagnostics = nil
+}
+
+func fixFile(filename string, dryRun bool) {
+	diagnostics := pendingDiagnostics
+	pendingDiagnostics = nil
+	defer func() {
+		pendingDiagnostics = append(pendingDiagnostics, diagnostics...)
+		flushPendingDiagnostics()
+	}()
+	info, err := os.Stat(filename)
+	if err != nil {
+		return
+	}
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintln(Stderr, "Error: ", err)
+		return
+	}
+	fixedSrc, fixed := FixSource(string(src), filename, diagnostics)
+	if fixedSrc == string(src) {
+		return
+	}
+	isFixed := map[Diagnostic]bool{}
+	for _, d := range fixed {
+		isFixed[d] = true
+	}
+	var remaining []Diagnostic
+	for _, d := range diagnostics {
+		if !isFixed[d] {
+			remaining = append(remaining, d)
+		}
+	}
+	if dryRun {
+		writeUnifiedDiff(Stdout, filename, string(src), fixedSrc)
+		return
+	}
+	if err := os.WriteFile(filename, []byte(fixedSrc), info.Mode()); err != nil {
+		fmt.Fprintln(Stderr, "Error: ", err)
+		return
+	}
+	diagnostics = remaining
+}
+
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns, for every line of a and b, whether it is
+// kept, using Myers' algorithm.
+func diffLines(a, b []string) (keptA, keptB []bool) {
+	n, m := len(a), len(b)
+	max := n + m
+	v := make([]int, 2*max+2)
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				keptA, keptB = make([]bool, n), make([]bool, m)
+				for ; d > 0; d-- {
+					v := trace[d]
+					k := x - y
+					var prevK int
+					if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+						prevK = k + 1
+					} else {
+						prevK = k - 1
+					}
+					prevX := v[max+prevK]
+					prevY := prevX - prevK
+					for x > prevX && y > prevY {
+						x--
+						y--
+						keptA[x], keptB[y] = true, true
+					}
+					x, y = prevX, prevY
+				}
+				for x > 0 && y > 0 {
+					x--
+					y--
+					keptA[x], keptB[y] = true, true
+				}
+				return
+			}
+		}
+	}
+	return
+}
+
+func writeUnifiedDiff(w io.Writer, filename string, before string, after string) {
+	const context = 3
+	a, b := splitLines(before), splitLines(after)
+	keptA, keptB := diffLines(a, b)
+	type op struct {
+		kind byte
+		line string
+	}
+	var ops []op
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && !keptA[i]:
+			ops = append(ops, op{'-', a[i]})
+			i++
+		case j < len(b) && !keptB[j]:
+			ops = append(ops, op{'+', b[j]})
+			j++
+		default:
+			ops = append(ops, op{' ', a[i]})
+			i++
+			j++
+		}
+	}
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", filename, filename)
+	lineA, lineB := 1, 1
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			lineA++
+			lineB++
+			k++
+			continue
+		}
+		// Start a hunk with up to `context` lines before the change
+		// and extend it while changes are close enough.
+		start := k
+		for start > 0 && k-start < context && ops[start-1].kind == ' ' {
+			start--
+		}
+		end := k
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*context {
+				if run-end < context {
+					end = run
+				} else {
+					end += context
+				}
+				break
+			}
+			end = run
+		}
+		startA, startB := lineA-(k-start), lineB-(k-start)
+		countA, countB := 0, 0
+		for _, o := range ops[start:end] {
+			if o.kind != '+' {
+				countA++
+			}
+			if o.kind != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", startA, countA, startB, countB)
+		for _, o := range ops[start:end] {
+			fmt.Fprintf(w, "%c%s", o.kind, o.line)
+			if !strings.HasSuffix(o.line, "\n") {
+				fmt.Fprint(w, "\n\\ No newline at end of file\n")
+			}
+		}
+		lineA, lineB = startA+countA, startB+countB
+		k = end
+	}
+}
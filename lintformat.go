@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+
+	. "github.com/candid82/joker/core"
+)
+
+/*
+   Machine-readable linter output (--lint-format).
+
+   Diagnostics are collected while linting and written to stdout
+   in one go once linting is done, since JSON, SARIF and checkstyle
+   documents can't be streamed line by line the way text output is.
+*/
+
+type lintDiagnostic struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	Severity  string `json:"severity"`
+	Rule      string `json:"rule"`
+	Kind      string `json:"kind"`
+	Message   string `json:"message"`
+}
+
+var lintFormats = []string{"text", "json", "sarif", "checkstyle"}
+
+var collectedDiagnostics []lintDiagnostic
+
+func isValidLintFormat(format string) bool {
+	for _, f := range lintFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+func makeLintDiagnostic(d Diagnostic) lintDiagnostic {
+	res := lintDiagnostic{
+		File:      d.Filename(),
+		Line:      d.StartLine(),
+		Column:    d.StartColumn(),
+		EndLine:   d.EndLine(),
+		EndColumn: d.EndColumn(),
+		Severity:  "warning",
+		Rule:      d.Rule,
+		Kind:      d.Kind,
+		Message:   d.Message,
+	}
+	if d.IsError() {
+		res.Severity = "error"
+	}
+	// Reader diagnostics only know where they start.
+	if res.EndLine < res.Line || (res.EndLine == res.Line && res.EndColumn < res.Column) {
+		res.EndLine, res.EndColumn = res.Line, res.Column
+	}
+	return res
+}
+
+func collectDiagnostic(d Diagnostic) {
+	collectedDiagnostics = append(collectedDiagnostics, makeLintDiagnostic(d))
+}
+
+func writeLintDiagnostics(format string) {
+	switch format {
+	case "text":
+		// Only diagnostics held back by --fix end up here.
+		for _, d := range collectedDiagnostics {
+			fmt.Fprintf(Stderr, "%s:%d:%d: %s: %s\n", d.File, d.Line, d.Column, d.Kind, d.Message)
+		}
+	case "json":
+		writeJSONDiagnostics(Stdout)
+	case "sarif":
+		writeSARIFDiagnostics(Stdout)
+	case "checkstyle":
+		writeCheckstyleDiagnostics(Stdout)
+	}
+}
+
+func writeJSON(out io.Writer, v interface{}) {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintln(Stderr, "Error: ", err)
+	}
+}
+
+func writeJSONDiagnostics(out io.Writer) {
+	res := collectedDiagnostics
+	if res == nil {
+		res = []lintDiagnostic{}
+	}
+	writeJSON(out, res)
+}
+
+func sarifUri(filename string) string {
+	if filepath.IsAbs(filename) {
+		return pathToUri(filename)
+	}
+	return filepath.ToSlash(filename)
+}
+
+func writeSARIFDiagnostics(out io.Writer) {
+	type object = map[string]interface{}
+	rules := []object{}
+	ruleIndex := map[string]int{}
+	results := []object{}
+	for _, d := range collectedDiagnostics {
+		if _, ok := ruleIndex[d.Rule]; !ok {
+			ruleIndex[d.Rule] = len(rules)
+			rules = append(rules, object{"id": d.Rule})
+		}
+		level := "warning"
+		if d.Severity == "error" {
+			level = "error"
+		}
+		region := object{}
+		if d.Line > 0 {
+			region["startLine"] = d.Line
+			region["startColumn"] = d.Column
+			region["endLine"] = d.EndLine
+			// SARIF end columns are exclusive.
+			region["endColumn"] = d.EndColumn + 1
+		}
+		results = append(results, object{
+			"ruleId":    d.Rule,
+			"ruleIndex": ruleIndex[d.Rule],
+			"level":     level,
+			"message":   object{"text": d.Message},
+			"locations": []object{{
+				"physicalLocation": object{
+					"artifactLocation": object{"uri": sarifUri(d.File)},
+					"region":           region,
+				},
+			}},
+		})
+	}
+	writeJSON(out, object{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []object{{
+			"tool": object{
+				"driver": object{
+					"name":           "joker",
+					"version":        VERSION,
+					"informationUri": "https://github.com/candid82/joker",
+					"rules":          rules,
+				},
+			},
+			"results": results,
+		}},
+	})
+}
+
+func writeCheckstyleDiagnostics(out io.Writer) {
+	type checkstyleError struct {
+		Line     int    `xml:"line,attr"`
+		Column   int    `xml:"column,attr"`
+		Severity string `xml:"severity,attr"`
+		Message  string `xml:"message,attr"`
+		Source   string `xml:"source,attr"`
+	}
+	type checkstyleFile struct {
+		Name   string            `xml:"name,attr"`
+		Errors []checkstyleError `xml:"error"`
+	}
+	type checkstyle struct {
+		XMLName xml.Name          `xml:"checkstyle"`
+		Version string            `xml:"version,attr"`
+		Files   []*checkstyleFile `xml:"file"`
+	}
+	files := map[string]*checkstyleFile{}
+	res := checkstyle{Version: "4.3"}
+	for _, d := range collectedDiagnostics {
+		f := files[d.File]
+		if f == nil {
+			f = &checkstyleFile{Name: d.File}
+			files[d.File] = f
+			res.Files = append(res.Files, f)
+		}
+		f.Errors = append(f.Errors, checkstyleError{
+			Line:     d.Line,
+			Column:   d.Column,
+			Severity: d.Severity,
+			Message:  d.Message,
+			Source:   "joker." + d.Rule,
+		})
+	}
+	sort.SliceStable(res.Files, func(i, j int) bool {
+		return res.Files[i].Name < res.Files[j].Name
+	})
+	fmt.Fprint(out, xml.Header)
+	enc := xml.NewEncoder(out)
+	enc.Indent("", "  ")
+	if err := enc.Encode(res); err != nil {
+		fmt.Fprintln(Stderr, "Error: ", err)
+	}
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	. "github.com/candid82/joker/core"
+)
+
+/*
+   Parallel linting of directories (--lint --jobs N).
+
+   The second pass of linting a directory is split between N worker
+   processes, each with its own namespaces and parser state. A worker
+   restores the declarations found in the first pass from a lint cache
+   written by the parent, lints its share of the files and writes their
+   cache entries back. The parent then reports all the files in order
+   from these entries, as it does for files that are up to date in a
+   persistent cache, so the output doesn't depend on the number of jobs.
+   The files a worker fails to lint are linted by the parent.
+*/
+
+type lintJob struct {
+	Dir    string   `json:"dir"`
+	Cache  string   `json:"cache"`
+	Files  []string `json:"files"`
+	Lint   []string `json:"lint"`
+	Output string   `json:"output"`
+}
+
+func runLintJobs(cache *lintCache, files []string, nsHashes map[string]string, dirname string, dialect Dialect, reportGloballyUnused bool) {
+	var pending []string
+	for _, path := range files {
+		if !cache.upToDate(path, nsHashes) {
+			pending = append(pending, path)
+		}
+	}
+	n := jobs
+	if n > len(pending) {
+		n = len(pending)
+	}
+	if n < 2 {
+		return
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return
+	}
+	tmp, err := os.MkdirTemp("", "joker-lint")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(tmp)
+	input := filepath.Join(tmp, "cache.json")
+	if cache.write(input) != nil {
+		return
+	}
+	results := make([]map[string]*lintCacheEntry, n)
+	var wg sync.WaitGroup
+	for w := 0; w < n; w++ {
+		job := lintJob{
+			Dir:    dirname,
+			Cache:  input,
+			Files:  files,
+			Output: filepath.Join(tmp, "output-"+strconv.Itoa(w)+".json"),
+		}
+		for i := w; i < len(pending); i += n {
+			job.Lint = append(job.Lint, pending[i])
+		}
+		jobFile := filepath.Join(tmp, "job-"+strconv.Itoa(w)+".json")
+		if writeJSONFile(jobFile, job) != nil {
+			continue
+		}
+		args := []string{"--lint", "--dialect", dialectArg(dialect), "--lint-format", lintFormat, "--lint-worker", jobFile}
+		if reportGloballyUnused {
+			args = append(args, "--report-globally-unused")
+		}
+		wg.Add(1)
+		go func(w int, job lintJob) {
+			defer wg.Done()
+			if exec.Command(exe, args...).Run() != nil {
+				return
+			}
+			var res map[string]*lintCacheEntry
+			if readJSONFile(job.Output, &res) == nil {
+				results[w] = res
+			}
+		}(w, job)
+	}
+	wg.Wait()
+	for _, res := range results {
+		for path, e := range res {
+			cache.next[path] = e
+			cache.fresh[path] = true
+		}
+	}
+}
+
+// lintWorker lints the files of a job started by runLintJobs.
+func lintWorker(jobFile string, dialect Dialect, reportGloballyUnused bool) {
+	var job lintJob
+	if err := readJSONFile(jobFile, &job); err != nil {
+		fmt.Fprintln(Stderr, "Error: ", err)
+		ExitJoker(1)
+	}
+	phase := dirPhase(dialect)
+	ns := GLOBAL_ENV.CurrentNamespace()
+	ReadConfig("", job.Dir)
+	configureLinterMode(dialect, "", job.Dir)
+	gensym := GENSYM
+	cache := newLintCache("", lintCacheConfig(job.Dir, dialect, reportGloballyUnused))
+	cache.load(job.Cache)
+	// What is reported goes to the cache entries.
+	Stderr = io.Discard
+	if phase == PARSE {
+		predeclareDir(job.Files, ns, cache)
+	}
+	nsHashes := NamespaceHashes()
+	lint := map[string]bool{}
+	for _, path := range job.Lint {
+		lint[path] = true
+	}
+	res := map[string]*lintCacheEntry{}
+	for _, path := range job.Files {
+		if !lint[path] {
+			// Linted by another worker: define its vars as linting it would.
+			ReplayDeclarations(path, cache.entry(path).Declarations, nil)
+			continue
+		}
+		recorder := cache.record(path)
+		processErr := lintDirFile(path, phase, gensym, reportGloballyUnused)
+		recorder.finish(path, nsHashes, processErr)
+		ResetUsage()
+		GLOBAL_ENV.SetCurrentNamespace(ns)
+		res[path] = cache.entry(path)
+	}
+	if err := writeJSONFile(job.Output, res); err != nil {
+		fmt.Fprintln(Stderr, "Error: ", err)
+		ExitJoker(1)
+	}
+}
+
+func writeJSONFile(filename string, v interface{}) error {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, content, 0666)
+}
+
+func readJSONFile(filename string, v interface{}) error {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, v)
+}
@@ -0,0 +1,762 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	. "github.com/candid82/joker/core"
+)
+
+/*
+   Language Server Protocol front end for the linter and formatter.
+
+   The server talks JSON-RPC over stdin/stdout. Every time a buffer
+   changes it is linted from scratch, the same way --lint lints a
+   single file, and the collected diagnostics are published.
+   All documents are linted in the dialect of the first one (or the
+   one given by --dialect), since linter mode can only be configured
+   once per process. Positions are sent and received in UTF-16 code
+   units, unless the client accepts UTF-32 ones, i.e. runes.
+*/
+
+type (
+	lspMessage struct {
+		Jsonrpc string           `json:"jsonrpc"`
+		Id      *json.RawMessage `json:"id,omitempty"`
+		Method  string           `json:"method,omitempty"`
+		Params  json.RawMessage  `json:"params,omitempty"`
+		Result  interface{}      `json:"result,omitempty"`
+		Error   *lspError        `json:"error,omitempty"`
+	}
+
+	lspError struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+
+	lspPosition struct {
+		Line      int `json:"line"`
+		Character int `json:"character"`
+	}
+
+	lspRange struct {
+		Start lspPosition `json:"start"`
+		End   lspPosition `json:"end"`
+	}
+
+	lspLocation struct {
+		Uri   string   `json:"uri"`
+		Range lspRange `json:"range"`
+	}
+
+	lspDiagnostic struct {
+		Range    lspRange `json:"range"`
+		Severity int      `json:"severity"`
+		Code     string   `json:"code,omitempty"`
+		Source   string   `json:"source"`
+		Message  string   `json:"message"`
+	}
+
+	lspTextEdit struct {
+		Range   lspRange `json:"range"`
+		NewText string   `json:"newText"`
+	}
+
+	lspDocumentSymbol struct {
+		Name           string   `json:"name"`
+		Detail         string   `json:"detail,omitempty"`
+		Kind           int      `json:"kind"`
+		Range          lspRange `json:"range"`
+		SelectionRange lspRange `json:"selectionRange"`
+	}
+
+	lspTextDocumentPositionParams struct {
+		TextDocument struct {
+			Uri string `json:"uri"`
+		} `json:"textDocument"`
+		Position lspPosition `json:"position"`
+	}
+
+	lspDocument struct {
+		uri  string
+		path string
+		text string
+		ns   *Namespace
+	}
+
+	lspServer struct {
+		in               *bufio.Reader
+		out              io.Writer
+		documents        map[string]*lspDocument
+		workspaceFolders []string
+		dialect          Dialect
+		configured       bool
+		baseNamespaces   map[*string]bool
+		userNs           *Namespace
+		shutdown         bool
+		// Whether the client counts characters in UTF-16 code units,
+		// as LSP does unless the client can take UTF-32, i.e. runes,
+		// the way Joker counts columns.
+		utf16 bool
+	}
+)
+
+const (
+	lspSeverityError   = 1
+	lspSeverityWarning = 2
+
+	lspSymbolNamespace = 3
+	lspSymbolClass     = 5
+	lspSymbolInterface = 11
+	lspSymbolFunction  = 12
+	lspSymbolVariable  = 13
+)
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	path := u.Path
+	if runtime.GOOS == "windows" {
+		path = strings.TrimPrefix(path, "/")
+	}
+	return filepath.FromSlash(path)
+}
+
+func pathToUri(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// encodePosition converts pos, whose character is a rune index
+// into its line, to the position encoding the client expects.
+func (srv *lspServer) encodePosition(lines []string, pos lspPosition) lspPosition {
+	if !srv.utf16 || pos.Line < 0 || pos.Line >= len(lines) {
+		return pos
+	}
+	n := 0
+	for _, r := range lines[pos.Line] {
+		if pos.Character == 0 {
+			break
+		}
+		pos.Character--
+		n += utf16Len(r)
+	}
+	// Past the end of the line, count every character as one unit.
+	pos.Character += n
+	return pos
+}
+
+// decodePosition is the reverse of encodePosition.
+func (srv *lspServer) decodePosition(lines []string, pos lspPosition) lspPosition {
+	if !srv.utf16 || pos.Line < 0 || pos.Line >= len(lines) {
+		return pos
+	}
+	n := 0
+	for _, r := range lines[pos.Line] {
+		if pos.Character <= 0 {
+			break
+		}
+		pos.Character -= utf16Len(r)
+		n++
+	}
+	if pos.Character > 0 {
+		n += pos.Character
+	}
+	pos.Character = n
+	return pos
+}
+
+func (srv *lspServer) encodeRange(lines []string, r lspRange) lspRange {
+	return lspRange{Start: srv.encodePosition(lines, r.Start), End: srv.encodePosition(lines, r.End)}
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// fileLines returns the lines of the file at path, preferring
+// the text of its open document, if any.
+func (srv *lspServer) fileLines(path string) []string {
+	for _, doc := range srv.documents {
+		if doc.path == path {
+			return strings.Split(doc.text, "\n")
+		}
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return strings.Split(string(b), "\n")
+}
+
+func (srv *lspServer) readMessage() (*lspMessage, error) {
+	length := -1
+	for {
+		line, err := srv.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(strings.ToLower(line), "content-length:") {
+			length, err = strconv.Atoi(strings.TrimSpace(line[len("content-length:"):]))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(srv.in, body); err != nil {
+		return nil, err
+	}
+	msg := &lspMessage{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (srv *lspServer) write(msg *lspMessage) {
+	msg.Jsonrpc = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		fmt.Fprintln(Stderr, "Error: ", err)
+		return
+	}
+	fmt.Fprintf(srv.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (srv *lspServer) reply(id *json.RawMessage, result interface{}) {
+	if result == nil {
+		// Marshal an explicit null rather than omitting the result.
+		result = json.RawMessage("null")
+	}
+	srv.write(&lspMessage{Id: id, Result: result})
+}
+
+func (srv *lspServer) replyError(id *json.RawMessage, code int, message string) {
+	srv.write(&lspMessage{Id: id, Error: &lspError{Code: code, Message: message}})
+}
+
+func (srv *lspServer) notify(method string, params interface{}) {
+	p, _ := json.Marshal(params)
+	srv.write(&lspMessage{Method: method, Params: p})
+}
+
+func (srv *lspServer) workspaceFolderFor(path string) string {
+	res := ""
+	for _, folder := range srv.workspaceFolders {
+		if strings.HasPrefix(path, folder+string(filepath.Separator)) && len(folder) > len(res) {
+			res = folder
+		}
+	}
+	return res
+}
+
+// resetLinterState forgets everything learned while linting
+// previous buffers, so that every buffer is linted as if it were
+// the only file passed to --lint.
+func (srv *lspServer) resetLinterState(doc *lspDocument) string {
+	workingDir := srv.workspaceFolderFor(doc.path)
+	if !srv.configured {
+		if srv.dialect == UNKNOWN {
+			srv.dialect = detectDialect(doc.path)
+		}
+		ReadConfig(doc.path, workingDir)
+		configureLinterMode(srv.dialect, doc.path, workingDir)
+		srv.userNs = GLOBAL_ENV.CurrentNamespace()
+		srv.baseNamespaces = map[*string]bool{}
+		for name := range GLOBAL_ENV.Namespaces {
+			srv.baseNamespaces[name] = true
+		}
+		srv.configured = true
+		return workingDir
+	}
+	for name, ns := range GLOBAL_ENV.Namespaces {
+		if !srv.baseNamespaces[name] {
+			GLOBAL_ENV.RemoveNamespace(ns.Name)
+		}
+	}
+	ResetUsage()
+	GLOBAL_ENV.SetCurrentNamespace(srv.userNs)
+	GLOBAL_ENV.CoreNamespace.Resolve("*loaded-libs*").SetRoot(EmptySet())
+	ReadConfig(doc.path, workingDir)
+	ProcessLinterFiles(srv.dialect, doc.path, workingDir)
+	return workingDir
+}
+
+func diagnosticRange(d Diagnostic, text []string) lspRange {
+	start := lspPosition{Line: d.StartLine() - 1, Character: d.StartColumn() - 1}
+	if start.Line < 0 {
+		start.Line = 0
+	}
+	if start.Character < 0 {
+		start.Character = 0
+	}
+	end := lspPosition{Line: d.EndLine() - 1, Character: d.EndColumn()}
+	if d.EndLine() == 0 || end.Line < start.Line || (end.Line == start.Line && end.Character <= start.Character) {
+		// Highlight up to the end of the token at the start position.
+		end = lspPosition{Line: start.Line, Character: start.Character + 1}
+		if start.Line < len(text) {
+			line := []rune(text[start.Line])
+			for end.Character < len(line) && !isDelimiterRune(line[end.Character]) {
+				end.Character++
+			}
+		}
+	}
+	return lspRange{Start: start, End: end}
+}
+
+func (srv *lspServer) lint(doc *lspDocument) {
+	srv.resetLinterState(doc)
+	diagnostics := []lspDiagnostic{}
+	lines := strings.Split(doc.text, "\n")
+	DiagnosticHandler = func(d Diagnostic) {
+		if d.StartLine() > 0 && d.Filename() != doc.path {
+			return
+		}
+		severity := lspSeverityWarning
+		if d.IsError() {
+			severity = lspSeverityError
+		}
+		diagnostics = append(diagnostics, lspDiagnostic{
+			Range:    srv.encodeRange(lines, diagnosticRange(d, lines)),
+			Severity: severity,
+			Code:     d.Rule,
+			Source:   "joker",
+			Message:  d.Message,
+		})
+	}
+	func() {
+		defer func() {
+			DiagnosticHandler = nil
+			if r := recover(); r != nil {
+				fmt.Fprintln(Stderr, r)
+			}
+		}()
+		phase := PARSE
+		if srv.dialect == EDN {
+			phase = READ
+		}
+		ResetSuppressions(doc.path)
+		reader := NewReader(strings.NewReader(doc.text), doc.path)
+		if ProcessReader(reader, doc.path, phase) == nil {
+			WarnOnUnusedNamespaces()
+			WarnOnUnusedVars()
+			WarnOnUnusedSuppressions()
+		}
+		doc.ns = GLOBAL_ENV.CurrentNamespace()
+	}()
+	srv.notify("textDocument/publishDiagnostics", map[string]interface{}{
+		"uri":         doc.uri,
+		"diagnostics": diagnostics,
+	})
+}
+
+func isDelimiterRune(r rune) bool {
+	switch r {
+	case '(', ')', '[', ']', '{', '}', '"', ';', ',', '\\', '@', '^', '`', '~', '\'':
+		return true
+	}
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+// symbolAt returns the symbol under the cursor, if any.
+func (doc *lspDocument) symbolAt(pos lspPosition) string {
+	lines := strings.Split(doc.text, "\n")
+	if pos.Line < 0 || pos.Line >= len(lines) {
+		return ""
+	}
+	line := []rune(lines[pos.Line])
+	start, end := pos.Character, pos.Character
+	if start > len(line) {
+		return ""
+	}
+	for start > 0 && !isDelimiterRune(line[start-1]) {
+		start--
+	}
+	for end < len(line) && !isDelimiterRune(line[end]) {
+		end++
+	}
+	sym := string(line[start:end])
+	if sym == "" || strings.HasPrefix(sym, ":") || strings.HasPrefix(sym, "#") {
+		return ""
+	}
+	return sym
+}
+
+func (srv *lspServer) resolve(doc *lspDocument, name string) *Var {
+	if name == "" || !srv.configured {
+		return nil
+	}
+	ns := doc.ns
+	if ns == nil {
+		ns = GLOBAL_ENV.CurrentNamespace()
+	}
+	vr, ok := GLOBAL_ENV.ResolveIn(ns, MakeSymbol(name))
+	if !ok {
+		return nil
+	}
+	return vr
+}
+
+func unquote(obj Object) Object {
+	if seq, ok := obj.(Seq); ok && !seq.IsEmpty() && seq.First().Equals(MakeSymbol("quote")) {
+		return seq.Rest().First()
+	}
+	return obj
+}
+
+func (srv *lspServer) hover(doc *lspDocument, pos lspPosition) interface{} {
+	vr := srv.resolve(doc, doc.symbolAt(srv.decodePosition(strings.Split(doc.text, "\n"), pos)))
+	if vr == nil {
+		return nil
+	}
+	var b bytes.Buffer
+	b.WriteString("```clojure\n" + vr.Name() + "\n")
+	meta := vr.GetMeta()
+	if meta != nil {
+		if ok, arglists := meta.Get(MakeKeyword("arglists")); ok {
+			if s, ok := unquote(arglists).(Seqable); ok {
+				for s := s.Seq(); !s.IsEmpty(); s = s.Rest() {
+					b.WriteString(s.First().ToString(true) + "\n")
+				}
+			}
+		}
+	}
+	b.WriteString("```\n")
+	if meta != nil {
+		if ok, doc := meta.Get(MakeKeyword("doc")); ok {
+			if s, ok := doc.(String); ok {
+				b.WriteString("\n" + s.S + "\n")
+			}
+		}
+	}
+	return map[string]interface{}{
+		"contents": map[string]interface{}{
+			"kind":  "markdown",
+			"value": b.String(),
+		},
+	}
+}
+
+func (srv *lspServer) definition(doc *lspDocument, pos lspPosition) interface{} {
+	vr := srv.resolve(doc, doc.symbolAt(srv.decodePosition(strings.Split(doc.text, "\n"), pos)))
+	if vr == nil {
+		return nil
+	}
+	filename, line, column := "", 0, 0
+	if info := vr.GetInfo(); info != nil {
+		filename, line, column = info.Filename(), info.StartLine(), info.StartColumn()
+	}
+	if meta := vr.GetMeta(); meta != nil && filename == "" {
+		if ok, f := meta.Get(MakeKeyword("file")); ok {
+			filename = f.ToString(false)
+		}
+		if ok, l := meta.Get(MakeKeyword("line")); ok {
+			if i, ok := l.(Int); ok {
+				line = i.I
+			}
+		}
+		if ok, c := meta.Get(MakeKeyword("column")); ok {
+			if i, ok := c.(Int); ok {
+				column = i.I
+			}
+		}
+	}
+	if filename == "" || !filepath.IsAbs(filename) || line == 0 {
+		return nil
+	}
+	p := srv.encodePosition(srv.fileLines(filename), lspPosition{Line: line - 1, Character: column - 1})
+	return lspLocation{Uri: pathToUri(filename), Range: lspRange{Start: p, End: p}}
+}
+
+func formRange(obj Object) lspRange {
+	info := obj.GetInfo()
+	if info == nil {
+		return lspRange{}
+	}
+	return lspRange{
+		Start: lspPosition{Line: info.StartLine() - 1, Character: info.StartColumn() - 1},
+		End:   lspPosition{Line: info.EndLine() - 1, Character: info.EndColumn()},
+	}
+}
+
+func defSymbolKind(def string) int {
+	switch def {
+	case "ns":
+		return lspSymbolNamespace
+	case "defn", "defn-", "defmacro", "defmulti", "defmethod":
+		return lspSymbolFunction
+	case "defrecord", "deftype":
+		return lspSymbolClass
+	case "defprotocol", "definterface":
+		return lspSymbolInterface
+	}
+	return lspSymbolVariable
+}
+
+func (srv *lspServer) documentSymbols(doc *lspDocument) interface{} {
+	symbols := []lspDocumentSymbol{}
+	lines := strings.Split(doc.text, "\n")
+	reader := NewReader(strings.NewReader(doc.text), doc.path)
+	for {
+		obj, err := TryRead(reader)
+		if err != nil {
+			// Report the symbols found before the first read error.
+			break
+		}
+		seq, ok := obj.(Seq)
+		if !ok || seq.IsEmpty() {
+			continue
+		}
+		head, ok := seq.First().(Symbol)
+		if !ok {
+			continue
+		}
+		def := head.Name()
+		if def != "ns" && !strings.HasPrefix(def, "def") {
+			continue
+		}
+		name, ok := seq.Rest().First().(Symbol)
+		if !ok {
+			continue
+		}
+		symbols = append(symbols, lspDocumentSymbol{
+			Name:           name.ToString(false),
+			Detail:         head.ToString(false),
+			Kind:           defSymbolKind(def),
+			Range:          srv.encodeRange(lines, formRange(obj)),
+			SelectionRange: srv.encodeRange(lines, formRange(name)),
+		})
+	}
+	return symbols
+}
+
+func (srv *lspServer) formatting(doc *lspDocument) interface{} {
+	var b bytes.Buffer
+	oldStdout := Stdout
+	oldThreshold := HASHMAP_THRESHOLD
+	Stdout = &b
+	defer func() {
+		Stdout = oldStdout
+		FORMAT_MODE = false
+		HASHMAP_THRESHOLD = oldThreshold
+	}()
+	ReadFormatConfig(doc.path, "")
+	reader := NewReader(strings.NewReader(doc.text), doc.path)
+	if err := ProcessReader(reader, "", FORMAT); err != nil {
+		return nil
+	}
+	lines := strings.Split(doc.text, "\n")
+	return []lspTextEdit{{
+		Range: lspRange{
+			Start: lspPosition{Line: 0, Character: 0},
+			End:   lspPosition{Line: len(lines), Character: 0},
+		},
+		NewText: b.String(),
+	}}
+}
+
+func (srv *lspServer) initialize(params json.RawMessage) interface{} {
+	var p struct {
+		RootUri          string `json:"rootUri"`
+		WorkspaceFolders []struct {
+			Uri string `json:"uri"`
+		} `json:"workspaceFolders"`
+		Capabilities struct {
+			General struct {
+				PositionEncodings []string `json:"positionEncodings"`
+			} `json:"general"`
+		} `json:"capabilities"`
+	}
+	json.Unmarshal(params, &p)
+	encoding := "utf-16"
+	for _, e := range p.Capabilities.General.PositionEncodings {
+		if e == "utf-32" {
+			encoding = e
+			srv.utf16 = false
+		}
+	}
+	for _, f := range p.WorkspaceFolders {
+		srv.workspaceFolders = append(srv.workspaceFolders, uriToPath(f.Uri))
+	}
+	if len(srv.workspaceFolders) == 0 && p.RootUri != "" {
+		srv.workspaceFolders = append(srv.workspaceFolders, uriToPath(p.RootUri))
+	}
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"positionEncoding": encoding,
+			"textDocumentSync": map[string]interface{}{
+				"openClose": true,
+				"change":    1, // full document sync
+				"save":      true,
+			},
+			"hoverProvider":              true,
+			"definitionProvider":         true,
+			"documentSymbolProvider":     true,
+			"documentFormattingProvider": true,
+			"workspace": map[string]interface{}{
+				"workspaceFolders": map[string]interface{}{
+					"supported":           true,
+					"changeNotifications": true,
+				},
+			},
+		},
+		"serverInfo": map[string]interface{}{
+			"name":    "joker",
+			"version": VERSION,
+		},
+	}
+}
+
+func (srv *lspServer) handle(msg *lspMessage) {
+	var p struct {
+		TextDocument struct {
+			Uri  string `json:"uri"`
+			Text string `json:"text"`
+		} `json:"textDocument"`
+		ContentChanges []struct {
+			Text string `json:"text"`
+		} `json:"contentChanges"`
+		Event struct {
+			Added []struct {
+				Uri string `json:"uri"`
+			} `json:"added"`
+			Removed []struct {
+				Uri string `json:"uri"`
+			} `json:"removed"`
+		} `json:"event"`
+	}
+	json.Unmarshal(msg.Params, &p)
+	doc := srv.documents[p.TextDocument.Uri]
+
+	switch msg.Method {
+	case "initialize":
+		srv.reply(msg.Id, srv.initialize(msg.Params))
+	case "initialized", "$/cancelRequest", "$/setTrace", "workspace/didChangeConfiguration":
+	case "shutdown":
+		srv.shutdown = true
+		srv.reply(msg.Id, nil)
+	case "exit":
+		if srv.shutdown {
+			ExitJoker(0)
+		}
+		ExitJoker(1)
+	case "workspace/didChangeWorkspaceFolders":
+		for _, f := range p.Event.Added {
+			srv.workspaceFolders = append(srv.workspaceFolders, uriToPath(f.Uri))
+		}
+		for _, f := range p.Event.Removed {
+			path := uriToPath(f.Uri)
+			for i, folder := range srv.workspaceFolders {
+				if folder == path {
+					srv.workspaceFolders = append(srv.workspaceFolders[:i], srv.workspaceFolders[i+1:]...)
+					break
+				}
+			}
+		}
+	case "textDocument/didOpen":
+		doc = &lspDocument{uri: p.TextDocument.Uri, path: uriToPath(p.TextDocument.Uri), text: p.TextDocument.Text}
+		srv.documents[doc.uri] = doc
+		srv.lint(doc)
+	case "textDocument/didChange":
+		if doc != nil && len(p.ContentChanges) > 0 {
+			doc.text = p.ContentChanges[len(p.ContentChanges)-1].Text
+			srv.lint(doc)
+		}
+	case "textDocument/didSave":
+		if doc != nil {
+			srv.lint(doc)
+		}
+	case "textDocument/didClose":
+		if doc != nil {
+			delete(srv.documents, doc.uri)
+			srv.notify("textDocument/publishDiagnostics", map[string]interface{}{
+				"uri":         doc.uri,
+				"diagnostics": []lspDiagnostic{},
+			})
+		}
+	case "textDocument/hover", "textDocument/definition":
+		var pp lspTextDocumentPositionParams
+		json.Unmarshal(msg.Params, &pp)
+		if doc == nil {
+			srv.reply(msg.Id, nil)
+		} else if msg.Method == "textDocument/hover" {
+			srv.reply(msg.Id, srv.hover(doc, pp.Position))
+		} else {
+			srv.reply(msg.Id, srv.definition(doc, pp.Position))
+		}
+	case "textDocument/documentSymbol":
+		if doc == nil {
+			srv.reply(msg.Id, nil)
+		} else {
+			srv.reply(msg.Id, srv.documentSymbols(doc))
+		}
+	case "textDocument/formatting":
+		if doc == nil {
+			srv.reply(msg.Id, nil)
+		} else {
+			srv.reply(msg.Id, srv.formatting(doc))
+		}
+	default:
+		if msg.Id != nil {
+			srv.replyError(msg.Id, -32601, "Method not found: "+msg.Method)
+		}
+	}
+}
+
+func lsp(dialect Dialect) {
+	srv := &lspServer{
+		in:        bufio.NewReader(os.Stdin),
+		out:       os.Stdout,
+		documents: map[string]*lspDocument{},
+		dialect:   dialect,
+		utf16:     true,
+	}
+	// Stdout belongs to the protocol; anything else goes to stderr.
+	Stdout = Stderr
+	GLOBAL_ENV.SetStdIO(MakeBufferedReader(strings.NewReader("")), MakeIOWriter(Stderr), MakeIOWriter(Stderr))
+	for {
+		msg, err := srv.readMessage()
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintln(Stderr, "Error: ", err)
+			}
+			return
+		}
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					fmt.Fprintln(Stderr, r)
+					if msg.Id != nil {
+						srv.replyError(msg.Id, -32603, fmt.Sprint(r))
+					}
+				}
+			}()
+			srv.handle(msg)
+		}()
+	}
+}
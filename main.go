@@ -30,6 +30,7 @@ import (
 	_ "github.com/candid82/joker/std/math"
 	_ "github.com/candid82/joker/std/os"
 	_ "github.com/candid82/joker/std/runtime"
+	_ "github.com/candid82/joker/std/sql"
 	_ "github.com/candid82/joker/std/strconv"
 	_ "github.com/candid82/joker/std/string"
 	_ "github.com/candid82/joker/std/time"
@@ -65,6 +66,12 @@ type (
 		third  *Var
 		exc    *Var
 	}
+	replHistory struct {
+		first  Object
+		second Object
+		third  Object
+		exc    Object
+	}
 )
 
 func NewReplContext(env *Env) *ReplContext {
@@ -72,10 +79,10 @@ func NewReplContext(env *Env) *ReplContext {
 	second, _ := env.Resolve(MakeSymbol("joker.core/*2"))
 	third, _ := env.Resolve(MakeSymbol("joker.core/*3"))
 	exc, _ := env.Resolve(MakeSymbol("joker.core/*e"))
-	first.Value = NIL
-	second.Value = NIL
-	third.Value = NIL
-	exc.Value = NIL
+	first.SetRoot(NIL)
+	second.SetRoot(NIL)
+	third.SetRoot(NIL)
+	exc.SetRoot(NIL)
 	return &ReplContext{
 		first:  first,
 		second: second,
@@ -85,13 +92,30 @@ func NewReplContext(env *Env) *ReplContext {
 }
 
 func (ctx *ReplContext) PushValue(obj Object) {
-	ctx.third.Value = ctx.second.Value
-	ctx.second.Value = ctx.first.Value
-	ctx.first.Value = obj
+	ctx.third.Set(ctx.second.Resolve())
+	ctx.second.Set(ctx.first.Resolve())
+	ctx.first.Set(obj)
 }
 
 func (ctx *ReplContext) PushException(exc Object) {
-	ctx.exc.Value = exc
+	ctx.exc.Set(exc)
+}
+
+func (ctx *ReplContext) save() replHistory {
+	return replHistory{
+		first:  ctx.first.Resolve(),
+		second: ctx.second.Resolve(),
+		third:  ctx.third.Resolve(),
+		exc:    ctx.exc.Resolve(),
+	}
+}
+
+// bind binds *1, *2, *3 and *e to the values of h in e.
+func (ctx *ReplContext) bind(e *Evaluation, h replHistory) {
+	e.Bind(ctx.first, h.first)
+	e.Bind(ctx.second, h.second)
+	e.Bind(ctx.third, h.third)
+	e.Bind(ctx.exc, h.exc)
 }
 
 func processFile(filename string, phase Phase) error {
@@ -271,11 +295,11 @@ func configureLinterMode(dialect Dialect, filename string, workingDir string) {
 	if dialect != JOKER {
 		RemoveJokerNamespaces()
 	}
-	GLOBAL_ENV.CoreNamespace.Resolve("*loaded-libs*").Value = EmptySet()
+	GLOBAL_ENV.CoreNamespace.Resolve("*loaded-libs*").SetRoot(EmptySet())
 	LINTER_MODE = true
 	DIALECT = dialect
 	lm, _ := GLOBAL_ENV.Resolve(MakeSymbol("joker.core/*linter-mode*"))
-	lm.Value = Boolean{B: true}
+	lm.SetRoot(Boolean{B: true})
 	GLOBAL_ENV.Features = GLOBAL_ENV.Features.Disjoin(MakeKeyword("joker")).Conj(makeDialectKeyword(dialect)).(Set)
 	EnableIdentValidation()
 }
@@ -302,6 +326,10 @@ func lintFile(filename string, dialect Dialect, workingDir string) {
 	if processFile(filename, phase) == nil {
 		WarnOnUnusedNamespaces()
 		WarnOnUnusedVars()
+		WarnOnUnusedSuppressions()
+	}
+	if fixFlag {
+		fixFile(filename, dryRunFlag)
 	}
 }
 
@@ -330,35 +358,150 @@ func isIgnored(path string) bool {
 	return false
 }
 
-func lintDir(dirname string, dialect Dialect, reportGloballyUnused bool) {
-	var processErr error
-	phase := PARSE
-	if dialect == EDN {
-		phase = READ
+// predeclareDir is the first pass of linting a directory. It parses
+// every file without reporting anything, so that the second pass knows
+// the vars, arglists and macros of all project namespaces, whichever
+// file they are used from. With the lint cache, files that haven't
+// changed are not parsed: their declarations are restored instead.
+func predeclareDir(files []string, ns *Namespace, cache *lintCache) {
+	handler, stderr, problemCount := DiagnosticHandler, Stderr, PROBLEM_COUNT
+	stdin, stdout, stderrObj := GLOBAL_ENV.StdIO()
+	DiagnosticHandler = func(Diagnostic) {}
+	Stderr = io.Discard
+	SkipLintRules = true
+	// Some linter macros print to *err* directly.
+	GLOBAL_ENV.SetStdIO(stdin, stdout, MakeIOWriter(io.Discard))
+	defer func() {
+		DiagnosticHandler, Stderr, PROBLEM_COUNT = handler, stderr, problemCount
+		GLOBAL_ENV.SetStdIO(stdin, stdout, stderrObj)
+		SkipLintRules = false
+	}()
+	var restored []*lintCacheEntry
+	for _, path := range files {
+		if cache != nil {
+			if entry := cache.entry(path); cache.fresh[path] {
+				RestoreDeclarations(path, entry.Declarations)
+				restored = append(restored, entry)
+				continue
+			}
+		}
+		var before *DeclarationSnapshot
+		var usage *GlobalUsage
+		if cache != nil {
+			before = TakeDeclarationSnapshot()
+			usage = TakeGlobalUsage()
+		}
+		GLOBAL_ENV.CoreNamespace.Resolve("*loaded-libs*").SetRoot(EmptySet())
+		processFile(path, PARSE)
+		if cache != nil {
+			entry := cache.entry(path)
+			entry.Declarations = Declarations(path, before)
+			fileUsage := TakeGlobalUsage()
+			entry.DeclarationUsage = fileUsage.Cached()
+			fileUsage.Mark()
+			usage.Mark()
+		}
+		ResetSuppressions(path)
+		GLOBAL_ENV.SetCurrentNamespace(ns)
 	}
-	ns := GLOBAL_ENV.CurrentNamespace()
-	ReadConfig("", dirname)
-	configureLinterMode(dialect, "", dirname)
+	// Only now that all the vars are back.
+	for _, entry := range restored {
+		MarkCachedUsage(entry.DeclarationUsage)
+	}
+	PredeclareVars()
+	ResetUsage()
+}
+
+func lintFiles(dirname string, dialect Dialect) []string {
+	var files []string
 	filepath.Walk(dirname, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			fmt.Fprintln(Stderr, "Error: ", err)
 			return nil
 		}
 		if !info.IsDir() && matchesDialect(path, dialect) && !isIgnored(path) {
-			GLOBAL_ENV.CoreNamespace.Resolve("*loaded-libs*").Value = EmptySet()
-			processErr = processFile(path, phase)
-			if processErr == nil {
-				WarnOnUnusedNamespaces()
-				WarnOnUnusedVars()
-			}
-			ResetUsage()
-			GLOBAL_ENV.SetCurrentNamespace(ns)
+			files = append(files, path)
 		}
 		return nil
 	})
+	return files
+}
+
+func dirPhase(dialect Dialect) Phase {
+	if dialect == EDN {
+		return READ
+	}
+	return PARSE
+}
+
+// lintDirFile lints a file of the directory in the second pass.
+// Generated symbols are numbered from gensym in every file so that
+// what is reported for a file doesn't depend on the files before it.
+func lintDirFile(path string, phase Phase, gensym int64, reportGloballyUnused bool) error {
+	GENSYM = gensym
+	GLOBAL_ENV.CoreNamespace.Resolve("*loaded-libs*").SetRoot(EmptySet())
+	processErr := processFile(path, phase)
+	if processErr == nil {
+		WarnOnUnusedNamespaces()
+		WarnOnUnusedVars()
+	} else {
+		ResetSuppressions(path)
+	}
+	if !reportGloballyUnused {
+		WarnOnUnusedSuppressions()
+	}
+	if fixFlag {
+		fixFile(path, dryRunFlag)
+	}
+	return processErr
+}
+
+func lintDir(dirname string, dialect Dialect, reportGloballyUnused bool) {
+	var processErr error
+	phase := dirPhase(dialect)
+	ns := GLOBAL_ENV.CurrentNamespace()
+	ReadConfig("", dirname)
+	configureLinterMode(dialect, "", dirname)
+	files := lintFiles(dirname, dialect)
+	gensym := GENSYM
+	var cache *lintCache
+	var nsHashes map[string]string
+	if cacheFlag {
+		cache = openLintCache(cacheLocation, dirname, dialect, reportGloballyUnused)
+		defer cache.save()
+	} else if jobs > 1 {
+		// Workers get the declarations of the first pass from the cache.
+		cache = newLintCache("", lintCacheConfig(dirname, dialect, reportGloballyUnused))
+	}
+	if phase == PARSE {
+		predeclareDir(files, ns, cache)
+	}
+	if cache != nil {
+		nsHashes = NamespaceHashes()
+	}
+	if jobs > 1 {
+		runLintJobs(cache, files, nsHashes, dirname, dialect, reportGloballyUnused)
+	}
+	for _, path := range files {
+		if cache != nil && cache.upToDate(path, nsHashes) {
+			processErr = cache.replay(path)
+			continue
+		}
+		var recorder *lintCacheRecorder
+		if cache != nil {
+			recorder = cache.record(path)
+		}
+		processErr = lintDirFile(path, phase, gensym, reportGloballyUnused)
+		if recorder != nil {
+			recorder.finish(path, nsHashes, processErr)
+		}
+		ResetUsage()
+		GLOBAL_ENV.SetCurrentNamespace(ns)
+	}
 	if processErr == nil && reportGloballyUnused {
 		WarnOnGloballyUnusedNamespaces()
 		WarnOnGloballyUnusedVars()
+		WarnOnUnusedSuppressions()
 	}
 }
 
@@ -376,15 +519,31 @@ func dialectFromArg(arg string) Dialect {
 	return UNKNOWN
 }
 
+func dialectArg(dialect Dialect) string {
+	switch dialect {
+	case CLJ:
+		return "clj"
+	case CLJS:
+		return "cljs"
+	case JOKER:
+		return "joker"
+	case EDN:
+		return "edn"
+	}
+	return ""
+}
+
 func usage(out io.Writer) {
 	fmt.Fprintf(out, "Joker - %s\n\n", VERSION)
 	fmt.Fprintln(out, "Usage: joker [args] [-- <repl-args>]                starts a repl")
 	fmt.Fprintln(out, "   or: joker [args] --repl [<socket>] [-- <repl-args>]")
 	fmt.Fprintln(out, "                                                    starts a repl (on optional network socket)")
+	fmt.Fprintln(out, "   or: joker [args] --nrepl <socket>                starts an nREPL server on network socket")
 	fmt.Fprintln(out, "   or: joker [args] --eval <expr> [-- <expr-args>]  evaluate <expr>, print if non-nil")
 	fmt.Fprintln(out, "   or: joker [args] [--file] <filename> [<script-args>]")
 	fmt.Fprintln(out, "                                                    input from file")
 	fmt.Fprintln(out, "   or: joker [args] --lint <filename>               lint the code in file")
+	fmt.Fprintln(out, "   or: joker [args] --lsp                           starts a Language Server Protocol server on stdin/stdout")
 	fmt.Fprintln(out, "\nNotes:")
 	fmt.Fprintln(out, "  -e is a synonym for --eval.")
 	fmt.Fprintln(out, "  '-' for <filename> means read from standard input (stdin).")
@@ -392,6 +551,7 @@ func usage(out io.Writer) {
 	fmt.Fprintln(out, "    in <repl-args>, <expr-args>, or <script-args> (TBD).")
 	fmt.Fprintln(out, "  <socket> is passed to Go's net.Listen() function. If multiple --*repl options are specified,")
 	fmt.Fprintln(out, "    the final one specified \"wins\".")
+	fmt.Fprintln(out, "  The nREPL server supports multiple concurrent sessions, each with its own *ns*, *1, *2, *3 and *e.")
 
 	fmt.Fprintln(out, "\nOptions (<args>):")
 	fmt.Fprintln(out, "  --help, -h")
@@ -404,6 +564,12 @@ func usage(out io.Writer) {
 	fmt.Fprintln(out, "    Format the source code and print it to standard output.")
 	fmt.Fprintln(out, "  --write")
 	fmt.Fprintln(out, "    Replace the file with the formatted source code. Must be used in conjunction with --format.")
+	fmt.Fprintln(out, "  --range <startLine>:<endLine>")
+	fmt.Fprintln(out, "    Format only the top-level forms that overlap the lines and keep the rest of the file as it is.")
+	fmt.Fprintln(out, "    Must be used in conjunction with --format.")
+	fmt.Fprintln(out, "  --check")
+	fmt.Fprintln(out, "    Print a unified diff for every file that isn't formatted and exit with a non-zero code")
+	fmt.Fprintln(out, "    if there is any, instead of formatting. Must be used in conjunction with --format.")
 	fmt.Fprintln(out, "  --parse")
 	fmt.Fprintln(out, "    Read and parse, but do not evaluate, the input.")
 	fmt.Fprintln(out, "  --evaluate")
@@ -417,7 +583,24 @@ func usage(out io.Writer) {
 	fmt.Fprintln(out, "  --no-repl-history")
 	fmt.Fprintln(out, "    Do not read or save repl command history to a file.")
 	fmt.Fprintln(out, "  --working-dir <directory>")
-	fmt.Fprintln(out, "    Specify directory to lint or working directory for lint configuration if linting single file (requires --lint).")
+	fmt.Fprintln(out, "    Specify directory to lint or format (with --write or --check), or working directory for")
+	fmt.Fprintln(out, "    configuration if linting or formatting a single file (requires --lint or --format).")
+	fmt.Fprintln(out, "  --lsp")
+	fmt.Fprintln(out, "    Run as a language server (diagnostics, go to definition, hover, document symbols and formatting).")
+	fmt.Fprintln(out, "    Use --dialect to set the dialect; otherwise it is detected from the first opened file.")
+	fmt.Fprintln(out, "  --lint-format <format>")
+	fmt.Fprintln(out, "    Output format for --lint: \"text\" (default), \"json\", \"sarif\" or \"checkstyle\".")
+	fmt.Fprintln(out, "    Non-text formats are written to stdout once linting is done.")
+	fmt.Fprintln(out, "  --fix")
+	fmt.Fprintln(out, "    Rewrite linted files to fix warnings that have a mechanical fix (requires --lint).")
+	fmt.Fprintln(out, "  --dry-run")
+	fmt.Fprintln(out, "    Print the changes --fix would make as a unified diff instead of writing them.")
+	fmt.Fprintln(out, "  --cache")
+	fmt.Fprintln(out, "    Cache linting results so that unchanged files are not linted again (requires --lint and --working-dir).")
+	fmt.Fprintln(out, "  --cache-location <file>")
+	fmt.Fprintln(out, "    Use <file> as the lint cache instead of one in the user cache directory (implies --cache).")
+	fmt.Fprintln(out, "  --jobs <n>")
+	fmt.Fprintln(out, "    When linting a directory, lint the files in <n> worker processes.")
 	fmt.Fprintln(out, "  --report-globally-unused")
 	fmt.Fprintln(out, "    Report globally unused namespaces and public vars when linting directories (requires --lint and --working-dir).")
 	fmt.Fprintln(out, "  --dialect <dialect>")
@@ -448,9 +631,18 @@ var (
 	lintFlag                 bool
 	reportGloballyUnusedFlag bool
 	dialect                  Dialect = UNKNOWN
+	lintFormat               string  = "text"
+	fixFlag                  bool
+	dryRunFlag               bool
+	cacheFlag                bool
+	cacheLocation            string
+	jobs                     int = 1
+	lintWorkerJob            string
 	eval                     string
 	replFlag                 bool
 	replSocket               string
+	nreplSocket              string
+	lspFlag                  bool
 	classPath                string
 	filename                 string
 	remainingArgs            []string
@@ -464,6 +656,7 @@ var (
 	exitToRepl               bool
 	errorToRepl              bool
 	writeFlag                bool
+	checkFlag                bool
 )
 
 func isNumber(s string) bool {
@@ -538,6 +731,20 @@ func parseArgs(args []string) {
 			phase = FORMAT
 		case "--write":
 			writeFlag = true
+		case "--check":
+			checkFlag = true
+		case "--range":
+			if i < length-1 && notOption(args[i+1]) {
+				i += 1 // shift
+				r, ok := parseLineRange(args[i])
+				if !ok {
+					fmt.Fprintf(Stderr, "Error: --range expects <startLine>:<endLine>, got '%s'.\n", args[i])
+					ExitJoker(2)
+				}
+				formatLineRange = r
+			} else {
+				missing = true
+			}
 		case "--read":
 			phase = READ
 		case "--parse":
@@ -567,6 +774,51 @@ func parseArgs(args []string) {
 		case "--lintedn":
 			lintFlag = true
 			dialect = EDN
+		case "--fix":
+			fixFlag = true
+		case "--dry-run":
+			dryRunFlag = true
+		case "--cache":
+			cacheFlag = true
+		case "--cache-location":
+			if i < length-1 && notOption(args[i+1]) {
+				i += 1 // shift
+				cacheLocation = args[i]
+				cacheFlag = true
+			} else {
+				missing = true
+			}
+		case "--jobs":
+			if i < length-1 && notOption(args[i+1]) {
+				i += 1 // shift
+				n, err := strconv.Atoi(args[i])
+				if err != nil || n < 1 {
+					fmt.Fprintf(Stderr, "Error: --jobs expects a positive number, got '%s'.\n", args[i])
+					ExitJoker(2)
+				}
+				jobs = n
+			} else {
+				missing = true
+			}
+		case "--lint-worker":
+			// Internal: lint the files of a job of a --jobs run.
+			if i < length-1 && notOption(args[i+1]) {
+				i += 1 // shift
+				lintWorkerJob = args[i]
+			} else {
+				missing = true
+			}
+		case "--lint-format":
+			if i < length-1 && notOption(args[i+1]) {
+				i += 1 // shift
+				lintFormat = strings.ToLower(args[i])
+				if !isValidLintFormat(lintFormat) {
+					fmt.Fprintf(Stderr, "Error: Unknown lint format '%s' (expected one of %s).\n", args[i], strings.Join(lintFormats, ", "))
+					ExitJoker(2)
+				}
+			} else {
+				missing = true
+			}
 		case "--dialect":
 			if i < length-1 && notOption(args[i+1]) {
 				i += 1 // shift
@@ -604,6 +856,15 @@ func parseArgs(args []string) {
 				i += 1 // shift
 				replSocket = args[i]
 			}
+		case "--nrepl":
+			if i < length-1 && notOption(args[i+1]) {
+				i += 1 // shift
+				nreplSocket = args[i]
+			} else {
+				missing = true
+			}
+		case "--lsp":
+			lspFlag = true
 		case "-c", "--classpath":
 			if i < length-1 && notOption(args[i+1]) {
 				i += 1 // shift
@@ -725,7 +986,6 @@ func main() {
 
 	saveForRepl = saveForRepl && (exitToRepl || errorToRepl) // don't bother saving stuff if no repl
 
-	RT.GIL.Lock()
 	ProcessCoreData()
 
 	GLOBAL_ENV.ReferCoreToUser()
@@ -740,11 +1000,19 @@ func main() {
 		fmt.Fprintf(debugOut, "lintFlag=%v\n", lintFlag)
 		fmt.Fprintf(debugOut, "reportGloballyUnusedFlag=%v\n", reportGloballyUnusedFlag)
 		fmt.Fprintf(debugOut, "dialect=%v\n", dialect)
+		fmt.Fprintf(debugOut, "lintFormat=%v\n", lintFormat)
+		fmt.Fprintf(debugOut, "fixFlag=%v\n", fixFlag)
+		fmt.Fprintf(debugOut, "dryRunFlag=%v\n", dryRunFlag)
+		fmt.Fprintf(debugOut, "cacheFlag=%v\n", cacheFlag)
+		fmt.Fprintf(debugOut, "cacheLocation=%v\n", cacheLocation)
+		fmt.Fprintf(debugOut, "jobs=%v\n", jobs)
 		fmt.Fprintf(debugOut, "workingDir=%v\n", workingDir)
 		fmt.Fprintf(debugOut, "HASHMAP_THRESHOLD=%v\n", HASHMAP_THRESHOLD)
 		fmt.Fprintf(debugOut, "eval=%v\n", eval)
 		fmt.Fprintf(debugOut, "replFlag=%v\n", replFlag)
 		fmt.Fprintf(debugOut, "replSocket=%v\n", replSocket)
+		fmt.Fprintf(debugOut, "nreplSocket=%v\n", nreplSocket)
+		fmt.Fprintf(debugOut, "lspFlag=%v\n", lspFlag)
 		fmt.Fprintf(debugOut, "classPath=%v\n", classPath)
 		fmt.Fprintf(debugOut, "noReadline=%v\n", noReadline)
 		fmt.Fprintf(debugOut, "noReplHistory=%v\n", noReplHistory)
@@ -808,6 +1076,15 @@ func main() {
 		defer finish()
 	}
 
+	if lspFlag {
+		if eval != "" || filename != "" || lintFlag || replFlag || nreplSocket != "" {
+			fmt.Fprintf(Stderr, "Error: --lsp cannot be combined with --eval, --lint, --repl, --nrepl or a <filename> argument.\n")
+			ExitJoker(19)
+		}
+		lsp(dialect)
+		return
+	}
+
 	if eval != "" {
 		if lintFlag {
 			fmt.Fprintf(Stderr, "Error: Cannot combine --eval/-e and --lint.\n")
@@ -817,6 +1094,10 @@ func main() {
 			fmt.Fprintf(Stderr, "Error: Cannot combine --eval/-e and --repl.\n")
 			ExitJoker(7)
 		}
+		if nreplSocket != "" {
+			fmt.Fprintf(Stderr, "Error: Cannot combine --eval/-e and --nrepl.\n")
+			ExitJoker(7)
+		}
 		if workingDir != "" {
 			fmt.Fprintf(Stderr, "Error: Cannot combine --eval/-e and --working-dir.\n")
 			ExitJoker(8)
@@ -849,6 +1130,10 @@ func main() {
 			fmt.Fprintf(Stderr, "Error: Cannot combine --lint and --repl.\n")
 			ExitJoker(10)
 		}
+		if nreplSocket != "" {
+			fmt.Fprintf(Stderr, "Error: Cannot combine --lint and --nrepl.\n")
+			ExitJoker(10)
+		}
 		if exitToRepl {
 			fmt.Fprintf(Stderr, "Error: Cannot combine --lint and --exit-to-repl.\n")
 			ExitJoker(14)
@@ -860,6 +1145,27 @@ func main() {
 		if dialect == UNKNOWN {
 			dialect = detectDialect(filename)
 		}
+		if cacheFlag && (fixFlag || filename != "" || workingDir == "") {
+			fmt.Fprintf(Stderr, "Error: --cache can only be used when linting a directory without --fix.\n")
+			ExitJoker(21)
+		}
+		if jobs > 1 && (fixFlag || filename != "" || workingDir == "") {
+			fmt.Fprintf(Stderr, "Error: --jobs can only be used when linting a directory without --fix.\n")
+			ExitJoker(21)
+		}
+		if fixFlag {
+			if filename == "-" {
+				fmt.Fprintf(Stderr, "Error: Cannot use --fix when reading from stdin.\n")
+				ExitJoker(20)
+			}
+			DiagnosticHandler = collectPendingDiagnostic
+		} else if lintFormat != "text" {
+			DiagnosticHandler = collectDiagnostic
+		}
+		if lintWorkerJob != "" {
+			lintWorker(lintWorkerJob, dialect, reportGloballyUnusedFlag)
+			return
+		}
 		if filename != "" {
 			lintFile(filename, dialect, workingDir)
 		} else if workingDir != "" {
@@ -868,17 +1174,83 @@ func main() {
 			fmt.Fprintf(Stderr, "Error: Missing --file or --working-dir argument.\n")
 			ExitJoker(16)
 		}
+		if fixFlag {
+			flushPendingDiagnostics()
+			PROBLEM_COUNT = len(collectedDiagnostics)
+		}
+		writeLintDiagnostics(lintFormat)
 		if PROBLEM_COUNT > 0 {
 			ExitJoker(1)
 		}
 		return
 	}
 
-	if workingDir != "" {
-		fmt.Fprintf(Stderr, "Error: Cannot specify --working-dir option when not linting.\n")
+	if checkFlag && phase != FORMAT {
+		fmt.Fprintf(Stderr, "Error: Cannot specify --check option when not formatting.\n")
 		ExitJoker(11)
 	}
 
+	if formatLineRange != nil && phase != FORMAT {
+		fmt.Fprintf(Stderr, "Error: Cannot specify --range option when not formatting.\n")
+		ExitJoker(11)
+	}
+
+	if workingDir != "" && phase != FORMAT {
+		fmt.Fprintf(Stderr, "Error: Cannot specify --working-dir option when not linting or formatting.\n")
+		ExitJoker(11)
+	}
+
+	if lintFormat != "text" {
+		fmt.Fprintf(Stderr, "Error: Cannot specify --lint-format option when not linting.\n")
+		ExitJoker(11)
+	}
+
+	if fixFlag || dryRunFlag {
+		fmt.Fprintf(Stderr, "Error: Cannot specify --fix or --dry-run option when not linting.\n")
+		ExitJoker(11)
+	}
+
+	if cacheFlag {
+		fmt.Fprintf(Stderr, "Error: Cannot specify --cache or --cache-location option when not linting.\n")
+		ExitJoker(11)
+	}
+
+	if jobs > 1 {
+		fmt.Fprintf(Stderr, "Error: Cannot specify --jobs option when not linting.\n")
+		ExitJoker(11)
+	}
+
+	if phase == FORMAT && (checkFlag || formatLineRange != nil || (filename == "" && workingDir != "")) {
+		if checkFlag && writeFlag {
+			fmt.Fprintf(Stderr, "Error: Cannot combine --check and --write.\n")
+			ExitJoker(22)
+		}
+		if filename == "" && workingDir == "" {
+			fmt.Fprintf(Stderr, "Error: Missing --file or --working-dir argument.\n")
+			ExitJoker(16)
+		}
+		if filename == "" && formatLineRange != nil {
+			fmt.Fprintf(Stderr, "Error: Cannot specify --range option when formatting a directory.\n")
+			ExitJoker(22)
+		}
+		if filename == "" && !checkFlag && !writeFlag {
+			fmt.Fprintf(Stderr, "Error: Formatting a directory requires --write or --check.\n")
+			ExitJoker(22)
+		}
+		if !formatFiles(filename, workingDir, checkFlag, writeFlag) {
+			ExitJoker(1)
+		}
+		return
+	}
+
+	if phase == FORMAT {
+		if filename == "-" {
+			ReadFormatConfig("", workingDir)
+		} else {
+			ReadFormatConfig(filename, workingDir)
+		}
+	}
+
 	if filename != "" {
 		if err := processFile(filename, phase); err != nil {
 			if !errorToRepl {
@@ -891,6 +1263,11 @@ func main() {
 		}
 	}
 
+	if nreplSocket != "" {
+		nrepl(nreplSocket)
+		return
+	}
+
 	if replSocket != "" {
 		srepl(replSocket, phase)
 		return
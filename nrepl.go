@@ -0,0 +1,692 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	. "github.com/candid82/joker/core"
+)
+
+/*
+   Bencode transport.
+
+   nREPL messages are bencoded dictionaries. Decoded values are
+   represented as int64, string, []interface{} and
+   map[string]interface{}.
+*/
+
+func bencodeRead(r *bufio.Reader) (interface{}, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c == 'i':
+		s, err := bencodeReadNumber(r, 'e')
+		if err != nil {
+			return nil, err
+		}
+		return strconv.ParseInt(s, 10, 64)
+	case c == 'l':
+		res := []interface{}{}
+		for {
+			c, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if c == 'e' {
+				return res, nil
+			}
+			r.UnreadByte()
+			v, err := bencodeRead(r)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, v)
+		}
+	case c == 'd':
+		res := map[string]interface{}{}
+		for {
+			c, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if c == 'e' {
+				return res, nil
+			}
+			r.UnreadByte()
+			k, err := bencodeRead(r)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, errors.New("bencode: dictionary key must be a string")
+			}
+			v, err := bencodeRead(r)
+			if err != nil {
+				return nil, err
+			}
+			res[key] = v
+		}
+	case c >= '0' && c <= '9':
+		r.UnreadByte()
+		s, err := bencodeReadNumber(r, ':')
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 || n > bencodeMaxString {
+			return nil, fmt.Errorf("bencode: invalid string length %d", n)
+		}
+		// Read as the bytes arrive rather than allocating n of them
+		// upfront, as n comes from the client.
+		var b strings.Builder
+		if _, err := io.CopyN(&b, r, int64(n)); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		return b.String(), nil
+	}
+	return nil, fmt.Errorf("bencode: unexpected character %q", c)
+}
+
+// The longest string bencodeRead accepts.
+const bencodeMaxString = 64 << 20
+
+// bencodeReadNumber reads the digits of a number up to delim,
+// which is consumed but not returned.
+func bencodeReadNumber(r *bufio.Reader, delim byte) (string, error) {
+	var b []byte
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if c == delim {
+			return string(b), nil
+		}
+		if len(b) == 20 {
+			return "", errors.New("bencode: number too long")
+		}
+		b = append(b, c)
+	}
+}
+
+func bencodeWrite(b *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case int:
+		fmt.Fprintf(b, "i%de", v)
+	case int64:
+		fmt.Fprintf(b, "i%de", v)
+	case bool:
+		if v {
+			b.WriteString("i1e")
+		} else {
+			b.WriteString("i0e")
+		}
+	case string:
+		fmt.Fprintf(b, "%d:%s", len(v), v)
+	case []string:
+		b.WriteByte('l')
+		for _, s := range v {
+			bencodeWrite(b, s)
+		}
+		b.WriteByte('e')
+	case []interface{}:
+		b.WriteByte('l')
+		for _, e := range v {
+			bencodeWrite(b, e)
+		}
+		b.WriteByte('e')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteByte('d')
+		for _, k := range keys {
+			bencodeWrite(b, k)
+			bencodeWrite(b, v[k])
+		}
+		b.WriteByte('e')
+	default:
+		panic(fmt.Sprintf("bencode: cannot encode %T", v))
+	}
+}
+
+/*
+   nREPL server.
+
+   Every session owns its current namespace and the values of
+   *1, *2, *3 and *e. Evaluation requests of a session are queued and
+   run one at a time on the session's goroutine, in an evaluation (see
+   core.Evaluation) that binds the current namespace, standard streams
+   and *1, *2, *3 and *e to those of the session. Requests of different
+   sessions run at the same time.
+*/
+
+type (
+	nreplMsg map[string]interface{}
+
+	nreplConn struct {
+		conn net.Conn
+		lock sync.Mutex
+	}
+
+	nreplSession struct {
+		id      string
+		ns      *Namespace
+		history replHistory
+		queue   chan nreplRequest
+		lock    sync.Mutex
+		running string // id of the message being evaluated, if any
+		// The evaluation of the running message, if it has begun.
+		evaluation *Evaluation
+		closed     bool
+	}
+
+	nreplRequest struct {
+		msg  nreplMsg
+		conn *nreplConn
+	}
+
+	nreplServer struct {
+		sessions    map[string]*nreplSession
+		lock        sync.Mutex
+		nextId      int
+		replContext *ReplContext
+	}
+
+	// nreplWriter forwards everything written to it to the client
+	// as "out" or "err" messages in reply to the request being evaluated.
+	nreplWriter struct {
+		req  nreplRequest
+		key  string
+		sess *nreplSession
+	}
+)
+
+var nreplOps = []string{"clone", "close", "complete", "describe", "eval", "info", "interrupt", "load-file", "ls-sessions"}
+
+func (m nreplMsg) str(key string) string {
+	if s, ok := m[key].(string); ok {
+		return s
+	}
+	return ""
+}
+
+func (c *nreplConn) send(msg nreplMsg) {
+	var b bytes.Buffer
+	bencodeWrite(&b, map[string]interface{}(msg))
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.conn.Write(b.Bytes())
+}
+
+func (req nreplRequest) reply(sess *nreplSession, msg nreplMsg) {
+	if id := req.msg.str("id"); id != "" {
+		msg["id"] = id
+	}
+	if sess != nil {
+		msg["session"] = sess.id
+	} else if s := req.msg.str("session"); s != "" {
+		msg["session"] = s
+	}
+	req.conn.send(msg)
+}
+
+func (req nreplRequest) done(sess *nreplSession, status ...string) {
+	req.reply(sess, nreplMsg{"status": append(status, "done")})
+}
+
+func (w *nreplWriter) Write(p []byte) (int, error) {
+	w.req.reply(w.sess, nreplMsg{w.key: string(p)})
+	return len(p), nil
+}
+
+func (srv *nreplServer) newSession(from *nreplSession) *nreplSession {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	srv.nextId++
+	sess := &nreplSession{
+		id:    fmt.Sprintf("joker-nrepl-session-%d", srv.nextId),
+		ns:    GLOBAL_ENV.FindNamespace(MakeSymbol("user")),
+		queue: make(chan nreplRequest, 64),
+		history: replHistory{
+			first:  NIL,
+			second: NIL,
+			third:  NIL,
+			exc:    NIL,
+		},
+	}
+	if from != nil {
+		from.lock.Lock()
+		sess.ns = from.ns
+		sess.history = from.history
+		from.lock.Unlock()
+	}
+	srv.sessions[sess.id] = sess
+	go srv.runSession(sess)
+	return sess
+}
+
+func (srv *nreplServer) findSession(id string) *nreplSession {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	return srv.sessions[id]
+}
+
+func (srv *nreplServer) closeSession(sess *nreplSession) {
+	srv.lock.Lock()
+	delete(srv.sessions, sess.id)
+	srv.lock.Unlock()
+	sess.lock.Lock()
+	defer sess.lock.Unlock()
+	if !sess.closed {
+		sess.closed = true
+		// Wake up the session's goroutine once its queue is drained.
+		go func() { sess.queue <- nreplRequest{} }()
+	}
+}
+
+func (srv *nreplServer) runSession(sess *nreplSession) {
+	for req := range sess.queue {
+		if req.msg == nil {
+			return
+		}
+		sess.lock.Lock()
+		sess.running = req.msg.str("id")
+		sess.lock.Unlock()
+
+		switch req.msg.str("op") {
+		case "eval":
+			srv.eval(sess, req, req.msg.str("code"), req.msg.str("file"), false)
+		case "load-file":
+			name := req.msg.str("file-path")
+			if name == "" {
+				name = req.msg.str("file-name")
+			}
+			srv.eval(sess, req, req.msg.str("file"), name, true)
+		}
+
+		sess.lock.Lock()
+		sess.running = ""
+		sess.lock.Unlock()
+	}
+}
+
+// evalForm reads and evaluates the next form, returning its printed
+// value or the error it threw. eof is set when there are no more forms.
+func (srv *nreplServer) evalForm(reader *Reader, parseContext *ParseContext) (value, errMsg string, eof bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if err, ok := r.(Error); ok {
+				srv.replContext.PushException(err)
+			}
+			errMsg = fmt.Sprintln(r)
+		}
+	}()
+	obj, err := TryRead(reader)
+	if err == io.EOF {
+		return "", "", true
+	}
+	if err != nil {
+		return "", fmt.Sprintln(err), false
+	}
+	res := Eval(Parse(obj, parseContext), nil)
+	srv.replContext.PushValue(res)
+	var b bytes.Buffer
+	PrintObject(res, &b)
+	return b.String(), "", false
+}
+
+// eval evaluates code in the context of sess, sending a value message for
+// each top-level form (or only for the last one if lastValueOnly is set).
+func (srv *nreplServer) eval(sess *nreplSession, req nreplRequest, code string, filename string, lastValueOnly bool) {
+	sess.lock.Lock()
+	ns := sess.ns
+	history := sess.history
+	sess.lock.Unlock()
+	if nsName := req.msg.str("ns"); nsName != "" {
+		if n := GLOBAL_ENV.FindNamespace(MakeSymbol(nsName)); n != nil {
+			ns = n
+		}
+	}
+	// The evaluation binds the standard streams, the current namespace
+	// and *1, *2, *3 and *e for this goroutine (and the ones it starts)
+	// only, so sessions can evaluate at the same time.
+	evaluation := GLOBAL_ENV.BeginEvaluation(MakeBufferedReader(strings.NewReader("")),
+		MakeIOWriter(&nreplWriter{req: req, key: "out", sess: sess}),
+		MakeIOWriter(&nreplWriter{req: req, key: "err", sess: sess}),
+		ns)
+	defer evaluation.End()
+	srv.replContext.bind(evaluation, history)
+	sess.lock.Lock()
+	sess.evaluation = evaluation
+	sess.lock.Unlock()
+	defer func() {
+		sess.lock.Lock()
+		sess.history = srv.replContext.save()
+		sess.ns = GLOBAL_ENV.CurrentNamespace()
+		sess.evaluation = nil
+		sess.lock.Unlock()
+	}()
+
+	if filename == "" {
+		filename = "<nrepl>"
+	}
+	reader := NewReader(strings.NewReader(code), filename)
+	parseContext := &ParseContext{GlobalEnv: GLOBAL_ENV}
+	status := []string{}
+	lastValue := ""
+	for {
+		value, errMsg, eof := srv.evalForm(reader, parseContext)
+		if errMsg != "" {
+			req.reply(sess, nreplMsg{"err": errMsg})
+			req.reply(sess, nreplMsg{
+				"ex":      "class joker.core/Error",
+				"root-ex": "class joker.core/Error",
+				"status":  []string{"eval-error"},
+			})
+			if strings.Contains(errMsg, "Evaluation interrupted") {
+				status = append(status, "interrupted")
+			}
+			break
+		}
+		if eof {
+			if lastValueOnly {
+				req.reply(sess, nreplMsg{"value": lastValue, "ns": GLOBAL_ENV.CurrentNamespace().Name.ToString(false)})
+			}
+			break
+		}
+		lastValue = value
+		if !lastValueOnly {
+			req.reply(sess, nreplMsg{"value": lastValue, "ns": GLOBAL_ENV.CurrentNamespace().Name.ToString(false)})
+		}
+	}
+	req.done(sess, status...)
+}
+
+func (srv *nreplServer) describe(req nreplRequest) {
+	ops := map[string]interface{}{}
+	for _, op := range nreplOps {
+		ops[op] = map[string]interface{}{}
+	}
+	v := strings.Split(VERSION[1:], ".")
+	req.reply(nil, nreplMsg{
+		"ops": ops,
+		"versions": map[string]interface{}{
+			"joker": map[string]interface{}{
+				"major":          v[0],
+				"minor":          v[1],
+				"incremental":    v[2],
+				"version-string": VERSION,
+			},
+			"nrepl": map[string]interface{}{
+				"major":          "1",
+				"minor":          "0",
+				"incremental":    "0",
+				"version-string": "1.0.0",
+			},
+		},
+		"aux": map[string]interface{}{
+			"current-ns": "user",
+		},
+		"status": []string{"done"},
+	})
+}
+
+func (srv *nreplServer) requestNamespace(sess *nreplSession, req nreplRequest) *Namespace {
+	if nsName := req.msg.str("ns"); nsName != "" {
+		if ns := GLOBAL_ENV.FindNamespace(MakeSymbol(nsName)); ns != nil {
+			return ns
+		}
+	}
+	if sess != nil {
+		sess.lock.Lock()
+		defer sess.lock.Unlock()
+		return sess.ns
+	}
+	return GLOBAL_ENV.FindNamespace(MakeSymbol("user"))
+}
+
+func varCandidateType(vr *Var) string {
+	meta := vr.GetMeta()
+	if meta != nil {
+		if ok, m := meta.Get(MakeKeyword("macro")); ok && ToBool(m) {
+			return "macro"
+		}
+	}
+	switch vr.Resolve().(type) {
+	case Callable:
+		return "function"
+	}
+	return "var"
+}
+
+func (srv *nreplServer) complete(sess *nreplSession, req nreplRequest) {
+	prefix := req.msg.str("prefix")
+	if prefix == "" {
+		prefix = req.msg.str("symbol")
+	}
+	ns := srv.requestNamespace(sess, req)
+	candidates := []interface{}{}
+	if i := strings.IndexRune(prefix, '/'); i > 0 {
+		nsName, name := prefix[:i], prefix[i+1:]
+		if target := GLOBAL_ENV.NamespaceFor(ns, MakeSymbol(nsName+"/"+name)); target != nil {
+			for k, vr := range target.Mappings() {
+				if strings.HasPrefix(*k, name) && vr.Name() == target.Name.ToString(false)+"/"+*k {
+					candidates = append(candidates, map[string]interface{}{
+						"candidate": nsName + "/" + *k,
+						"ns":        target.Name.ToString(false),
+						"type":      varCandidateType(vr),
+					})
+				}
+			}
+		}
+	} else {
+		for k, vr := range ns.Mappings() {
+			if strings.HasPrefix(*k, prefix) {
+				candidates = append(candidates, map[string]interface{}{
+					"candidate": *k,
+					"ns":        strings.SplitN(vr.Name(), "/", 2)[0],
+					"type":      varCandidateType(vr),
+				})
+			}
+		}
+		for k := range GLOBAL_ENV.AllNamespaces() {
+			if strings.HasPrefix(*k, prefix) {
+				candidates = append(candidates, map[string]interface{}{"candidate": *k, "type": "namespace"})
+			}
+		}
+		for k := range ns.Aliases() {
+			if strings.HasPrefix(*k, prefix) {
+				candidates = append(candidates, map[string]interface{}{"candidate": *k, "type": "namespace"})
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].(map[string]interface{})["candidate"].(string) < candidates[j].(map[string]interface{})["candidate"].(string)
+	})
+	req.reply(sess, nreplMsg{"completions": candidates, "status": []string{"done"}})
+}
+
+func (srv *nreplServer) info(sess *nreplSession, req nreplRequest) {
+	sym := req.msg.str("sym")
+	if sym == "" {
+		sym = req.msg.str("symbol")
+	}
+	vr, ok := GLOBAL_ENV.ResolveIn(srv.requestNamespace(sess, req), MakeSymbol(sym))
+	if sym == "" || !ok {
+		req.done(sess, "no-info")
+		return
+	}
+	parts := strings.SplitN(vr.Name(), "/", 2)
+	res := nreplMsg{
+		"ns":     parts[0],
+		"name":   parts[1],
+		"status": []string{"done"},
+	}
+	if meta := vr.GetMeta(); meta != nil {
+		for _, key := range []string{"doc", "file", "added"} {
+			if ok, v := meta.Get(MakeKeyword(key)); ok && !v.Equals(NIL) {
+				res[key] = v.ToString(false)
+			}
+		}
+		for _, key := range []string{"line", "column"} {
+			if ok, v := meta.Get(MakeKeyword(key)); ok {
+				if i, ok := v.(Int); ok {
+					res[key] = i.I
+				}
+			}
+		}
+		if ok, v := meta.Get(MakeKeyword("arglists")); ok && !v.Equals(NIL) {
+			var b bytes.Buffer
+			for s := v.(Seqable).Seq(); !s.IsEmpty(); s = s.Rest() {
+				if b.Len() > 0 {
+					b.WriteString("\n")
+				}
+				b.WriteString(s.First().ToString(true))
+			}
+			res["arglists-str"] = b.String()
+		}
+		if ok, v := meta.Get(MakeKeyword("macro")); ok && ToBool(v) {
+			res["macro"] = "true"
+		}
+	}
+	req.reply(sess, res)
+}
+
+func (srv *nreplServer) interrupt(sess *nreplSession, req nreplRequest) {
+	sess.lock.Lock()
+	defer sess.lock.Unlock()
+	target := req.msg.str("interrupt-id")
+	if sess.evaluation == nil || (target != "" && target != sess.running) {
+		req.done(sess, "session-idle")
+		return
+	}
+	sess.evaluation.Interrupt()
+	req.done(sess, "interrupted")
+}
+
+func (srv *nreplServer) handle(req nreplRequest) {
+	op := req.msg.str("op")
+	var sess *nreplSession
+	if id := req.msg.str("session"); id != "" {
+		if sess = srv.findSession(id); sess == nil {
+			req.done(nil, "error", "unknown-session")
+			return
+		}
+	}
+	switch op {
+	case "clone":
+		newSess := srv.newSession(sess)
+		req.reply(sess, nreplMsg{"new-session": newSess.id, "status": []string{"done"}})
+	case "close":
+		if sess != nil {
+			srv.closeSession(sess)
+		}
+		req.done(sess, "session-closed")
+	case "describe":
+		srv.describe(req)
+	case "ls-sessions":
+		srv.lock.Lock()
+		ids := []string{}
+		for id := range srv.sessions {
+			ids = append(ids, id)
+		}
+		srv.lock.Unlock()
+		sort.Strings(ids)
+		req.reply(sess, nreplMsg{"sessions": ids, "status": []string{"done"}})
+	case "eval", "load-file":
+		if sess == nil {
+			// Sessionless evaluation uses a throwaway session.
+			sess = srv.newSession(nil)
+			defer srv.closeSession(sess)
+		}
+		sess.lock.Lock()
+		closed := sess.closed
+		sess.lock.Unlock()
+		if !closed {
+			sess.queue <- req
+		}
+	case "interrupt":
+		if sess == nil {
+			req.done(nil, "error", "unknown-session")
+			return
+		}
+		srv.interrupt(sess, req)
+	case "complete", "completions":
+		srv.complete(sess, req)
+	case "info", "lookup":
+		srv.info(sess, req)
+	default:
+		req.done(sess, "error", "unknown-op")
+	}
+}
+
+func (srv *nreplServer) serve(conn net.Conn) {
+	c := &nreplConn{conn: conn}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		v, err := bencodeRead(r)
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintf(Stderr, "nREPL client %s: %s\n", conn.RemoteAddr(), err.Error())
+			}
+			return
+		}
+		msg, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		srv.handle(nreplRequest{msg: nreplMsg(msg), conn: c})
+	}
+}
+
+func newNreplServer() *nreplServer {
+	ProcessReplData()
+	GLOBAL_ENV.FindNamespace(MakeSymbol("user")).ReferAll(GLOBAL_ENV.FindNamespace(MakeSymbol("joker.repl")))
+	return &nreplServer{
+		sessions:    map[string]*nreplSession{},
+		replContext: NewReplContext(GLOBAL_ENV),
+	}
+}
+
+func nrepl(addr string) {
+	srv := newNreplServer()
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Fprintf(Stderr, "Cannot start nREPL server listening on %s: %s\n", addr, err.Error())
+		ExitJoker(18)
+	}
+	defer l.Close()
+
+	fmt.Printf("nREPL server started on port %d on host %s - nrepl://%s\n",
+		l.Addr().(*net.TCPAddr).Port, l.Addr().(*net.TCPAddr).IP, l.Addr())
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			fmt.Fprintf(Stderr, "Cannot accept nREPL client on %s: %s\n", l.Addr(), err.Error())
+			return
+		}
+		go srv.serve(conn)
+	}
+}
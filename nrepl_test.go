@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/candid82/joker/core"
+)
+
+func TestMain(m *testing.M) {
+	GLOBAL_ENV.InitEnv(Stdin, Stdout, Stderr, nil)
+	ProcessCoreData()
+	GLOBAL_ENV.ReferCoreToUser()
+	os.Exit(m.Run())
+}
+
+func TestBencodeRoundTrip(t *testing.T) {
+	for _, v := range []interface{}{
+		int64(0),
+		int64(-42),
+		"",
+		"hello",
+		"héllo\nwörld",
+		[]interface{}{},
+		[]interface{}{"a", int64(1), []interface{}{"b"}},
+		map[string]interface{}{},
+		map[string]interface{}{"op": "eval", "id": "1", "nested": map[string]interface{}{"x": []interface{}{int64(2)}}},
+	} {
+		var b bytes.Buffer
+		bencodeWrite(&b, v)
+		res, err := bencodeRead(bufio.NewReader(&b))
+		if err != nil {
+			t.Errorf("%#v: %s", v, err)
+			continue
+		}
+		if !reflect.DeepEqual(v, res) {
+			t.Errorf("expected %#v, got %#v", v, res)
+		}
+	}
+}
+
+func TestBencodeWrite(t *testing.T) {
+	for _, c := range []struct {
+		v   interface{}
+		enc string
+	}{
+		{5, "i5e"},
+		{true, "i1e"},
+		{"spam", "4:spam"},
+		{[]string{"a", "bc"}, "l1:a2:bce"},
+		{map[string]interface{}{"b": 1, "a": "x"}, "d1:a1:x1:bi1ee"},
+	} {
+		var b bytes.Buffer
+		bencodeWrite(&b, c.v)
+		if b.String() != c.enc {
+			t.Errorf("%#v: expected %q, got %q", c.v, c.enc, b.String())
+		}
+	}
+}
+
+func TestBencodeReadErrors(t *testing.T) {
+	for _, enc := range []string{
+		"",
+		"x",
+		"i12",
+		"4:spa",
+		"-1:a",
+		"99999999999:",
+		"123456789012345678901234567890:",
+		"di1ei2ee",
+		"l1:a",
+	} {
+		if v, err := bencodeRead(bufio.NewReader(strings.NewReader(enc))); err == nil {
+			t.Errorf("%q: expected an error, got %#v", enc, v)
+		}
+	}
+}
+
+type nreplClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func startNrepl(t *testing.T) *nreplClient {
+	srv := newNreplServer()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		l.Close()
+	})
+	return &nreplClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *nreplClient) send(msg map[string]interface{}) {
+	var b bytes.Buffer
+	bencodeWrite(&b, msg)
+	if _, err := c.conn.Write(b.Bytes()); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *nreplClient) read() nreplMsg {
+	c.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	v, err := bencodeRead(c.r)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return nreplMsg(v.(map[string]interface{}))
+}
+
+func hasStatus(msg nreplMsg, status string) bool {
+	if s, ok := msg["status"].([]interface{}); ok {
+		for _, e := range s {
+			if e == status {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// request sends msg and returns the replies to it, up to
+// the one with the "done" status.
+func (c *nreplClient) request(msg map[string]interface{}) []nreplMsg {
+	c.send(msg)
+	var res []nreplMsg
+	for {
+		reply := c.read()
+		if reply.str("id") != msg["id"] {
+			c.t.Fatalf("unexpected reply %#v to %#v", reply, msg)
+		}
+		res = append(res, reply)
+		if hasStatus(reply, "done") {
+			return res
+		}
+	}
+}
+
+// clone returns a new session, which is a copy of from,
+// if it's not empty.
+func (c *nreplClient) clone(from string) string {
+	msg := map[string]interface{}{"op": "clone", "id": "clone"}
+	if from != "" {
+		msg["session"] = from
+	}
+	replies := c.request(msg)
+	id := replies[0].str("new-session")
+	if id == "" {
+		c.t.Fatalf("no new session in %#v", replies)
+	}
+	return id
+}
+
+// values returns the values and output in replies.
+func values(replies []nreplMsg) (vals []string, out string) {
+	for _, r := range replies {
+		if v, ok := r["value"].(string); ok {
+			vals = append(vals, v)
+		}
+		out += r.str("out") + r.str("err")
+	}
+	return
+}
+
+// interrupt interrupts the evaluation of message id in sess and
+// returns the last reply to that message.
+func (c *nreplClient) interrupt(sess, id string) nreplMsg {
+	// Interrupt until the evaluation has started. The replies to
+	// both requests may arrive in any order.
+	var last nreplMsg
+	for interrupted := false; !interrupted; {
+		c.send(map[string]interface{}{"op": "interrupt", "id": "interrupt", "session": sess, "interrupt-id": id})
+		for {
+			reply := c.read()
+			if reply.str("id") == id {
+				last = reply
+				continue
+			}
+			interrupted = hasStatus(reply, "interrupted")
+			break
+		}
+		if !interrupted {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	for last == nil || !hasStatus(last, "done") {
+		last = c.read()
+		if last.str("id") != id {
+			c.t.Fatalf("unexpected reply %#v", last)
+		}
+	}
+	return last
+}
+
+func TestNreplDescribe(t *testing.T) {
+	c := startNrepl(t)
+	replies := c.request(map[string]interface{}{"op": "describe", "id": "1"})
+	ops, ok := replies[0]["ops"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("no ops in %#v", replies[0])
+	}
+	for _, op := range nreplOps {
+		if _, ok := ops[op]; !ok {
+			t.Errorf("op %s is not described", op)
+		}
+	}
+	versions := replies[0]["versions"].(map[string]interface{})
+	if versions["joker"].(map[string]interface{})["version-string"] != VERSION {
+		t.Errorf("unexpected versions %#v", versions)
+	}
+}
+
+func TestNreplEval(t *testing.T) {
+	c := startNrepl(t)
+	sess := c.clone("")
+	vals, out := values(c.request(map[string]interface{}{
+		"op": "eval", "id": "1", "session": sess,
+		"code": "(println \"hi\") (def x 40) (+ x 2)",
+	}))
+	if !reflect.DeepEqual(vals, []string{"nil", "#'user/x", "42"}) || out != "hi\n" {
+		t.Errorf("unexpected values %q and output %q", vals, out)
+	}
+
+	vals, out = values(c.request(map[string]interface{}{
+		"op": "eval", "id": "2", "session": sess,
+		"code": "@(future (print \"from a future\") 1)",
+	}))
+	if !reflect.DeepEqual(vals, []string{"1"}) || out != "from a future" {
+		t.Errorf("unexpected values %q and output %q of a future", vals, out)
+	}
+
+	c.request(map[string]interface{}{"op": "eval", "id": "2", "session": sess, "code": "(ns nrepl-test.a)"})
+	replies := c.request(map[string]interface{}{"op": "eval", "id": "3", "session": sess, "code": "*ns*"})
+	if vals, _ := values(replies); len(vals) != 1 || vals[0] != `#object[Namespace "nrepl-test.a"]` || replies[0].str("ns") != "nrepl-test.a" {
+		t.Errorf("the session's namespace isn't kept: %#v", replies)
+	}
+	other := c.clone(sess)
+	if vals, _ := values(c.request(map[string]interface{}{"op": "eval", "id": "4", "session": other, "code": "(str *ns*)"})); vals[0] != `"nrepl-test.a"` {
+		t.Errorf("a cloned session doesn't share its namespace: %q", vals)
+	}
+
+	replies = c.request(map[string]interface{}{"op": "eval", "id": "5", "session": sess, "code": "(/ 1 0)"})
+	if _, out := values(replies); !strings.Contains(out, "Division by zero") || !hasStatus(replies[len(replies)-2], "eval-error") {
+		t.Errorf("unexpected error replies %#v", replies)
+	}
+	if vals, _ := values(c.request(map[string]interface{}{"op": "eval", "id": "6", "session": sess, "code": "(some? *e)"})); vals[0] != "true" {
+		t.Errorf("*e is not set: %q", vals)
+	}
+}
+
+func TestNreplInterrupt(t *testing.T) {
+	c := startNrepl(t)
+	sess := c.clone("")
+	replies := c.request(map[string]interface{}{"op": "interrupt", "id": "1", "session": sess})
+	if !hasStatus(replies[0], "session-idle") {
+		t.Errorf("expected an idle session: %#v", replies)
+	}
+
+	c.send(map[string]interface{}{"op": "eval", "id": "2", "session": sess, "code": "(loop [] (recur))"})
+	if last := c.interrupt(sess, "2"); !hasStatus(last, "interrupted") {
+		t.Errorf("evaluation was not interrupted: %#v", last)
+	}
+	if vals, _ := values(c.request(map[string]interface{}{"op": "eval", "id": "4", "session": sess, "code": "(+ 1 2)"})); vals[0] != "3" {
+		t.Errorf("session doesn't evaluate after interrupt: %q", vals)
+	}
+}
+
+func TestNreplClose(t *testing.T) {
+	c := startNrepl(t)
+	sess := c.clone("")
+	replies := c.request(map[string]interface{}{"op": "ls-sessions", "id": "1"})
+	if !reflect.DeepEqual(replies[0]["sessions"], []interface{}{sess}) {
+		t.Errorf("unexpected sessions %#v", replies[0])
+	}
+	replies = c.request(map[string]interface{}{"op": "close", "id": "2", "session": sess})
+	if !hasStatus(replies[0], "session-closed") {
+		t.Errorf("session was not closed: %#v", replies)
+	}
+	replies = c.request(map[string]interface{}{"op": "eval", "id": "3", "session": sess, "code": "1"})
+	if !hasStatus(replies[0], "unknown-session") {
+		t.Errorf("closed session still evaluates: %#v", replies)
+	}
+	replies = c.request(map[string]interface{}{"op": "ls-sessions", "id": "4"})
+	if !reflect.DeepEqual(replies[0]["sessions"], []interface{}{}) {
+		t.Errorf("unexpected sessions %#v", replies[0])
+	}
+}
+
+func TestNreplInterruptOnlyEvaluation(t *testing.T) {
+	c := startNrepl(t)
+	sess := c.clone("")
+	c.request(map[string]interface{}{
+		"op": "eval", "id": "1", "session": sess,
+		"code": "(def p (promise)) (def f (future (loop [] (if (realized? p) :done (recur)))))",
+	})
+	c.send(map[string]interface{}{"op": "eval", "id": "2", "session": sess, "code": "(loop [] (recur))"})
+	if last := c.interrupt(sess, "2"); !hasStatus(last, "interrupted") {
+		t.Errorf("evaluation was not interrupted: %#v", last)
+	}
+	if vals, _ := values(c.request(map[string]interface{}{"op": "eval", "id": "3", "session": sess, "code": "(deliver p 1) @f"})); vals[1] != ":done" {
+		t.Errorf("interrupt aborted a future: %q", vals)
+	}
+}
+
+func TestNreplEvalBindings(t *testing.T) {
+	c := startNrepl(t)
+	sess := c.clone("")
+	c.request(map[string]interface{}{"op": "eval", "id": "1", "session": sess, "code": "(ns nrepl-test.b)"})
+	stdin, stdout, stderr := GLOBAL_ENV.StdIO()
+	ns := GLOBAL_ENV.CurrentNamespace()
+
+	c.send(map[string]interface{}{"op": "eval", "id": "2", "session": sess, "code": "(println \"started\") (loop [] (recur))"})
+	for out := ""; !strings.Contains(out, "started"); {
+		out += c.read().str("out")
+	}
+	if in, out, err := GLOBAL_ENV.StdIO(); in != stdin || out != stdout || err != stderr {
+		t.Errorf("the evaluation changed the standard streams of other goroutines")
+	}
+	if GLOBAL_ENV.CurrentNamespace() != ns {
+		t.Errorf("the evaluation changed the namespace of other goroutines to %s", GLOBAL_ENV.CurrentNamespace().ToString(false))
+	}
+	c.interrupt(sess, "2")
+}
+
+func TestNreplConcurrentSessions(t *testing.T) {
+	c := startNrepl(t)
+	a := c.clone("")
+	b := c.clone("")
+	c.request(map[string]interface{}{"op": "eval", "id": "1", "session": a, "code": ":a"})
+	c.request(map[string]interface{}{"op": "eval", "id": "2", "session": b, "code": ":b"})
+
+	c.send(map[string]interface{}{"op": "eval", "id": "3", "session": a, "code": "(do (println \"started\") (loop [] (recur)))"})
+	for out := ""; !strings.Contains(out, "started\n"); {
+		out += c.read().str("out")
+	}
+	if vals, _ := values(c.request(map[string]interface{}{"op": "eval", "id": "4", "session": b, "code": "*1"})); vals[0] != ":b" {
+		t.Errorf("a session doesn't have its own *1, or waits for another one: %q", vals)
+	}
+	c.interrupt(a, "3")
+	if vals, _ := values(c.request(map[string]interface{}{"op": "eval", "id": "5", "session": a, "code": "*1"})); vals[0] != ":a" {
+		t.Errorf("another session changed *1: %q", vals)
+	}
+}
@@ -13,8 +13,8 @@ func __by_prefix_(_args []Object) Object {
 	_c := len(_args)
 	switch {
 	case _c == 3:
-		db := ExtractBoltDB(_args, 0)
-		bucket := ExtractString(_args, 1)
+		db := ExtractObject(_args, 0)
+		bucket := ExtractObject(_args, 1)
 		prefix := ExtractString(_args, 2)
 		_res := byPrefix(db, bucket, prefix)
 		return _res
@@ -49,8 +49,8 @@ func __create_bucket_(_args []Object) Object {
 	_c := len(_args)
 	switch {
 	case _c == 2:
-		db := ExtractBoltDB(_args, 0)
-		name := ExtractString(_args, 1)
+		db := ExtractObject(_args, 0)
+		name := ExtractObject(_args, 1)
 		_res := createBucket(db, name)
 		return _res
 
@@ -67,8 +67,8 @@ func __create_bucket_if_not_exists_(_args []Object) Object {
 	_c := len(_args)
 	switch {
 	case _c == 2:
-		db := ExtractBoltDB(_args, 0)
-		name := ExtractString(_args, 1)
+		db := ExtractObject(_args, 0)
+		name := ExtractObject(_args, 1)
 		_res := createBucketIfNotExists(db, name)
 		return _res
 
@@ -78,6 +78,24 @@ func __create_bucket_if_not_exists_(_args []Object) Object {
 	return NIL
 }
 
+var __cursor__P ProcFn = __cursor_
+var cursor_ Proc = Proc{Fn: __cursor__P, Name: "cursor_", Package: "std/bolt"}
+
+func __cursor_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 2:
+		tx := ExtractBoltTx(_args, 0)
+		bucket := ExtractObject(_args, 1)
+		_res := newCursor(tx, bucket)
+		return MakeBoltCursor(_res)
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
 var __delete__P ProcFn = __delete_
 var delete_ Proc = Proc{Fn: __delete__P, Name: "delete_", Package: "std/bolt"}
 
@@ -85,8 +103,8 @@ func __delete_(_args []Object) Object {
 	_c := len(_args)
 	switch {
 	case _c == 3:
-		db := ExtractBoltDB(_args, 0)
-		bucket := ExtractString(_args, 1)
+		db := ExtractObject(_args, 0)
+		bucket := ExtractObject(_args, 1)
 		key := ExtractString(_args, 2)
 		_res := delete(db, bucket, key)
 		return _res
@@ -104,8 +122,8 @@ func __delete_bucket_(_args []Object) Object {
 	_c := len(_args)
 	switch {
 	case _c == 2:
-		db := ExtractBoltDB(_args, 0)
-		name := ExtractString(_args, 1)
+		db := ExtractObject(_args, 0)
+		name := ExtractObject(_args, 1)
 		_res := deleteBucket(db, name)
 		return _res
 
@@ -115,6 +133,23 @@ func __delete_bucket_(_args []Object) Object {
 	return NIL
 }
 
+var __first__P ProcFn = __first_
+var first_ Proc = Proc{Fn: __first__P, Name: "first_", Package: "std/bolt"}
+
+func __first_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 1:
+		cursor := ExtractBoltCursor(_args, 0)
+		_res := cursorFirst(cursor)
+		return _res
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
 var __get__P ProcFn = __get_
 var get_ Proc = Proc{Fn: __get__P, Name: "get_", Package: "std/bolt"}
 
@@ -122,8 +157,8 @@ func __get_(_args []Object) Object {
 	_c := len(_args)
 	switch {
 	case _c == 3:
-		db := ExtractBoltDB(_args, 0)
-		bucket := ExtractString(_args, 1)
+		db := ExtractObject(_args, 0)
+		bucket := ExtractObject(_args, 1)
 		key := ExtractString(_args, 2)
 		_res := get(db, bucket, key)
 		return _res
@@ -134,6 +169,40 @@ func __get_(_args []Object) Object {
 	return NIL
 }
 
+var __last__P ProcFn = __last_
+var last_ Proc = Proc{Fn: __last__P, Name: "last_", Package: "std/bolt"}
+
+func __last_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 1:
+		cursor := ExtractBoltCursor(_args, 0)
+		_res := cursorLast(cursor)
+		return _res
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
+var __next__P ProcFn = __next_
+var next_ Proc = Proc{Fn: __next__P, Name: "next_", Package: "std/bolt"}
+
+func __next_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 1:
+		cursor := ExtractBoltCursor(_args, 0)
+		_res := cursorNext(cursor)
+		return _res
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
 var __next_sequence__P ProcFn = __next_sequence_
 var next_sequence_ Proc = Proc{Fn: __next_sequence__P, Name: "next_sequence_", Package: "std/bolt"}
 
@@ -141,8 +210,8 @@ func __next_sequence_(_args []Object) Object {
 	_c := len(_args)
 	switch {
 	case _c == 2:
-		db := ExtractBoltDB(_args, 0)
-		bucket := ExtractString(_args, 1)
+		db := ExtractObject(_args, 0)
+		bucket := ExtractObject(_args, 1)
 		_res := nextSequence(db, bucket)
 		return _res
 
@@ -161,7 +230,14 @@ func __open_(_args []Object) Object {
 	case _c == 2:
 		filename := ExtractString(_args, 0)
 		mode := ExtractInt(_args, 1)
-		_res := open(filename, mode)
+		_res := open(filename, mode, EmptyArrayMap())
+		return MakeBoltDB(_res)
+
+	case _c == 3:
+		filename := ExtractString(_args, 0)
+		mode := ExtractInt(_args, 1)
+		opts := ExtractMap(_args, 2)
+		_res := open(filename, mode, opts)
 		return MakeBoltDB(_res)
 
 	default:
@@ -170,6 +246,23 @@ func __open_(_args []Object) Object {
 	return NIL
 }
 
+var __prev__P ProcFn = __prev_
+var prev_ Proc = Proc{Fn: __prev__P, Name: "prev_", Package: "std/bolt"}
+
+func __prev_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 1:
+		cursor := ExtractBoltCursor(_args, 0)
+		_res := cursorPrev(cursor)
+		return _res
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
 var __put__P ProcFn = __put_
 var put_ Proc = Proc{Fn: __put__P, Name: "put_", Package: "std/bolt"}
 
@@ -177,10 +270,10 @@ func __put_(_args []Object) Object {
 	_c := len(_args)
 	switch {
 	case _c == 4:
-		db := ExtractBoltDB(_args, 0)
-		bucket := ExtractString(_args, 1)
+		db := ExtractObject(_args, 0)
+		bucket := ExtractObject(_args, 1)
 		key := ExtractString(_args, 2)
-		value := ExtractString(_args, 3)
+		value := ExtractObject(_args, 3)
 		_res := put(db, bucket, key, value)
 		return _res
 
@@ -190,6 +283,104 @@ func __put_(_args []Object) Object {
 	return NIL
 }
 
+var __scan__P ProcFn = __scan_
+var scan_ Proc = Proc{Fn: __scan__P, Name: "scan_", Package: "std/bolt"}
+
+func __scan_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 2:
+		tx := ExtractBoltTx(_args, 0)
+		bucket := ExtractObject(_args, 1)
+		_res := scan(tx, bucket, EmptyArrayMap())
+		return _res
+
+	case _c == 3:
+		tx := ExtractBoltTx(_args, 0)
+		bucket := ExtractObject(_args, 1)
+		opts := ExtractMap(_args, 2)
+		_res := scan(tx, bucket, opts)
+		return _res
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
+var __seek__P ProcFn = __seek_
+var seek_ Proc = Proc{Fn: __seek__P, Name: "seek_", Package: "std/bolt"}
+
+func __seek_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 2:
+		cursor := ExtractBoltCursor(_args, 0)
+		key := ExtractString(_args, 1)
+		_res := cursorSeek(cursor, key)
+		return _res
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
+var __update__P ProcFn = __update_
+var update_ Proc = Proc{Fn: __update__P, Name: "update_", Package: "std/bolt"}
+
+func __update_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 2:
+		db := ExtractObject(_args, 0)
+		f := ExtractCallable(_args, 1)
+		_res := withTx(db, true, f)
+		return _res
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
+var __view__P ProcFn = __view_
+var view_ Proc = Proc{Fn: __view__P, Name: "view_", Package: "std/bolt"}
+
+func __view_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 2:
+		db := ExtractObject(_args, 0)
+		f := ExtractCallable(_args, 1)
+		_res := withTx(db, false, f)
+		return _res
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
+var __with_tx__P ProcFn = __with_tx_
+var with_tx_ Proc = Proc{Fn: __with_tx__P, Name: "with_tx_", Package: "std/bolt"}
+
+func __with_tx_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 3:
+		db := ExtractObject(_args, 0)
+		writable := ExtractBoolean(_args, 1)
+		f := ExtractCallable(_args, 2)
+		_res := withTx(db, writable, f)
+		return _res
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
 func Init() {
 
 	InternsOrThunks()
@@ -14,6 +14,18 @@ func InternsOrThunks() {
 	}
 	boltNamespace.ResetMeta(MakeMeta(nil, `Provide API for Bolt embedded database https://github.com/etcd-io/bbolt.
 
+         Functions that read or write a bucket take either a database, in which
+         case they run in a transaction of their own, or a transaction passed
+         to the function given to update, view or with-tx, so that several of them
+         are committed or rolled back together.
+
+         Buckets are named by strings, and nested buckets by vectors of the names
+         of the buckets leading to them, e.g. ["users" "admins"].
+         Keys are strings. Values are strings as well, unless the database
+         is opened with {:values :edn}, in which case they may be any Joker
+         value that can be written and read back as EDN. Storing any other
+         value (such as a function or an atom) throws.
+
          Example:
 
          user=> (def db (joker.bolt/open "bolt.db" 0600))
@@ -27,7 +39,19 @@ func InternsOrThunks() {
          user=> (joker.bolt/put db "users" (str id) (joker.json/write-string {:id id :name "Joe Black"}))
          nil
          user=> (joker.json/read-string (joker.bolt/get db "users" (str id)))
-         {"id" 1, "name" "Joe Black"}`, "1.0"))
+         {"id" 1, "name" "Joe Black"}
+
+         The same with EDN values, in a single transaction:
+
+         user=> (def db (joker.bolt/open "edn.db" 0600 {:values :edn}))
+         #'user/db
+         user=> (joker.bolt/update db (fn [tx]
+                                        (joker.bolt/create-bucket tx "users")
+                                        (let [id (joker.bolt/next-sequence tx "users")]
+                                          (joker.bolt/put tx "users" (str id) {:id id :name "Joe Black"}))))
+         nil
+         user=> (joker.bolt/view db #(doall (joker.bolt/scan % "users")))
+         (["1" {:id 1, :name "Joe Black"}])`, "1.0"))
 
 	boltNamespace.InternVar("by-prefix", by_prefix_,
 		MakeMeta(
@@ -35,7 +59,8 @@ func InternsOrThunks() {
 			`Retrives key/value pairs for all keys in bucket
   that start with prefix.
   Returns a vector of [key value] tuples. Passing empty prefix
-  will return all key/values in bucket.`, "1.0"))
+  will return all key/values in bucket. Nested buckets
+  are returned with nil values.`, "1.0"))
 
 	boltNamespace.InternVar("close", close_,
 		MakeMeta(
@@ -48,13 +73,23 @@ func InternsOrThunks() {
 		MakeMeta(
 			NewListFrom(NewVectorFrom(MakeSymbol("db"), MakeSymbol("name"))),
 			`Creates a new bucket. Throws an error if the bucket already exists,
-  if the bucket name is blank, or if the bucket name is too long.`, "1.0"))
+  if the bucket name is blank, or if the bucket name is too long.
+  The buckets a nested bucket is created in must exist.`, "1.0"))
 
 	boltNamespace.InternVar("create-bucket-if-not-exists", create_bucket_if_not_exists_,
 		MakeMeta(
 			NewListFrom(NewVectorFrom(MakeSymbol("db"), MakeSymbol("name"))),
-			`Creates a new bucket if it doesn't already exist.
-   Throws an error if the bucket name is blank, or if the bucket name is too long.`, "1.0"))
+			`Creates a new bucket if it doesn't already exist, along with
+  the buckets a nested bucket is created in.
+  Throws an error if the bucket name is blank, or if the bucket name is too long.`, "1.0"))
+
+	boltNamespace.InternVar("cursor", cursor_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("tx"), MakeSymbol("bucket"))),
+			`Returns a cursor over the bucket in transaction tx, for use with
+  first, last, seek, next and prev, which move the cursor and return
+  the [key value] tuple it lands on, or nil if there is none.
+  Nested buckets are returned with nil values.`, "1.0").Plus(MakeKeyword("tag"), String{S: "BoltCursor"}))
 
 	boltNamespace.InternVar("delete", delete_,
 		MakeMeta(
@@ -64,13 +99,29 @@ func InternsOrThunks() {
 	boltNamespace.InternVar("delete-bucket", delete_bucket_,
 		MakeMeta(
 			NewListFrom(NewVectorFrom(MakeSymbol("db"), MakeSymbol("name"))),
-			`Deletes a bucket. Throws an error if the bucket doesn't exist.`, "1.0"))
+			`Deletes a bucket, along with the buckets nested in it.
+  Throws an error if the bucket doesn't exist.`, "1.0"))
+
+	boltNamespace.InternVar("first", first_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("cursor"))),
+			`Moves the cursor to the first key in its bucket and returns its [key value] tuple.`, "1.0"))
 
 	boltNamespace.InternVar("get", get_,
 		MakeMeta(
 			NewListFrom(NewVectorFrom(MakeSymbol("db"), MakeSymbol("bucket"), MakeSymbol("key"))),
 			`Retrieves the value for a key in the bucket.
-  Returns nil if the key does not exist.`, "1.0"))
+  Returns nil if the key does not exist or names a nested bucket.`, "1.0"))
+
+	boltNamespace.InternVar("last", last_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("cursor"))),
+			`Moves the cursor to the last key in its bucket and returns its [key value] tuple.`, "1.0"))
+
+	boltNamespace.InternVar("next", next_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("cursor"))),
+			`Moves the cursor to the next key and returns its [key value] tuple.`, "1.0"))
 
 	boltNamespace.InternVar("next-sequence", next_sequence_,
 		MakeMeta(
@@ -79,11 +130,19 @@ func InternsOrThunks() {
 
 	boltNamespace.InternVar("open", open_,
 		MakeMeta(
-			NewListFrom(NewVectorFrom(MakeSymbol("filename"), MakeSymbol("mode"))),
+			NewListFrom(NewVectorFrom(MakeSymbol("filename"), MakeSymbol("mode")), NewVectorFrom(MakeSymbol("filename"), MakeSymbol("mode"), MakeSymbol("opts"))),
 			`Creates and opens a database at the given path.
   If the file does not exist then it will be created automatically
   with mode perm (before umask).
-  mode is normally passed as an octal literal, e.g. 0600`, "1.0").Plus(MakeKeyword("tag"), String{S: "BoltDB"}))
+  mode is normally passed as an octal literal, e.g. 0600
+  opts is a map with the following optional keys:
+  - values (:string, the default, to store strings as they are,
+    or :edn to store any EDN-serialisable value).`, "1.0").Plus(MakeKeyword("tag"), String{S: "BoltDB"}))
+
+	boltNamespace.InternVar("prev", prev_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("cursor"))),
+			`Moves the cursor to the previous key and returns its [key value] tuple.`, "1.0"))
 
 	boltNamespace.InternVar("put", put_,
 		MakeMeta(
@@ -92,4 +151,42 @@ func InternsOrThunks() {
   If the key exist then its previous value will be overwritten.
   Throws an error if the key is blank, if the key is too large, or if the value is too large.`, "1.0"))
 
+	boltNamespace.InternVar("scan", scan_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("tx"), MakeSymbol("bucket")), NewVectorFrom(MakeSymbol("tx"), MakeSymbol("bucket"), MakeSymbol("opts"))),
+			`Returns a lazy seq of the [key value] tuples of the bucket
+  in transaction tx, in ascending order of keys.
+  The seq must be consumed before the transaction ends.
+  opts is a map with the following optional keys:
+  - start (only keys from start, inclusive)
+  - end (only keys up to end, exclusive)
+  - prefix (only keys that start with prefix)
+  - reverse (if true, return the tuples in descending order of keys).`, "1.0"))
+
+	boltNamespace.InternVar("seek", seek_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("cursor"), MakeSymbol("key"))),
+			`Moves the cursor to the given key, or the next key after it if it
+  does not exist, and returns its [key value] tuple.`, "1.0"))
+
+	boltNamespace.InternVar("update", update_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("db"), MakeSymbol("f"))),
+			`Calls f with a read-write transaction on db. See with-tx.`, "1.0"))
+
+	boltNamespace.InternVar("view", view_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("db"), MakeSymbol("f"))),
+			`Calls f with a read-only transaction on db. See with-tx.`, "1.0"))
+
+	boltNamespace.InternVar("with-tx", with_tx_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("db"), MakeSymbol("writable"), MakeSymbol("f"))),
+			`Begins a transaction on db, read-write if writable is true
+  and read-only otherwise, and calls f with it.
+  Commits the transaction and returns the result of f, unless f throws,
+  in which case the transaction is rolled back and the error rethrown.
+  If db is a transaction already, f is just called with it.
+  The transaction must not be used once f returns.`, "1.0"))
+
 }
@@ -3,6 +3,7 @@ package bolt
 import (
 	"bytes"
 	"os"
+	"strings"
 	"unsafe"
 
 	. "github.com/candid82/joker/core"
@@ -10,16 +11,46 @@ import (
 )
 
 type (
+	boltDB struct {
+		*bolt.DB
+		// Whether values are stored as EDN rather than as strings.
+		edn bool
+	}
+	boltTx struct {
+		*bolt.Tx
+		db *boltDB
+	}
+	boltCursor struct {
+		*bolt.Cursor
+		db *boltDB
+	}
 	// TODO: wrapper types like this can probably be auto generated
 	BoltDB struct {
-		*bolt.DB
+		*boltDB
+		hash uint32
+	}
+	BoltTx struct {
+		*boltTx
+		hash uint32
+	}
+	BoltCursor struct {
+		*boltCursor
 		hash uint32
 	}
+	// bucketParent is what buckets are created in: a Tx or a Bucket.
+	bucketParent interface {
+		Bucket(name []byte) *bolt.Bucket
+		CreateBucket(name []byte) (*bolt.Bucket, error)
+		CreateBucketIfNotExists(name []byte) (*bolt.Bucket, error)
+		DeleteBucket(name []byte) error
+	}
 )
 
 var boltDBType *Type
+var boltTxType *Type
+var boltCursorType *Type
 
-func MakeBoltDB(db *bolt.DB) BoltDB {
+func MakeBoltDB(db *boltDB) BoltDB {
 	res := BoltDB{db, 0}
 	res.hash = HashPtr(uintptr(unsafe.Pointer(db)))
 	return res
@@ -31,7 +62,7 @@ func (db BoltDB) ToString(escape bool) string {
 
 func (db BoltDB) Equals(other interface{}) bool {
 	if otherDb, ok := other.(BoltDB); ok {
-		return db.DB == otherDb.DB
+		return db.boltDB == otherDb.boltDB
 	}
 	return false
 }
@@ -60,113 +91,436 @@ func EnsureArgIsBoltDB(args []Object, index int) BoltDB {
 	panic(FailArg(obj, "BoltDB", index))
 }
 
-func ExtractBoltDB(args []Object, index int) *bolt.DB {
-	return EnsureArgIsBoltDB(args, index).DB
+func ExtractBoltDB(args []Object, index int) *boltDB {
+	return EnsureArgIsBoltDB(args, index).boltDB
+}
+
+func MakeBoltTx(tx *boltTx) BoltTx {
+	res := BoltTx{tx, 0}
+	res.hash = HashPtr(uintptr(unsafe.Pointer(tx)))
+	return res
+}
+
+func (tx BoltTx) ToString(escape bool) string {
+	return "#object[BoltTx]"
+}
+
+func (tx BoltTx) Equals(other interface{}) bool {
+	if otherTx, ok := other.(BoltTx); ok {
+		return tx.boltTx == otherTx.boltTx
+	}
+	return false
+}
+
+func (tx BoltTx) GetInfo() *ObjectInfo {
+	return nil
+}
+
+func (tx BoltTx) GetType() *Type {
+	return boltTxType
+}
+
+func (tx BoltTx) Hash() uint32 {
+	return tx.hash
+}
+
+func (tx BoltTx) WithInfo(info *ObjectInfo) Object {
+	return tx
+}
+
+func EnsureArgIsBoltTx(args []Object, index int) BoltTx {
+	obj := args[index]
+	if c, yes := obj.(BoltTx); yes {
+		return c
+	}
+	panic(FailArg(obj, "BoltTx", index))
+}
+
+func ExtractBoltTx(args []Object, index int) *boltTx {
+	return EnsureArgIsBoltTx(args, index).boltTx
+}
+
+func MakeBoltCursor(c *boltCursor) BoltCursor {
+	res := BoltCursor{c, 0}
+	res.hash = HashPtr(uintptr(unsafe.Pointer(c)))
+	return res
+}
+
+func (c BoltCursor) ToString(escape bool) string {
+	return "#object[BoltCursor]"
+}
+
+func (c BoltCursor) Equals(other interface{}) bool {
+	if otherC, ok := other.(BoltCursor); ok {
+		return c.boltCursor == otherC.boltCursor
+	}
+	return false
+}
+
+func (c BoltCursor) GetInfo() *ObjectInfo {
+	return nil
+}
+
+func (c BoltCursor) GetType() *Type {
+	return boltCursorType
+}
+
+func (c BoltCursor) Hash() uint32 {
+	return c.hash
+}
+
+func (c BoltCursor) WithInfo(info *ObjectInfo) Object {
+	return c
+}
+
+func EnsureArgIsBoltCursor(args []Object, index int) BoltCursor {
+	obj := args[index]
+	if c, yes := obj.(BoltCursor); yes {
+		return c
+	}
+	panic(FailArg(obj, "BoltCursor", index))
 }
 
-func open(filename string, mode int) *bolt.DB {
+func ExtractBoltCursor(args []Object, index int) *boltCursor {
+	return EnsureArgIsBoltCursor(args, index).boltCursor
+}
+
+func open(filename string, mode int, opts Map) *boltDB {
+	res := &boltDB{}
+	if ok, v := opts.Get(MakeKeyword("values")); ok {
+		switch EnsureObjectIsKeyword(v, "values: %s").Name() {
+		case "string":
+		case "edn":
+			res.edn = true
+		default:
+			panic(RT.NewError("values must be :string or :edn, got " + v.ToString(true)))
+		}
+	}
 	db, err := bolt.Open(filename, os.FileMode(mode), nil)
 	PanicOnErr(err)
-	return db
+	res.DB = db
+	return res
 }
 
-func close(db *bolt.DB) Nil {
+func close(db *boltDB) Nil {
 	err := db.Close()
 	PanicOnErr(err)
 	return NIL
 }
 
-func createBucket(db *bolt.DB, name string) Nil {
-	db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucket([]byte(name))
-		PanicOnErr(err)
-		return nil
+func (db *boltDB) encode(value Object) []byte {
+	if db.edn {
+		s := value.ToString(true)
+		// Refuse what decode couldn't read back, such as
+		// functions and atoms, which print as #object[...].
+		obj, err := TryRead(NewReader(strings.NewReader(s), "<bolt>"))
+		if err != nil || !obj.Equals(value) {
+			panic(RT.NewError("Value can't be stored as EDN: " + s))
+		}
+		return []byte(s)
+	}
+	return []byte(EnsureObjectIsString(value, "value: %s").S)
+}
+
+func (db *boltDB) decode(value []byte) Object {
+	if value == nil {
+		return NIL
+	}
+	if !db.edn {
+		return MakeString(string(value))
+	}
+	obj, err := TryRead(NewReader(strings.NewReader(string(value)), "<bolt>"))
+	PanicOnErr(err)
+	return obj
+}
+
+// inTx calls f with obj if it's a transaction, or else with a new
+// transaction on database obj, writable or not, which is committed
+// if f returns and rolled back if it panics.
+func inTx(obj Object, writable bool, f func(tx *boltTx)) {
+	switch obj := obj.(type) {
+	case BoltTx:
+		if obj.DB() == nil {
+			panic(RT.NewError("Transaction is closed"))
+		}
+		f(obj.boltTx)
+	case BoltDB:
+		run := obj.View
+		if writable {
+			run = obj.Update
+		}
+		PanicOnErr(run(func(tx *bolt.Tx) error {
+			f(&boltTx{tx, obj.boltDB})
+			return nil
+		}))
+	default:
+		panic(RT.NewArgTypeError(0, obj, "BoltDB or BoltTx"))
+	}
+}
+
+func withTx(db Object, writable bool, f Callable) Object {
+	var res Object
+	inTx(db, writable, func(tx *boltTx) {
+		res = f.Call([]Object{MakeBoltTx(tx)})
 	})
-	return NIL
+	return res
+}
+
+// bucketPath returns the names of the buckets on the way to bucket,
+// which is either a name or a vector of names of nested buckets.
+func bucketPath(bucket Object) [][]byte {
+	switch b := bucket.(type) {
+	case String:
+		return [][]byte{[]byte(b.S)}
+	case Vec:
+		if b.Count() == 0 {
+			panic(RT.NewError("Bucket path must not be empty"))
+		}
+		var res [][]byte
+		for s := b.Seq(); !s.IsEmpty(); s = s.Rest() {
+			res = append(res, []byte(EnsureObjectIsString(s.First(), "bucket name: %s").S))
+		}
+		return res
+	default:
+		panic(RT.NewArgTypeError(1, bucket, "String or Vector"))
+	}
+}
+
+// walkBuckets returns the bucket at the end of path, which
+// leads to bucket, or tx itself if path is empty.
+func walkBuckets(tx *bolt.Tx, bucket Object, path [][]byte) bucketParent {
+	var res bucketParent = tx
+	for i, name := range path {
+		b := res.Bucket(name)
+		if b == nil {
+			missing := string(name)
+			if _, ok := bucket.(Vec); ok {
+				v := EmptyVector()
+				for _, n := range path[:i+1] {
+					v = v.Conjoin(MakeString(string(n)))
+				}
+				missing = v.ToString(true)
+			}
+			panic(RT.NewError("Bucket doesn't exists: " + missing))
+		}
+		res = b
+	}
+	return res
 }
 
-func createBucketIfNotExists(db *bolt.DB, name string) Nil {
-	db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte(name))
+func getBucket(tx *bolt.Tx, bucket Object) *bolt.Bucket {
+	return walkBuckets(tx, bucket, bucketPath(bucket)).(*bolt.Bucket)
+}
+
+// parentBucket returns what bucket is to be created in, along with
+// bucket's own name.
+func parentBucket(tx *bolt.Tx, bucket Object) (bucketParent, []byte) {
+	path := bucketPath(bucket)
+	return walkBuckets(tx, bucket, path[:len(path)-1]), path[len(path)-1]
+}
+
+func createBucket(db Object, name Object) Nil {
+	inTx(db, true, func(tx *boltTx) {
+		parent, n := parentBucket(tx.Tx, name)
+		_, err := parent.CreateBucket(n)
 		PanicOnErr(err)
-		return nil
 	})
 	return NIL
 }
 
-func deleteBucket(db *bolt.DB, name string) Nil {
-	db.Update(func(tx *bolt.Tx) error {
-		err := tx.DeleteBucket([]byte(name))
-		PanicOnErr(err)
-		return nil
+func createBucketIfNotExists(db Object, name Object) Nil {
+	inTx(db, true, func(tx *boltTx) {
+		var parent bucketParent = tx.Tx
+		for _, n := range bucketPath(name) {
+			b, err := parent.CreateBucketIfNotExists(n)
+			PanicOnErr(err)
+			parent = b
+		}
 	})
 	return NIL
 }
 
-func getBucket(tx *bolt.Tx, bucket string) *bolt.Bucket {
-	b := tx.Bucket([]byte(bucket))
-	if b == nil {
-		panic(RT.NewError("Bucket doesn't exists: " + bucket))
-	}
-	return b
+func deleteBucket(db Object, name Object) Nil {
+	inTx(db, true, func(tx *boltTx) {
+		parent, n := parentBucket(tx.Tx, name)
+		PanicOnErr(parent.DeleteBucket(n))
+	})
+	return NIL
 }
 
-func nextSequence(db *bolt.DB, bucket string) Int {
+func nextSequence(db Object, bucket Object) Int {
 	var id uint64
-	db.Update(func(tx *bolt.Tx) error {
-		b := getBucket(tx, bucket)
-		id, _ = b.NextSequence()
-		return nil
+	inTx(db, true, func(tx *boltTx) {
+		var err error
+		id, err = getBucket(tx.Tx, bucket).NextSequence()
+		PanicOnErr(err)
 	})
 	return MakeInt(int(id))
 }
 
-func put(db *bolt.DB, bucket, key, value string) Nil {
-	db.Update(func(tx *bolt.Tx) error {
-		b := getBucket(tx, bucket)
-		err := b.Put([]byte(key), []byte(value))
+func put(db Object, bucket Object, key string, value Object) Nil {
+	inTx(db, true, func(tx *boltTx) {
+		b := getBucket(tx.Tx, bucket)
+		err := b.Put([]byte(key), tx.db.encode(value))
 		PanicOnErr(err)
-		return nil
 	})
 	return NIL
 }
 
-func delete(db *bolt.DB, bucket, key string) Nil {
-	db.Update(func(tx *bolt.Tx) error {
-		b := getBucket(tx, bucket)
+func delete(db Object, bucket Object, key string) Nil {
+	inTx(db, true, func(tx *boltTx) {
+		b := getBucket(tx.Tx, bucket)
 		err := b.Delete([]byte(key))
 		PanicOnErr(err)
-		return nil
 	})
 	return NIL
 }
 
-func get(db *bolt.DB, bucket, key string) Object {
-	var v []byte
-	db.View(func(tx *bolt.Tx) error {
-		b := getBucket(tx, bucket)
-		v = b.Get([]byte(key))
-		return nil
+func get(db Object, bucket Object, key string) Object {
+	var res Object
+	inTx(db, false, func(tx *boltTx) {
+		b := getBucket(tx.Tx, bucket)
+		res = tx.db.decode(b.Get([]byte(key)))
 	})
-	if v == nil {
+	return res
+}
+
+func entry(db *boltDB, k, v []byte) Object {
+	if k == nil {
 		return NIL
 	}
-	return MakeString(string(v))
+	return NewVectorFrom(MakeString(string(k)), db.decode(v))
 }
 
-func byPrefix(db *bolt.DB, bucket, prefix string) *ArrayVector {
+func byPrefix(db Object, bucket Object, prefix string) *ArrayVector {
 	res := EmptyArrayVector()
-	db.View(func(tx *bolt.Tx) error {
-		c := getBucket(tx, bucket).Cursor()
+	inTx(db, false, func(tx *boltTx) {
+		c := getBucket(tx.Tx, bucket).Cursor()
 		pr := []byte(prefix)
 		for k, v := c.Seek(pr); k != nil && bytes.HasPrefix(k, pr); k, v = c.Next() {
-			res.Append(NewVectorFrom(MakeString(string(k)), MakeString(string(v))))
+			res.Append(entry(tx.db, k, v))
 		}
-		return nil
 	})
 	return res
 }
 
+func newCursor(tx *boltTx, bucket Object) *boltCursor {
+	if tx.DB() == nil {
+		panic(RT.NewError("Transaction is closed"))
+	}
+	return &boltCursor{getBucket(tx.Tx, bucket).Cursor(), tx.db}
+}
+
+// checkTx panics if c's transaction is closed, as Bolt would
+// panic itself on moving c.
+func (c *boltCursor) checkTx() {
+	if c.Bucket().Tx().DB() == nil {
+		panic(RT.NewError("Transaction is closed"))
+	}
+}
+
+func (c *boltCursor) move(f func() ([]byte, []byte)) Object {
+	c.checkTx()
+	k, v := f()
+	return entry(c.db, k, v)
+}
+
+func cursorFirst(c *boltCursor) Object {
+	return c.move(c.First)
+}
+
+func cursorLast(c *boltCursor) Object {
+	return c.move(c.Last)
+}
+
+func cursorNext(c *boltCursor) Object {
+	return c.move(c.Next)
+}
+
+func cursorPrev(c *boltCursor) Object {
+	return c.move(c.Prev)
+}
+
+func cursorSeek(c *boltCursor, key string) Object {
+	return c.move(func() ([]byte, []byte) {
+		return c.Seek([]byte(key))
+	})
+}
+
+// prefixEnd returns the smallest key greater than all the keys
+// that start with prefix, or nil if there is none.
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+func optKey(opts Map, key string) []byte {
+	if ok, v := opts.Get(MakeKeyword(key)); ok && !v.Equals(NIL) {
+		return []byte(EnsureObjectIsString(v, key+": %s").S)
+	}
+	return nil
+}
+
+// scan returns the lazy seq of the entries of bucket with keys
+// from :start (inclusive) to :end (exclusive) and starting with
+// :prefix, in descending order of keys if :reverse is true.
+func scan(tx *boltTx, bucket Object, opts Map) Object {
+	c := newCursor(tx, bucket)
+	start, end := optKey(opts, "start"), optKey(opts, "end")
+	if prefix := optKey(opts, "prefix"); prefix != nil {
+		if start == nil || bytes.Compare(prefix, start) > 0 {
+			start = prefix
+		}
+		if pe := prefixEnd(prefix); pe != nil && (end == nil || bytes.Compare(pe, end) < 0) {
+			end = pe
+		}
+	}
+	inRange := func(k []byte) bool {
+		return k != nil && (start == nil || bytes.Compare(k, start) >= 0) && (end == nil || bytes.Compare(k, end) < 0)
+	}
+	var first, step func() ([]byte, []byte)
+	if ok, r := opts.Get(MakeKeyword("reverse")); ok && ToBool(r) {
+		first = func() ([]byte, []byte) {
+			if end == nil {
+				return c.Last()
+			}
+			if k, _ := c.Seek(end); k == nil {
+				return c.Last()
+			}
+			return c.Prev()
+		}
+		step = c.Prev
+	} else {
+		first = func() ([]byte, []byte) {
+			if start == nil {
+				return c.First()
+			}
+			return c.Seek(start)
+		}
+		step = c.Next
+	}
+	var entries func(move func() ([]byte, []byte)) *LazySeq
+	entries = func(move func() ([]byte, []byte)) *LazySeq {
+		return NewLazySeq(Proc{Fn: func(args []Object) Object {
+			c.checkTx()
+			k, v := move()
+			if !inRange(k) {
+				return EmptyList
+			}
+			return NewConsSeq(entry(c.db, k, v), entries(step))
+		}, Name: "entries", Package: "std/bolt"})
+	}
+	return entries(first)
+}
+
 func init() {
 	boltDBType = RegType("BoltDB", (*BoltDB)(nil), "Wraps Bolt DB type")
+	boltTxType = RegType("BoltTx", (*BoltTx)(nil), "Wraps Bolt Tx type")
+	boltCursorType = RegType("BoltCursor", (*BoltCursor)(nil), "Wraps Bolt Cursor type")
 }
@@ -0,0 +1,35 @@
+// This file is generated by generate-std.joke script. Do not edit manually!
+
+//go:build !gen_code
+// +build !gen_code
+
+package filepath
+
+import (
+	"fmt"
+	. "github.com/candid82/joker/core"
+	"os"
+)
+
+func InternsOrThunks() {
+	if VerbosityLevel > 0 {
+		fmt.Fprintln(os.Stderr, "Lazily running fast version of filepath.InternsOrThunks().")
+	}
+	STD_thunk_filepath_abs__var = __abs_
+	STD_thunk_filepath_isabs__var = __isabs_
+	STD_thunk_filepath_base__var = __base_
+	STD_thunk_filepath_clean__var = __clean_
+	STD_thunk_filepath_dir__var = __dir_
+	STD_thunk_filepath_eval_symlinks__var = __eval_symlinks_
+	STD_thunk_filepath_ext__var = __ext_
+	STD_thunk_filepath_file_seq__var = __file_seq_
+	STD_thunk_filepath_from_slash__var = __from_slash_
+	STD_thunk_filepath_glob__var = __glob_
+	STD_thunk_filepath_join__var = __join_
+	STD_thunk_filepath_ismatches__var = __ismatches_
+	STD_thunk_filepath_rel__var = __rel_
+	STD_thunk_filepath_split__var = __split_
+	STD_thunk_filepath_split_list__var = __split_list_
+	STD_thunk_filepath_to_slash__var = __to_slash_
+	STD_thunk_filepath_volume_name__var = __volume_name_
+}
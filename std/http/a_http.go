@@ -4,8 +4,26 @@ package http
 
 import (
 	. "github.com/candid82/joker/core"
+	"time"
 )
 
+var __address__P ProcFn = __address_
+var address_ Proc = Proc{Fn: __address__P, Name: "address_", Package: "std/http"}
+
+func __address_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 1:
+		server := ExtractHTTPServer(_args, 0)
+		_res := serverAddress(server)
+		return MakeString(_res)
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
 var __send__P ProcFn = __send_
 var send_ Proc = Proc{Fn: __send__P, Name: "send_", Package: "std/http"}
 
@@ -23,6 +41,56 @@ func __send_(_args []Object) Object {
 	return NIL
 }
 
+var __serve__P ProcFn = __serve_
+var serve_ Proc = Proc{Fn: __serve__P, Name: "serve_", Package: "std/http"}
+
+func __serve_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 2:
+		addr := ExtractString(_args, 0)
+		handler := ExtractCallable(_args, 1)
+		_res := startServer(addr, handler, EmptyArrayMap())
+		return MakeHTTPServer(_res)
+
+	case _c == 3:
+		addr := ExtractString(_args, 0)
+		handler := ExtractCallable(_args, 1)
+		opts := ExtractMap(_args, 2)
+		_res := startServer(addr, handler, opts)
+		return MakeHTTPServer(_res)
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
+var __serve_files__P ProcFn = __serve_files_
+var serve_files_ Proc = Proc{Fn: __serve_files__P, Name: "serve_files_", Package: "std/http"}
+
+func __serve_files_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 2:
+		addr := ExtractString(_args, 0)
+		root := ExtractString(_args, 1)
+		_res := startFileServer(addr, root, EmptyArrayMap())
+		return MakeHTTPServer(_res)
+
+	case _c == 3:
+		addr := ExtractString(_args, 0)
+		root := ExtractString(_args, 1)
+		opts := ExtractMap(_args, 2)
+		_res := startFileServer(addr, root, opts)
+		return MakeHTTPServer(_res)
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
 var __start_file_server__P ProcFn = __start_file_server_
 var start_file_server_ Proc = Proc{Fn: __start_file_server__P, Name: "start_file_server_", Package: "std/http"}
 
@@ -32,7 +100,14 @@ func __start_file_server_(_args []Object) Object {
 	case _c == 2:
 		addr := ExtractString(_args, 0)
 		root := ExtractString(_args, 1)
-		_res := startFileServer(addr, root)
+		_res := waitServer(startFileServer(addr, root, EmptyArrayMap()))
+		return _res
+
+	case _c == 3:
+		addr := ExtractString(_args, 0)
+		root := ExtractString(_args, 1)
+		opts := ExtractMap(_args, 2)
+		_res := waitServer(startFileServer(addr, root, opts))
 		return _res
 
 	default:
@@ -50,7 +125,158 @@ func __start_server_(_args []Object) Object {
 	case _c == 2:
 		addr := ExtractString(_args, 0)
 		handler := ExtractCallable(_args, 1)
-		_res := startServer(addr, handler)
+		_res := waitServer(startServer(addr, handler, EmptyArrayMap()))
+		return _res
+
+	case _c == 3:
+		addr := ExtractString(_args, 0)
+		handler := ExtractCallable(_args, 1)
+		opts := ExtractMap(_args, 2)
+		_res := waitServer(startServer(addr, handler, opts))
+		return _res
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
+var __stop__P ProcFn = __stop_
+var stop_ Proc = Proc{Fn: __stop__P, Name: "stop_", Package: "std/http"}
+
+func __stop_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 1:
+		server := ExtractHTTPServer(_args, 0)
+		_res := stopServer(server, -1)
+		return _res
+
+	case _c == 2:
+		server := ExtractHTTPServer(_args, 0)
+		timeout := ExtractInt(_args, 1)
+		_res := stopServer(server, time.Duration(timeout)*time.Millisecond)
+		return _res
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
+var __wait__P ProcFn = __wait_
+var wait_ Proc = Proc{Fn: __wait__P, Name: "wait_", Package: "std/http"}
+
+func __wait_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 1:
+		server := ExtractHTTPServer(_args, 0)
+		_res := waitServer(server)
+		return _res
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
+var __ws_close__P ProcFn = __ws_close_
+var ws_close_ Proc = Proc{Fn: __ws_close__P, Name: "ws_close_", Package: "std/http"}
+
+func __ws_close_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 1:
+		ws := ExtractWebSocket(_args, 0)
+		_res := wsClose(ws, 1000, "")
+		return _res
+
+	case _c == 2:
+		ws := ExtractWebSocket(_args, 0)
+		code := ExtractInt(_args, 1)
+		_res := wsClose(ws, code, "")
+		return _res
+
+	case _c == 3:
+		ws := ExtractWebSocket(_args, 0)
+		code := ExtractInt(_args, 1)
+		reason := ExtractString(_args, 2)
+		_res := wsClose(ws, code, reason)
+		return _res
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
+var __ws_close_status__P ProcFn = __ws_close_status_
+var ws_close_status_ Proc = Proc{Fn: __ws_close_status__P, Name: "ws_close_status_", Package: "std/http"}
+
+func __ws_close_status_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 1:
+		ws := ExtractWebSocket(_args, 0)
+		_res := wsCloseStatus(ws)
+		return _res
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
+var __ws_connect__P ProcFn = __ws_connect_
+var ws_connect_ Proc = Proc{Fn: __ws_connect__P, Name: "ws_connect_", Package: "std/http"}
+
+func __ws_connect_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 1:
+		url := ExtractString(_args, 0)
+		_res := wsConnect(url, EmptyArrayMap())
+		return MakeWebSocket(_res)
+
+	case _c == 2:
+		url := ExtractString(_args, 0)
+		opts := ExtractMap(_args, 1)
+		_res := wsConnect(url, opts)
+		return MakeWebSocket(_res)
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
+var __ws_in__P ProcFn = __ws_in_
+var ws_in_ Proc = Proc{Fn: __ws_in__P, Name: "ws_in_", Package: "std/http"}
+
+func __ws_in_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 1:
+		ws := ExtractWebSocket(_args, 0)
+		_res := wsIn(ws)
+		return _res
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
+var __ws_out__P ProcFn = __ws_out_
+var ws_out_ Proc = Proc{Fn: __ws_out__P, Name: "ws_out_", Package: "std/http"}
+
+func __ws_out_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 1:
+		ws := ExtractWebSocket(_args, 0)
+		_res := wsOut(ws)
 		return _res
 
 	default:
@@ -14,6 +14,12 @@ func InternsOrThunks() {
 	}
 	httpNamespace.ResetMeta(MakeMeta(nil, `Provides HTTP client and server implementations.`, "1.0"))
 
+	httpNamespace.InternVar("address", address_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("server"))),
+			`Returns the network address server is listening on, which
+  includes the actual port if server was started on port 0.`, "1.0").Plus(MakeKeyword("tag"), String{S: "String"}))
+
 	httpNamespace.InternVar("send", send_,
 		MakeMeta(
 			NewListFrom(NewVectorFrom(MakeSymbol("request"))),
@@ -21,25 +27,160 @@ func InternsOrThunks() {
   request is a map with the following keys:
   - url (string)
   - method (string, keyword or symbol, defaults to :get)
-  - body (string)
+  - body (string or IOReader, such as a File, which is streamed)
+  - form-params (map, sent as an application/x-www-form-urlencoded body)
+  - multipart (seq of maps with :name, :content (string or IOReader)
+    and optional :filename and :content-type keys, streamed as
+    a multipart/form-data body)
+  - query-params (map, added to the query string of url)
   - host (string, overrides Host header if provided)
-  - headers (map).
-  All keys except for url are optional.
+  - headers (map)
+  - basic-auth ("user:password" string or [user password] vector)
+  - as (:string or :stream, defaults to :string)
+  - timeout (int, in milliseconds, including reading the response body)
+  - follow-redirects (boolean, defaults to true)
+  - ca-file (string, PEM file of CAs to verify the server with)
+  - cert-file and key-file (strings, PEM files of the client certificate)
+  - insecure-skip-verify (boolean, defaults to false).
+  All keys except for url are optional, and only one of body,
+  form-params and multipart may be present. Map keys and values of
+  params may be strings, keywords or other objects, which are
+  converted with str; a seq value adds the param once per element.
   response is a map with the following keys:
   - status (int)
-  - body (string)
+  - body (string, or IOReader if request's :as is :stream;
+    the reader must be closed with joker.io/close)
   - headers (map)
   - content-length (int)`, "1.0"))
 
+	httpNamespace.InternVar("serve", serve_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("addr"), MakeSymbol("handler")), NewVectorFrom(MakeSymbol("addr"), MakeSymbol("handler"), MakeSymbol("opts"))),
+			`Starts HTTP server on the TCP network address addr in the
+  background and returns the server, which keeps running until
+  it's stopped with stop. Use wait to block until then.
+  handler is called, in a goroutine of its own, with a request map
+  with the following keys:
+  - request-method (keyword)
+  - uri (string)
+  - query-string (string)
+  - headers (map of lower-case names to strings)
+  - body (IOReader)
+  - scheme (:http or :https)
+  - server-name, server-port, remote-addr, protocol and host (strings)
+  - websocket? (true if the request asks for a WebSocket upgrade).
+  It returns a response map with the following keys:
+  - status (int, defaults to 200)
+  - headers (map of names to strings or seqs of strings)
+  - body (string; File, served with support for conditional
+    and range requests; any other IOReader, which is streamed and
+    then closed if possible; seq of strings, each of which is
+    streamed as soon as it's realized; or a function of an IOWriter
+    that streams the body by writing to it).
+  To accept a WebSocket upgrade request instead, return a response
+  map with the following keys:
+  - websocket (function that is called with the WebSocket connection,
+    see ws-connect; the connection stays open after it returns)
+  - headers (as above, sent with the upgrade response)
+  - subprotocols (seq of strings, the subprotocols the server supports
+    in order of preference)
+  - allowed-origins ("*" or a seq of strings; by default, only
+    requests from the server's own host are upgraded)
+  - ping-interval (int, see ws-connect).
+  opts is a map with the following optional keys:
+  - cert-file and key-file (strings, PEM files of the certificate
+    and key to serve HTTPS with)
+  - error-handler (function of the request map and the error thrown
+    by handler that returns a response map; by default, errors are
+    answered with status 500).
+  Errors are also printed to stderr.`, "1.0").Plus(MakeKeyword("tag"), String{S: "HTTPServer"}))
+
+	httpNamespace.InternVar("serve-files", serve_files_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("addr"), MakeSymbol("root")), NewVectorFrom(MakeSymbol("addr"), MakeSymbol("root"), MakeSymbol("opts"))),
+			`Starts HTTP server on the TCP network address addr that
+  serves HTTP requests with the contents of the file system rooted at root.
+  Returns the server, see serve.
+  opts is a map with optional cert-file and key-file keys, as for serve.`, "1.0").Plus(MakeKeyword("tag"), String{S: "HTTPServer"}))
+
 	httpNamespace.InternVar("start-file-server", start_file_server_,
 		MakeMeta(
-			NewListFrom(NewVectorFrom(MakeSymbol("addr"), MakeSymbol("root"))),
+			NewListFrom(NewVectorFrom(MakeSymbol("addr"), MakeSymbol("root")), NewVectorFrom(MakeSymbol("addr"), MakeSymbol("root"), MakeSymbol("opts"))),
 			`Starts HTTP server on the TCP network address addr that
-  serves HTTP requests with the contents of the file system rooted at root.`, "1.0"))
+  serves HTTP requests with the contents of the file system rooted at root,
+  like serve-files, and blocks until it stops.`, "1.0"))
 
 	httpNamespace.InternVar("start-server", start_server_,
 		MakeMeta(
-			NewListFrom(NewVectorFrom(MakeSymbol("addr"), MakeSymbol("handler"))),
-			`Starts HTTP server on the TCP network address addr.`, "1.0"))
+			NewListFrom(NewVectorFrom(MakeSymbol("addr"), MakeSymbol("handler")), NewVectorFrom(MakeSymbol("addr"), MakeSymbol("handler"), MakeSymbol("opts"))),
+			`Starts HTTP server on the TCP network address addr, like serve,
+  and blocks until it stops. Throws the error that stopped the server,
+  if any. Use serve to run a server in the background instead.`, "1.0"))
+
+	httpNamespace.InternVar("stop", stop_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("server")), NewVectorFrom(MakeSymbol("server"), MakeSymbol("timeout"))),
+			`Stops server. It stops accepting connections right away and
+  closes the open ones, either immediately or, if timeout is given,
+  once the requests in progress are finished or timeout (in milliseconds)
+  has passed, whichever comes first. Returns nil once server has stopped.`, "1.0"))
+
+	httpNamespace.InternVar("wait", wait_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("server"))),
+			`Blocks until server is stopped. Throws the error that stopped
+  the server if it wasn't stopped with stop.`, "1.0"))
+
+	httpNamespace.InternVar("ws-close", ws_close_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("ws")), NewVectorFrom(MakeSymbol("ws"), MakeSymbol("code")), NewVectorFrom(MakeSymbol("ws"), MakeSymbol("code"), MakeSymbol("reason"))),
+			`Closes WebSocket connection ws with close code (1000, normal closure,
+  by default) and reason. Waits for the other side to acknowledge it,
+  but for no longer than 5 seconds. Messages that arrive in the meantime
+  are dropped.`, "1.0"))
+
+	httpNamespace.InternVar("ws-close-status", ws_close_status_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("ws"))),
+			`Returns nil if WebSocket connection ws is open. Otherwise,
+  returns a map with the :code and :reason it was closed with.
+  The code is 1006 (abnormal closure) if the connection was lost
+  without a close frame, in which case the reason is the error.`, "1.0"))
+
+	httpNamespace.InternVar("ws-connect", ws_connect_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("url")), NewVectorFrom(MakeSymbol("url"), MakeSymbol("opts"))),
+			`Opens a WebSocket connection to url (ws:// or wss://) and returns it.
+  Incoming messages are put into the channel returned by ws-in:
+  text messages as strings, binary messages as IOReaders.
+  Messages put into the channel returned by ws-out are sent:
+  strings and other values (as by str) as text messages,
+  IOReaders as binary messages. The connection is closed, with a normal
+  close code, when ws-out is closed, and it's closed by ws-close.
+  Once the connection is closed, for whatever reason, both channels
+  are closed and ws-close-status returns its close code and reason.
+  Pings from the other side are answered automatically.
+  opts is a map with the following optional keys:
+  - headers (map of strings, sent with the handshake request)
+  - subprotocols (seq of strings, the subprotocols to ask for)
+  - timeout (int, handshake timeout in milliseconds)
+  - ping-interval (int, in milliseconds; if set, the other side is
+    pinged this often and the connection is closed if it doesn't answer
+    a ping before the next one is due)
+  - ca-file, cert-file, key-file and insecure-skip-verify, as for send.`, "1.0").Plus(MakeKeyword("tag"), String{S: "WebSocket"}))
+
+	httpNamespace.InternVar("ws-in", ws_in_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("ws"))),
+			`Returns the channel of incoming messages of WebSocket connection ws.
+  Reading from it is up to the caller: messages aren't read from the
+  connection until the previous one is taken from the channel.`, "1.0"))
+
+	httpNamespace.InternVar("ws-out", ws_out_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("ws"))),
+			`Returns the channel of outgoing messages of WebSocket connection ws.
+  Close it to close the connection once all the messages put into it
+  have been sent.`, "1.0"))
 
 }
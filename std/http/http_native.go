@@ -1,18 +1,22 @@
 package http
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	. "github.com/candid82/joker/core"
 )
 
-var client = &http.Client{}
-
 func extractMethod(request Map) string {
 	if ok, m := request.Get(MakeKeyword("method")); ok {
 		switch m := m.(type) {
@@ -36,15 +40,158 @@ func getOrPanic(m Map, k Object, errMsg string) Object {
 	panic(RT.NewError(errMsg))
 }
 
+// paramString returns the text of a param name or value:
+// strings as is, keywords and symbols without the colon,
+// anything else as printed by str.
+func paramString(obj Object, errMsg string) string {
+	switch obj := obj.(type) {
+	case String:
+		return obj.S
+	case Keyword:
+		return obj.ToString(false)[1:]
+	case Nil:
+		panic(RT.NewError(errMsg))
+	default:
+		return obj.ToString(false)
+	}
+}
+
+func mapToValues(params Map, name string) url.Values {
+	values := url.Values{}
+	for iter := params.Iter(); iter.HasNext(); {
+		p := iter.Next()
+		key := paramString(p.Key, name+" name must not be nil")
+		if s, ok := p.Value.(Seqable); ok && !isStringLike(p.Value) {
+			for s := s.Seq(); !s.IsEmpty(); s = s.Rest() {
+				values.Add(key, paramString(s.First(), name+" value must not be nil"))
+			}
+		} else {
+			values.Add(key, paramString(p.Value, name+" value must not be nil"))
+		}
+	}
+	return values
+}
+
+func isStringLike(obj Object) bool {
+	switch obj.(type) {
+	case String, Keyword, Symbol:
+		return true
+	}
+	return false
+}
+
+// bodyReader returns the reader of a request or response body,
+// which is a string or an IOReader (such as a File).
+func bodyReader(b Object, errMsg string) io.Reader {
+	switch b := b.(type) {
+	case String:
+		return strings.NewReader(b.S)
+	case io.Reader:
+		return b
+	default:
+		panic(RT.NewError(fmt.Sprintf(errMsg, "String or IOReader", b.GetType().ToString(false))))
+	}
+}
+
+// multipartBody streams the parts, each a map with :name and
+// :content keys and optional :filename and :content-type keys,
+// through a pipe so that file contents are never held in memory.
+func multipartBody(parts Seqable) (*io.PipeReader, string) {
+	type part struct {
+		name, filename, contentType string
+		content                     io.Reader
+	}
+	var ps []part
+	for s := parts.Seq(); !s.IsEmpty(); s = s.Rest() {
+		m := EnsureObjectIsMap(s.First(), "multipart part: %s")
+		p := part{
+			name:    EnsureObjectIsString(getOrPanic(m, MakeKeyword("name"), ":name key must be present in multipart part"), "multipart part name: %s").S,
+			content: bodyReader(getOrPanic(m, MakeKeyword("content"), ":content key must be present in multipart part"), "multipart part content: Expected %s, got %s"),
+		}
+		if ok, f := m.Get(MakeKeyword("filename")); ok {
+			p.filename = EnsureObjectIsString(f, "multipart part filename: %s").S
+		}
+		if ok, ct := m.Get(MakeKeyword("content-type")); ok {
+			p.contentType = EnsureObjectIsString(ct, "multipart part content-type: %s").S
+		}
+		ps = append(ps, p)
+	}
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		for _, p := range ps {
+			h := textproto.MIMEHeader{}
+			disposition := fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(p.name))
+			if p.filename != "" {
+				disposition += fmt.Sprintf(`; filename="%s"`, quoteEscaper.Replace(p.filename))
+			}
+			h.Set("Content-Disposition", disposition)
+			if p.contentType != "" {
+				h.Set("Content-Type", p.contentType)
+			} else if p.filename != "" {
+				h.Set("Content-Type", "application/octet-stream")
+			}
+			w, err := mw.CreatePart(h)
+			if err == nil {
+				_, err = io.Copy(w, p.content)
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.CloseWithError(mw.Close())
+	}()
+	return pr, mw.FormDataContentType()
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
 func mapToReq(request Map) *http.Request {
 	method := strings.ToUpper(extractMethod(request))
-	url := EnsureObjectIsString(getOrPanic(request, MakeKeyword("url"), ":url key must be present in request map"), "url: %s").S
+	reqURL := EnsureObjectIsString(getOrPanic(request, MakeKeyword("url"), ":url key must be present in request map"), "url: %s").S
+	if ok, q := request.Get(MakeKeyword("query-params")); ok {
+		u, err := url.Parse(reqURL)
+		PanicOnErr(err)
+		query := u.Query()
+		for k, v := range mapToValues(EnsureObjectIsMap(q, "query-params: %s"), "query param") {
+			query[k] = append(query[k], v...)
+		}
+		u.RawQuery = query.Encode()
+		reqURL = u.String()
+	}
 	var reqBody io.Reader
+	contentType := ""
+	bodyKeys := 0
 	if ok, b := request.Get(MakeKeyword("body")); ok {
-		reqBody = strings.NewReader(EnsureObjectIsString(b, "body: %s").S)
+		reqBody = bodyReader(b, "body: Expected %s, got %s")
+		bodyKeys++
+	}
+	if ok, f := request.Get(MakeKeyword("form-params")); ok {
+		reqBody = strings.NewReader(mapToValues(EnsureObjectIsMap(f, "form-params: %s"), "form param").Encode())
+		contentType = "application/x-www-form-urlencoded"
+		bodyKeys++
 	}
-	req, err := http.NewRequest(method, url, reqBody)
+	if ok, m := request.Get(MakeKeyword("multipart")); ok {
+		pr, ct := multipartBody(EnsureObjectIsSeqable(m, "multipart: %s"))
+		// Stop the goroutine writing the parts if the request can't be made.
+		defer func() {
+			if r := recover(); r != nil {
+				pr.Close()
+				panic(r)
+			}
+		}()
+		reqBody, contentType = pr, ct
+		bodyKeys++
+	}
+	if bodyKeys > 1 {
+		panic(RT.NewError("Only one of :body, :form-params and :multipart may be present in request map"))
+	}
+	req, err := http.NewRequest(method, reqURL, reqBody)
 	PanicOnErr(err)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
 	if ok, headers := request.Get(MakeKeyword("headers")); ok {
 		h := EnsureObjectIsMap(headers, "headers: %s")
 		for iter := h.Iter(); iter.HasNext(); {
@@ -55,38 +202,98 @@ func mapToReq(request Map) *http.Request {
 	if ok, host := request.Get(MakeKeyword("host")); ok {
 		req.Host = EnsureObjectIsString(host, "host: %s").S
 	}
+	if ok, auth := request.Get(MakeKeyword("basic-auth")); ok {
+		switch auth := auth.(type) {
+		case String:
+			user, password, _ := strings.Cut(auth.S, ":")
+			req.SetBasicAuth(user, password)
+		case Vec:
+			if auth.Count() != 2 {
+				panic(RT.NewError("basic-auth must be a vector of user and password"))
+			}
+			req.SetBasicAuth(EnsureObjectIsString(auth.At(0), "basic-auth user: %s").S,
+				EnsureObjectIsString(auth.At(1), "basic-auth password: %s").S)
+		default:
+			panic(RT.NewError(fmt.Sprintf("basic-auth must be a string or a vector, got %s", auth.GetType().ToString(false))))
+		}
+	}
 	return req
 }
 
-func reqToMap(host String, port String, req *http.Request) Map {
-	defer req.Body.Close()
-	res := EmptyArrayMap()
-	body, err := ioutil.ReadAll(req.Body)
-	PanicOnErr(err)
-	res.Add(MakeKeyword("request-method"), MakeKeyword(strings.ToLower(req.Method)))
-	res.Add(MakeKeyword("body"), MakeString(string(body)))
-	res.Add(MakeKeyword("uri"), MakeString(req.URL.Path))
-	res.Add(MakeKeyword("query-string"), MakeString(req.URL.RawQuery))
-	res.Add(MakeKeyword("server-name"), host)
-	res.Add(MakeKeyword("server-port"), port)
-	res.Add(MakeKeyword("remote-addr"), MakeString(req.RemoteAddr[:strings.LastIndexByte(req.RemoteAddr, byte(':'))]))
-	res.Add(MakeKeyword("protocol"), MakeString(req.Proto))
-	res.Add(MakeKeyword("scheme"), MakeKeyword("http"))
-	res.Add(MakeKeyword("host"), MakeString(req.Host))
-	headers := EmptyArrayMap()
-	for k, v := range req.Header {
-		headers.Add(MakeString(strings.ToLower(k)), MakeString(strings.Join(v, ",")))
-	}
-	res.Add(MakeKeyword("headers"), headers)
-	return res
+func getBool(m Map, k string, def bool) bool {
+	if ok, v := m.Get(MakeKeyword(k)); ok {
+		return ToBool(v)
+	}
+	return def
 }
 
-func respToMap(resp *http.Response) Map {
-	defer resp.Body.Close()
+// tlsConfig returns the client TLS config set by the :ca-file,
+// :cert-file, :key-file and :insecure-skip-verify keys of
+// the request map, or nil if none of them is present.
+func tlsConfig(request Map) *tls.Config {
+	var config *tls.Config
+	ensureConfig := func() {
+		if config == nil {
+			config = &tls.Config{}
+		}
+	}
+	if ok, caFile := request.Get(MakeKeyword("ca-file")); ok {
+		pem, err := os.ReadFile(EnsureObjectIsString(caFile, "ca-file: %s").S)
+		PanicOnErr(err)
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			panic(RT.NewError("No certificates found in ca-file"))
+		}
+		ensureConfig()
+		config.RootCAs = pool
+	}
+	okCert, certFile := request.Get(MakeKeyword("cert-file"))
+	okKey, keyFile := request.Get(MakeKeyword("key-file"))
+	if okCert != okKey {
+		panic(RT.NewError("cert-file and key-file must be present together"))
+	}
+	if okCert {
+		cert, err := tls.LoadX509KeyPair(EnsureObjectIsString(certFile, "cert-file: %s").S, EnsureObjectIsString(keyFile, "key-file: %s").S)
+		PanicOnErr(err)
+		ensureConfig()
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if getBool(request, "insecure-skip-verify", false) {
+		ensureConfig()
+		config.InsecureSkipVerify = true
+	}
+	return config
+}
+
+func mapToClient(request Map) *http.Client {
+	client := &http.Client{}
+	if ok, t := request.Get(MakeKeyword("timeout")); ok {
+		client.Timeout = time.Duration(EnsureObjectIsInt(t, "timeout: %s").I) * time.Millisecond
+	}
+	if !getBool(request, "follow-redirects", true) {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	if config := tlsConfig(request); config != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = config
+		transport.DisableKeepAlives = true
+		client.Transport = transport
+	}
+	return client
+}
+
+func respToMap(resp *http.Response, stream bool) Map {
 	res := EmptyArrayMap()
-	body, err := ioutil.ReadAll(resp.Body)
-	PanicOnErr(err)
-	res.Add(MakeKeyword("body"), MakeString(string(body)))
+	if stream {
+		res.Add(MakeKeyword("body"), MakeIOReader(resp.Body))
+	} else {
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		PanicOnErr(err)
+		res.Add(MakeKeyword("body"), MakeString(string(body)))
+	}
 	res.Add(MakeKeyword("status"), MakeInt(resp.StatusCode))
 	respHeaders := EmptyArrayMap()
 	for k, v := range resp.Header {
@@ -98,78 +305,19 @@ func respToMap(resp *http.Response) Map {
 	return res
 }
 
-func mapToResp(response Map, w http.ResponseWriter) {
-	status := 0
-	if ok, s := response.Get(MakeKeyword("status")); ok {
-		status = EnsureObjectIsInt(s, "HTTP response status: %s").I
-	}
-	body := ""
-	if ok, b := response.Get(MakeKeyword("body")); ok {
-		body = EnsureObjectIsString(b, "HTTP response body: %s").S
-	}
-	if ok, headers := response.Get(MakeKeyword("headers")); ok {
-		header := w.Header()
-		h := EnsureObjectIsMap(headers, "HTTP response headers: %s")
-		for iter := h.Iter(); iter.HasNext(); {
-			p := iter.Next()
-			hname := EnsureObjectIsString(p.Key, "HTTP response header name %s").S
-			switch pvalue := p.Value.(type) {
-			case String:
-				header.Add(hname, pvalue.S)
-			case Seqable:
-				s := pvalue.Seq()
-				for !s.IsEmpty() {
-					header.Add(hname, EnsureObjectIsString(s.First(), "HTTP response header value: %s").S)
-					s = s.Rest()
-				}
-			default:
-				panic(RT.NewError("HTTP response header value must be a string or a seq of strings"))
-			}
+func sendRequest(request Map) Map {
+	stream := false
+	if ok, as := request.Get(MakeKeyword("as")); ok {
+		switch {
+		case as.Equals(MakeKeyword("stream")):
+			stream = true
+		case as.Equals(MakeKeyword("string")):
+		default:
+			panic(RT.NewError("as must be :string or :stream, got " + as.ToString(true)))
 		}
 	}
-	if status != 0 {
-		w.WriteHeader(status)
-	}
-	io.WriteString(w, body)
-}
-
-func sendRequest(request Map) Map {
-	req := mapToReq(request)
-	RT.GIL.Unlock()
-	resp, err := client.Do(req)
-	RT.GIL.Lock()
-	PanicOnErr(err)
-	return respToMap(resp)
-}
-
-func startServer(addr string, handler Callable) Object {
-	i := strings.LastIndexByte(addr, byte(':'))
-	host, port := MakeString(addr), MakeString("")
-	if i != -1 {
-		host = MakeString(addr[:i])
-		port = MakeString(addr[i+1:])
-	}
-	RT.GIL.Unlock()
-	defer RT.GIL.Lock()
-	err := http.ListenAndServe(addr, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		RT.GIL.Lock()
-		defer func() {
-			RT.GIL.Unlock()
-			if r := recover(); r != nil {
-				w.WriteHeader(500)
-				io.WriteString(w, "Internal server error")
-				fmt.Fprintln(os.Stderr, r)
-			}
-		}()
-		response := handler.Call([]Object{reqToMap(host, port, req)})
-		mapToResp(EnsureObjectIsMap(response, "HTTP response: %s"), w)
-	}))
-	PanicOnErr(err)
-	return NIL
-}
-
-func startFileServer(addr string, root string) Object {
-	err := http.ListenAndServe(addr, http.FileServer(http.Dir(root)))
+	client := mapToClient(request)
+	resp, err := client.Do(mapToReq(request))
 	PanicOnErr(err)
-	return NIL
+	return respToMap(resp, stream)
 }
@@ -0,0 +1,409 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	. "github.com/candid82/joker/core"
+	"github.com/gorilla/websocket"
+)
+
+type (
+	Server struct {
+		*http.Server
+		listener net.Listener
+		// Closed once the server has stopped serving.
+		done chan struct{}
+		err  error
+		// WebSocket connections are hijacked from the server,
+		// so it doesn't close them on Shutdown; stop does.
+		lock    sync.Mutex
+		sockets map[*wsConn]struct{}
+	}
+	HTTPServer struct {
+		*Server
+		hash uint32
+	}
+	// responseWriter records whether the response has been
+	// started, after which errors can no longer be reported
+	// with an error response.
+	responseWriter struct {
+		http.ResponseWriter
+		wroteHeader bool
+	}
+	// flushWriter flushes every write through to the client,
+	// so that streamed response bodies aren't held in buffers.
+	flushWriter struct {
+		w *responseWriter
+	}
+)
+
+var httpServerType *Type
+
+func MakeHTTPServer(s *Server) HTTPServer {
+	res := HTTPServer{s, 0}
+	res.hash = HashPtr(uintptr(unsafe.Pointer(s)))
+	return res
+}
+
+func (s HTTPServer) ToString(escape bool) string {
+	return "#object[HTTPServer " + s.listener.Addr().String() + "]"
+}
+
+func (s HTTPServer) Equals(other interface{}) bool {
+	if otherServer, ok := other.(HTTPServer); ok {
+		return s.Server == otherServer.Server
+	}
+	return false
+}
+
+func (s HTTPServer) GetInfo() *ObjectInfo {
+	return nil
+}
+
+func (s HTTPServer) GetType() *Type {
+	return httpServerType
+}
+
+func (s HTTPServer) Hash() uint32 {
+	return s.hash
+}
+
+func (s HTTPServer) WithInfo(info *ObjectInfo) Object {
+	return s
+}
+
+func EnsureArgIsHTTPServer(args []Object, index int) HTTPServer {
+	obj := args[index]
+	if c, yes := obj.(HTTPServer); yes {
+		return c
+	}
+	panic(FailArg(obj, "HTTPServer", index))
+}
+
+func ExtractHTTPServer(args []Object, index int) *Server {
+	return EnsureArgIsHTTPServer(args, index).Server
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(p)
+}
+
+// Hijack lets WebSocket connections be taken over.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.wroteHeader = true
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (fw flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	fw.w.Flush()
+	return n, err
+}
+
+func reqToMap(host String, port String, scheme Keyword, req *http.Request) Map {
+	res := EmptyArrayMap()
+	res.Add(MakeKeyword("request-method"), MakeKeyword(strings.ToLower(req.Method)))
+	res.Add(MakeKeyword("body"), MakeIOReader(req.Body))
+	res.Add(MakeKeyword("uri"), MakeString(req.URL.Path))
+	res.Add(MakeKeyword("query-string"), MakeString(req.URL.RawQuery))
+	res.Add(MakeKeyword("server-name"), host)
+	res.Add(MakeKeyword("server-port"), port)
+	res.Add(MakeKeyword("remote-addr"), MakeString(req.RemoteAddr[:strings.LastIndexByte(req.RemoteAddr, byte(':'))]))
+	res.Add(MakeKeyword("protocol"), MakeString(req.Proto))
+	res.Add(MakeKeyword("scheme"), scheme)
+	res.Add(MakeKeyword("host"), MakeString(req.Host))
+	if websocket.IsWebSocketUpgrade(req) {
+		res.Add(MakeKeyword("websocket?"), Boolean{B: true})
+	}
+	headers := EmptyArrayMap()
+	for k, v := range req.Header {
+		headers.Add(MakeString(strings.ToLower(k)), MakeString(strings.Join(v, ",")))
+	}
+	res.Add(MakeKeyword("headers"), headers)
+	return res
+}
+
+func writeHeaders(response Map, header http.Header) {
+	if ok, headers := response.Get(MakeKeyword("headers")); ok {
+		h := EnsureObjectIsMap(headers, "HTTP response headers: %s")
+		for iter := h.Iter(); iter.HasNext(); {
+			p := iter.Next()
+			hname := EnsureObjectIsString(p.Key, "HTTP response header name %s").S
+			switch pvalue := p.Value.(type) {
+			case String:
+				header.Add(hname, pvalue.S)
+			case Seqable:
+				s := pvalue.Seq()
+				for !s.IsEmpty() {
+					header.Add(hname, EnsureObjectIsString(s.First(), "HTTP response header value: %s").S)
+					s = s.Rest()
+				}
+			default:
+				panic(RT.NewError("HTTP response header value must be a string or a seq of strings"))
+			}
+		}
+	}
+}
+
+// mapToResp writes the response, whose body is a string, a File
+// (served with support for conditional and range requests),
+// any other IOReader, a seq of strings or a function of
+// an IOWriter. Bodies other than strings and Files are streamed,
+// with every write flushed through to the client.
+func mapToResp(response Map, w *responseWriter, req *http.Request) {
+	status := 0
+	if ok, s := response.Get(MakeKeyword("status")); ok {
+		status = EnsureObjectIsInt(s, "HTTP response status: %s").I
+	}
+	writeHeaders(response, w.Header())
+	var body Object = NIL
+	if ok, b := response.Get(MakeKeyword("body")); ok {
+		body = b
+	}
+	if f, ok := body.(*File); ok && (status == 0 || status == http.StatusOK) {
+		defer f.Close()
+		info, err := f.Stat()
+		PanicOnErr(err)
+		http.ServeContent(w, req, info.Name(), info.ModTime(), f)
+		return
+	}
+	if status != 0 {
+		w.WriteHeader(status)
+	}
+	switch b := body.(type) {
+	case Nil:
+	case String:
+		io.WriteString(w, b.S)
+	case io.Reader:
+		if c, ok := b.(io.Closer); ok {
+			defer c.Close()
+		}
+		_, err := io.Copy(flushWriter{w}, b)
+		PanicOnErr(err)
+	case Callable:
+		b.Call([]Object{MakeIOWriter(flushWriter{w})})
+	case Seqable:
+		fw := flushWriter{w}
+		for s := b.Seq(); !s.IsEmpty(); s = s.Rest() {
+			_, err := io.WriteString(fw, EnsureObjectIsString(s.First(), "HTTP response body element: %s").S)
+			PanicOnErr(err)
+		}
+	default:
+		panic(RT.NewError("HTTP response body must be a string, IOReader, seq of strings or function, got " + body.GetType().ToString(false)))
+	}
+}
+
+func errorObject(r interface{}) Object {
+	if obj, ok := r.(Object); ok {
+		return obj
+	}
+	return MakeString(fmt.Sprint(r))
+}
+
+// handleError replies to a request whose handler has thrown r,
+// with the response of errorHandler if there is one. It's too late
+// for that once the response has been started, so the connection
+// is aborted instead to let the client know the response is broken.
+func handleError(r interface{}, request Map, w *responseWriter, req *http.Request, errorHandler Callable) {
+	fmt.Fprintf(os.Stderr, "Error handling %s %s: %v\n", req.Method, req.URL.Path, r)
+	if w.wroteHeader {
+		panic(http.ErrAbortHandler)
+	}
+	if errorHandler != nil {
+		handled := func() (ok bool) {
+			defer func() {
+				if r := recover(); r != nil {
+					fmt.Fprintf(os.Stderr, "Error in error handler for %s %s: %v\n", req.Method, req.URL.Path, r)
+				}
+			}()
+			response := errorHandler.Call([]Object{request, errorObject(r)})
+			mapToResp(EnsureObjectIsMap(response, "HTTP response: %s"), w, req)
+			return true
+		}()
+		if handled || w.wroteHeader {
+			return
+		}
+	}
+	w.WriteHeader(http.StatusInternalServerError)
+	io.WriteString(w, "Internal server error")
+}
+
+func makeHandler(s *Server, handler Callable, host, port String, scheme Keyword, errorHandler Callable) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		rw := &responseWriter{ResponseWriter: w}
+		request := reqToMap(host, port, scheme, req)
+		defer func() {
+			if r := recover(); r != nil {
+				if r == http.ErrAbortHandler {
+					panic(r)
+				}
+				handleError(r, request, rw, req, errorHandler)
+			}
+		}()
+		response := EnsureObjectIsMap(handler.Call([]Object{request}), "HTTP response: %s")
+		if ok, _ := response.Get(MakeKeyword("websocket")); ok {
+			upgradeWebSocket(s, response, rw, req)
+			return
+		}
+		mapToResp(response, rw, req)
+	})
+}
+
+// listen starts serving HTTP, or HTTPS if opts has :cert-file
+// and :key-file, on the TCP network address addr. Errors that
+// prevent the server from starting are thrown, those that stop
+// it later are thrown by wait.
+func listen(addr string, opts Map, handler func(s *Server, scheme Keyword, port String) http.Handler) *Server {
+	var tlsConfig *tls.Config
+	okCert, certFile := opts.Get(MakeKeyword("cert-file"))
+	okKey, keyFile := opts.Get(MakeKeyword("key-file"))
+	if okCert != okKey {
+		panic(RT.NewError("cert-file and key-file must be present together"))
+	}
+	scheme := MakeKeyword("http")
+	if okCert {
+		cert, err := tls.LoadX509KeyPair(EnsureObjectIsString(certFile, "cert-file: %s").S, EnsureObjectIsString(keyFile, "key-file: %s").S)
+		PanicOnErr(err)
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		scheme = MakeKeyword("https")
+	}
+	ln, err := net.Listen("tcp", addr)
+	PanicOnErr(err)
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	s := &Server{
+		Server:   &http.Server{TLSConfig: tlsConfig},
+		listener: ln,
+		done:     make(chan struct{}),
+		sockets:  map[*wsConn]struct{}{},
+	}
+	s.Handler = handler(s, scheme, MakeString(port))
+	go func() {
+		var err error
+		if tlsConfig != nil {
+			err = s.ServeTLS(ln, "", "")
+		} else {
+			err = s.Serve(ln)
+		}
+		if err != http.ErrServerClosed {
+			s.err = err
+		}
+		close(s.done)
+	}()
+	return s
+}
+
+func startServer(addr string, handler Callable, opts Map) *Server {
+	var errorHandler Callable
+	if ok, eh := opts.Get(MakeKeyword("error-handler")); ok {
+		errorHandler = EnsureObjectIsCallable(eh, "error-handler: %s")
+	}
+	host := MakeString(addr)
+	if i := strings.LastIndexByte(addr, byte(':')); i != -1 {
+		host = MakeString(addr[:i])
+	}
+	return listen(addr, opts, func(s *Server, scheme Keyword, port String) http.Handler {
+		return makeHandler(s, handler, host, port, scheme, errorHandler)
+	})
+}
+
+func startFileServer(addr string, root string, opts Map) *Server {
+	return listen(addr, opts, func(*Server, Keyword, String) http.Handler {
+		return http.FileServer(http.Dir(root))
+	})
+}
+
+func (s *Server) track(c *wsConn) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	select {
+	case <-c.done:
+		// Already closed, and untracked.
+	default:
+		s.sockets[c] = struct{}{}
+	}
+}
+
+func (s *Server) untrack(c *wsConn) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.sockets, c)
+}
+
+// closeSockets closes the server's WebSocket connections
+// with a going away close code.
+func (s *Server) closeSockets() {
+	s.lock.Lock()
+	sockets := make([]*wsConn, 0, len(s.sockets))
+	for c := range s.sockets {
+		sockets = append(sockets, c)
+	}
+	s.lock.Unlock()
+	var wg sync.WaitGroup
+	for _, c := range sockets {
+		wg.Add(1)
+		go func(c *wsConn) {
+			defer wg.Done()
+			c.close(websocket.CloseGoingAway, "Server stopped")
+		}(c)
+	}
+	wg.Wait()
+}
+
+// stopServer closes the server's listener and then waits up to
+// timeout for the requests in progress to finish before closing
+// their connections. A negative timeout closes them immediately.
+// WebSocket connections are closed either way.
+func stopServer(s *Server, timeout time.Duration) Nil {
+	defer s.closeSockets()
+	if timeout < 0 {
+		PanicOnErr(s.Close())
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := s.Shutdown(ctx); err != nil {
+			if err != context.DeadlineExceeded {
+				PanicOnErr(err)
+			}
+			PanicOnErr(s.Close())
+		}
+	}
+	<-s.done
+	return NIL
+}
+
+func waitServer(s *Server) Nil {
+	<-s.done
+	PanicOnErr(s.err)
+	return NIL
+}
+
+func serverAddress(s *Server) string {
+	return s.listener.Addr().String()
+}
+
+func init() {
+	httpServerType = RegType("HTTPServer", (*HTTPServer)(nil), "Wraps Go 'net/http.Server' type")
+}
@@ -0,0 +1,334 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	. "github.com/candid82/joker/core"
+	"github.com/gorilla/websocket"
+)
+
+type (
+	// wsConn pumps the messages of a WebSocket connection between
+	// the connection and a pair of channels: incoming messages are put
+	// into in, and whatever is put into out is sent. The connection
+	// is closed when either side closes it, or when out is closed.
+	wsConn struct {
+		conn      *websocket.Conn
+		in        *Channel
+		out       *Channel
+		closeSent sync.Once
+		// The close code and reason sent by close, if it's
+		// been called.
+		sentStatus atomic.Value
+		finished   sync.Once
+		// Closed once the connection is closed, after which
+		// status holds its close code and reason.
+		done    chan struct{}
+		status  Map
+		onClose func(*wsConn)
+	}
+	WebSocket struct {
+		*wsConn
+		hash uint32
+	}
+)
+
+// How long to wait for the other side to answer a close frame.
+const wsCloseTimeout = 5 * time.Second
+
+var webSocketType *Type
+
+func MakeWebSocket(c *wsConn) WebSocket {
+	res := WebSocket{c, 0}
+	res.hash = HashPtr(uintptr(unsafe.Pointer(c)))
+	return res
+}
+
+func (ws WebSocket) ToString(escape bool) string {
+	return "#object[WebSocket " + ws.conn.RemoteAddr().String() + "]"
+}
+
+func (ws WebSocket) Equals(other interface{}) bool {
+	if otherWS, ok := other.(WebSocket); ok {
+		return ws.wsConn == otherWS.wsConn
+	}
+	return false
+}
+
+func (ws WebSocket) GetInfo() *ObjectInfo {
+	return nil
+}
+
+func (ws WebSocket) GetType() *Type {
+	return webSocketType
+}
+
+func (ws WebSocket) Hash() uint32 {
+	return ws.hash
+}
+
+func (ws WebSocket) WithInfo(info *ObjectInfo) Object {
+	return ws
+}
+
+func EnsureArgIsWebSocket(args []Object, index int) WebSocket {
+	obj := args[index]
+	if c, yes := obj.(WebSocket); yes {
+		return c
+	}
+	panic(FailArg(obj, "WebSocket", index))
+}
+
+func ExtractWebSocket(args []Object, index int) *wsConn {
+	return EnsureArgIsWebSocket(args, index).wsConn
+}
+
+func closeStatus(code int, reason string) Map {
+	res := EmptyArrayMap()
+	res.Add(MakeKeyword("code"), MakeInt(code))
+	res.Add(MakeKeyword("reason"), MakeString(reason))
+	return res
+}
+
+func abnormalClose(err error) Map {
+	return closeStatus(websocket.CloseAbnormalClosure, err.Error())
+}
+
+func newWSConn(conn *websocket.Conn, opts Map, onClose func(*wsConn)) *wsConn {
+	c := &wsConn{
+		conn:    conn,
+		in:      MakeChannel(make(chan FutureResult)),
+		out:     MakeChannel(make(chan FutureResult)),
+		done:    make(chan struct{}),
+		onClose: onClose,
+	}
+	if ok, p := opts.Get(MakeKeyword("ping-interval")); ok {
+		go c.ping(time.Duration(EnsureObjectIsInt(p, "ping-interval: %s").I) * time.Millisecond)
+	}
+	go c.read()
+	go c.write()
+	return c
+}
+
+func (c *wsConn) read() {
+	for {
+		t, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if sent, ok := c.sentStatus.Load().(Map); ok {
+				c.finish(sent)
+			} else if ce, ok := err.(*websocket.CloseError); ok {
+				c.finish(closeStatus(ce.Code, ce.Text))
+			} else {
+				c.finish(abnormalClose(err))
+			}
+			return
+		}
+		if t == websocket.BinaryMessage {
+			c.in.Put(MakeIOReader(bytes.NewReader(data)))
+		} else {
+			c.in.Put(MakeString(string(data)))
+		}
+	}
+}
+
+func (c *wsConn) write() {
+	for {
+		msg, ok := c.out.Take()
+		if !ok {
+			c.close(websocket.CloseNormalClosure, "")
+			return
+		}
+		var err error
+		switch msg := msg.(type) {
+		case String:
+			err = c.conn.WriteMessage(websocket.TextMessage, []byte(msg.S))
+		case io.Reader:
+			var data []byte
+			if data, err = io.ReadAll(msg); err == nil {
+				err = c.conn.WriteMessage(websocket.BinaryMessage, data)
+			}
+		default:
+			err = c.conn.WriteMessage(websocket.TextMessage, []byte(msg.ToString(false)))
+		}
+		if err != nil {
+			c.finish(abnormalClose(err))
+			return
+		}
+	}
+}
+
+// ping pings the other side every interval, and closes the
+// connection if it doesn't answer before the next ping is due.
+func (c *wsConn) ping(interval time.Duration) {
+	extend := func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(2 * interval))
+	}
+	extend("")
+	c.conn.SetPongHandler(extend)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(interval)); err != nil {
+				c.finish(abnormalClose(err))
+				return
+			}
+		}
+	}
+}
+
+// close sends a close frame with code and reason and waits for
+// the other side to answer it before closing the connection.
+// The connection's close status is then code and reason, whatever
+// the answer. Messages that arrive in the meantime are dropped, so
+// that reading them doesn't hold up reading the answer.
+func (c *wsConn) close(code int, reason string) {
+	c.closeSent.Do(func() {
+		status := closeStatus(code, reason)
+		c.sentStatus.Store(status)
+		c.out.Close()
+		go func() {
+			for {
+				if _, ok := c.in.Take(); !ok {
+					return
+				}
+			}
+		}()
+		err := c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsCloseTimeout))
+		if err != nil {
+			c.finish(abnormalClose(err))
+			return
+		}
+		select {
+		case <-c.done:
+		case <-time.After(wsCloseTimeout):
+			c.finish(status)
+		}
+	})
+	<-c.done
+}
+
+func (c *wsConn) finish(status Map) {
+	c.finished.Do(func() {
+		c.status = status
+		c.conn.Close()
+		c.in.Close()
+		c.out.Close()
+		close(c.done)
+		if c.onClose != nil {
+			c.onClose(c)
+		}
+	})
+}
+
+func wsIn(c *wsConn) *Channel {
+	return c.in
+}
+
+func wsOut(c *wsConn) *Channel {
+	return c.out
+}
+
+func wsClose(c *wsConn, code int, reason string) Nil {
+	c.close(code, reason)
+	return NIL
+}
+
+func wsCloseStatus(c *wsConn) Object {
+	select {
+	case <-c.done:
+		return c.status
+	default:
+		return NIL
+	}
+}
+
+// upgradeWebSocket upgrades the request to a WebSocket connection
+// and calls the :websocket function of the response with it.
+func upgradeWebSocket(s *Server, response Map, w *responseWriter, req *http.Request) {
+	f := EnsureObjectIsCallable(getOrPanic(response, MakeKeyword("websocket"), ":websocket key must be present in response map"), "websocket: %s")
+	upgrader := websocket.Upgrader{}
+	if ok, p := response.Get(MakeKeyword("subprotocols")); ok {
+		upgrader.Subprotocols = toStrings(EnsureObjectIsSeqable(p, "subprotocols: %s"), "subprotocol: %s")
+	}
+	if ok, o := response.Get(MakeKeyword("allowed-origins")); ok {
+		if o.Equals(MakeString("*")) {
+			upgrader.CheckOrigin = func(*http.Request) bool { return true }
+		} else {
+			origins := toStrings(EnsureObjectIsSeqable(o, "allowed-origins: %s"), "allowed origin: %s")
+			upgrader.CheckOrigin = func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+				for _, o := range origins {
+					if o == origin {
+						return true
+					}
+				}
+				return origin == ""
+			}
+		}
+	}
+	header := http.Header{}
+	writeHeaders(response, header)
+	conn, err := upgrader.Upgrade(w, req, header)
+	if err != nil {
+		// Upgrade has replied with an error response.
+		return
+	}
+	c := newWSConn(conn, response, s.untrack)
+	s.track(c)
+	defer func() {
+		if r := recover(); r != nil {
+			go c.close(websocket.CloseInternalServerErr, "Internal server error")
+			panic(r)
+		}
+	}()
+	f.Call([]Object{MakeWebSocket(c)})
+}
+
+func toStrings(s Seqable, errMsg string) []string {
+	var res []string
+	for s := s.Seq(); !s.IsEmpty(); s = s.Rest() {
+		res = append(res, EnsureObjectIsString(s.First(), errMsg).S)
+	}
+	return res
+}
+
+func wsConnect(url string, opts Map) *wsConn {
+	dialer := websocket.Dialer{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig(opts),
+	}
+	if ok, t := opts.Get(MakeKeyword("timeout")); ok {
+		dialer.HandshakeTimeout = time.Duration(EnsureObjectIsInt(t, "timeout: %s").I) * time.Millisecond
+	}
+	if ok, p := opts.Get(MakeKeyword("subprotocols")); ok {
+		dialer.Subprotocols = toStrings(EnsureObjectIsSeqable(p, "subprotocols: %s"), "subprotocol: %s")
+	}
+	header := http.Header{}
+	if ok, headers := opts.Get(MakeKeyword("headers")); ok {
+		h := EnsureObjectIsMap(headers, "headers: %s")
+		for iter := h.Iter(); iter.HasNext(); {
+			p := iter.Next()
+			header.Add(EnsureObjectIsString(p.Key, "header name: %s").S, EnsureObjectIsString(p.Value, "header value: %s").S)
+		}
+	}
+	conn, resp, err := dialer.Dial(url, header)
+	if err == websocket.ErrBadHandshake && resp != nil {
+		panic(RT.NewError("WebSocket handshake failed with status " + resp.Status))
+	}
+	PanicOnErr(err)
+	return newWSConn(conn, opts, nil)
+}
+
+func init() {
+	webSocketType = RegType("WebSocket", (*WebSocket)(nil), "Wraps Gorilla 'websocket.Conn' type")
+}
@@ -44,6 +44,23 @@ func __copy_(_args []Object) Object {
 	return NIL
 }
 
+var __gzip_reader__P ProcFn = __gzip_reader_
+var gzip_reader_ Proc = Proc{Fn: __gzip_reader__P, Name: "gzip_reader_", Package: "std/io"}
+
+func __gzip_reader_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 1:
+		src := ExtractObject(_args, 0)
+		_res := gzipReader(src)
+		return MakeIOReader(_res)
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
 var __pipe__P ProcFn = __pipe_
 var pipe_ Proc = Proc{Fn: __pipe__P, Name: "pipe_", Package: "std/io"}
 
@@ -0,0 +1,22 @@
+// This file is generated by generate-std.joke script. Do not edit manually!
+
+//go:build !gen_code
+// +build !gen_code
+
+package io
+
+import (
+	"fmt"
+	. "github.com/candid82/joker/core"
+	"os"
+)
+
+func InternsOrThunks() {
+	if VerbosityLevel > 0 {
+		fmt.Fprintln(os.Stderr, "Lazily running fast version of io.InternsOrThunks().")
+	}
+	STD_thunk_io_close__var = __close_
+	STD_thunk_io_copy__var = __copy_
+	STD_thunk_io_gzip_reader__var = __gzip_reader_
+	STD_thunk_io_pipe__var = __pipe_
+}
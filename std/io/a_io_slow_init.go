@@ -1,5 +1,8 @@
 // This file is generated by generate-std.joke script. Do not edit manually!
 
+//go:build gen_code
+// +build gen_code
+
 package io
 
 import (
@@ -27,6 +30,13 @@ func InternsOrThunks() {
   src must be IOReader, e.g. as returned by joker.os/open.
   dst must be IOWriter, e.g. as returned by joker.os/create.`, "1.0").Plus(MakeKeyword("tag"), String{S: "Int"}))
 
+	ioNamespace.InternVar("gzip-reader", gzip_reader_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("src"))),
+			`Returns an IOReader of the gzip-compressed contents of src,
+  a string or IOReader, compressed as they are read. src is closed,
+  if possible, once it's read.`, "1.0").Plus(MakeKeyword("tag"), String{S: "IOReader"}))
+
 	ioNamespace.InternVar("pipe", pipe_,
 		MakeMeta(
 			NewListFrom(NewVectorFrom()),
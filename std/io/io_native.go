@@ -1,8 +1,11 @@
 package io
 
 import (
-	. "github.com/candid82/joker/core"
+	"compress/gzip"
 	"io"
+	"strings"
+
+	. "github.com/candid82/joker/core"
 )
 
 func pipe() Object {
@@ -22,3 +25,30 @@ func close(f Object) Nil {
 	}
 	panic(RT.NewError("Object is not closable: " + f.ToString(false)))
 }
+
+// gzipReader returns a reader of the gzip-compressed src,
+// which is compressed as it's read.
+func gzipReader(src Object) *IOReader {
+	var r io.Reader
+	switch src := src.(type) {
+	case String:
+		r = strings.NewReader(src.S)
+	case io.Reader:
+		r = src
+	default:
+		panic(RT.NewArgTypeError(0, src, "String or IOReader"))
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		zw := gzip.NewWriter(pw)
+		_, err := io.Copy(zw, r)
+		if err == nil {
+			err = zw.Close()
+		}
+		if c, ok := r.(io.Closer); ok {
+			c.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return MakeIOReader(pr)
+}
@@ -61,13 +61,13 @@ func toObject(v interface{}, keywordize bool) Object {
 	case nil:
 		return NIL
 	case []interface{}:
-		res := EmptyVector()
+		res := NewTransientVector(EmptyVector())
 		for _, v := range v {
-			res = res.Conjoin(toObject(v, keywordize))
+			res.TransientConj(toObject(v, keywordize))
 		}
-		return res
+		return res.Persistent()
 	case map[string]interface{}:
-		res := EmptyArrayMap()
+		res := NewTransientMap(EmptyArrayMap())
 		for k, v := range v {
 			var key Object
 			if keywordize {
@@ -75,9 +75,9 @@ func toObject(v interface{}, keywordize bool) Object {
 			} else {
 				key = MakeString(k)
 			}
-			res.Add(key, toObject(v, keywordize))
+			res.TransientAssoc(key, toObject(v, keywordize))
 		}
-		return res
+		return res.Persistent()
 	default:
 		panic(RT.NewError(fmt.Sprintf("Unknown json value: %v", v)))
 	}
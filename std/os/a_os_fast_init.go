@@ -0,0 +1,71 @@
+// This file is generated by generate-std.joke script. Do not edit manually!
+
+//go:build !gen_code
+// +build !gen_code
+
+package os
+
+import (
+	"fmt"
+	. "github.com/candid82/joker/core"
+	"os"
+)
+
+func InternsOrThunks() {
+	if VerbosityLevel > 0 {
+		fmt.Fprintln(os.Stderr, "Lazily running fast version of os.InternsOrThunks().")
+	}
+	STD_thunk_os_args__var = __args_
+	STD_thunk_os_chdir__var = __chdir_
+	STD_thunk_os_chmod__var = __chmod_
+	STD_thunk_os_chown__var = __chown_
+	STD_thunk_os_chtimes__var = __chtimes_
+	STD_thunk_os_clearenv__var = __clearenv_
+	STD_thunk_os_close__var = __close_
+	STD_thunk_os_create__var = __create_
+	STD_thunk_os_create_temp__var = __create_temp_
+	STD_thunk_os_cwd__var = __cwd_
+	STD_thunk_os_egid__var = __egid_
+	STD_thunk_os_env__var = __env_
+	STD_thunk_os_euid__var = __euid_
+	STD_thunk_os_exec__var = __exec_
+	STD_thunk_os_executable__var = __executable_
+	STD_thunk_os_isexists__var = __isexists_
+	STD_thunk_os_exit__var = __exit_
+	STD_thunk_os_expand_env__var = __expand_env_
+	STD_thunk_os_get_env__var = __get_env_
+	STD_thunk_os_gid__var = __gid_
+	STD_thunk_os_groups__var = __groups_
+	STD_thunk_os_hostname__var = __hostname_
+	STD_thunk_os_kill__var = __kill_
+	STD_thunk_os_lchown__var = __lchown_
+	STD_thunk_os_link__var = __link_
+	STD_thunk_os_ls__var = __ls_
+	STD_thunk_os_lstat__var = __lstat_
+	STD_thunk_os_mkdir__var = __mkdir_
+	STD_thunk_os_mkdir_all__var = __mkdir_all_
+	STD_thunk_os_mkdir_temp__var = __mkdir_temp_
+	STD_thunk_os_open__var = __open_
+	STD_thunk_os_pagesize__var = __pagesize_
+	STD_thunk_os_ispath_separator__var = __ispath_separator_
+	STD_thunk_os_pid__var = __pid_
+	STD_thunk_os_ppid__var = __ppid_
+	STD_thunk_os_read_link__var = __read_link_
+	STD_thunk_os_remove__var = __remove_
+	STD_thunk_os_remove_all__var = __remove_all_
+	STD_thunk_os_rename__var = __rename_
+	STD_thunk_os_set_env__var = __set_env_
+	STD_thunk_os_sh__var = __sh_
+	STD_thunk_os_sh_from__var = __sh_from_
+	STD_thunk_os_signal__var = __signal_
+	STD_thunk_os_start__var = __start_
+	STD_thunk_os_stat__var = __stat_
+	STD_thunk_os_symlink__var = __symlink_
+	STD_thunk_os_temp_dir__var = __temp_dir_
+	STD_thunk_os_truncate__var = __truncate_
+	STD_thunk_os_uid__var = __uid_
+	STD_thunk_os_unset_env__var = __unset_env_
+	STD_thunk_os_user_cache_dir__var = __user_cache_dir_
+	STD_thunk_os_user_config_dir__var = __user_config_dir_
+	STD_thunk_os_user_home_dir__var = __user_home_dir_
+}
@@ -1,5 +1,8 @@
 // This file is generated by generate-std.joke script. Do not edit manually!
 
+//go:build gen_code
+// +build gen_code
+
 package os
 
 import (
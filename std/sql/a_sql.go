@@ -0,0 +1,195 @@
+// This file is generated by generate-std.joke script. Do not edit manually!
+
+package sql
+
+import (
+	. "github.com/candid82/joker/core"
+)
+
+var __close__P ProcFn = __close_
+var close_ Proc = Proc{Fn: __close__P, Name: "close_", Package: "std/sql"}
+
+func __close_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 1:
+		x := ExtractObject(_args, 0)
+		_res := close(x)
+		return _res
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
+var __execute_BANG__P ProcFn = __execute_BANG_
+var execute_BANG_ Proc = Proc{Fn: __execute_BANG__P, Name: "execute_BANG_", Package: "std/sql"}
+
+func __execute_BANG_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 1:
+		stmt := ExtractSQLStmt(_args, 0)
+		_res := execute(MakeSQLStmt(stmt), EmptyVector())
+		return _res
+
+	case _c == 2:
+		conn := ExtractObject(_args, 0)
+		sql := ExtractObject(_args, 1)
+		_res := execute(conn, sql)
+		return _res
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
+var __lazy_query__P ProcFn = __lazy_query_
+var lazy_query_ Proc = Proc{Fn: __lazy_query__P, Name: "lazy_query_", Package: "std/sql"}
+
+func __lazy_query_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 1:
+		stmt := ExtractSQLStmt(_args, 0)
+		_res := lazyQuery(MakeSQLStmt(stmt), EmptyVector())
+		return _res
+
+	case _c == 2:
+		conn := ExtractObject(_args, 0)
+		sql := ExtractObject(_args, 1)
+		_res := lazyQuery(conn, sql)
+		return _res
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
+var __open__P ProcFn = __open_
+var open_ Proc = Proc{Fn: __open__P, Name: "open_", Package: "std/sql"}
+
+func __open_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 1:
+		dsn := ExtractString(_args, 0)
+		_res := open("sqlite", dsn)
+		return MakeSQLDB(_res)
+
+	case _c == 2:
+		driver := ExtractString(_args, 0)
+		dsn := ExtractString(_args, 1)
+		_res := open(driver, dsn)
+		return MakeSQLDB(_res)
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
+var __prepare__P ProcFn = __prepare_
+var prepare_ Proc = Proc{Fn: __prepare__P, Name: "prepare_", Package: "std/sql"}
+
+func __prepare_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 2:
+		conn := ExtractObject(_args, 0)
+		sql := ExtractString(_args, 1)
+		_res := prepare(conn, sql)
+		return MakeSQLStmt(_res)
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
+var __query__P ProcFn = __query_
+var query_ Proc = Proc{Fn: __query__P, Name: "query_", Package: "std/sql"}
+
+func __query_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 1:
+		stmt := ExtractSQLStmt(_args, 0)
+		_res := query(MakeSQLStmt(stmt), EmptyVector())
+		return _res
+
+	case _c == 2:
+		conn := ExtractObject(_args, 0)
+		sql := ExtractObject(_args, 1)
+		_res := query(conn, sql)
+		return _res
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
+var __with_lazy_query__P ProcFn = __with_lazy_query_
+var with_lazy_query_ Proc = Proc{Fn: __with_lazy_query__P, Name: "with_lazy_query_", Package: "std/sql"}
+
+func __with_lazy_query_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 2:
+		stmt := ExtractSQLStmt(_args, 0)
+		f := ExtractCallable(_args, 1)
+		_res := withLazyQuery(MakeSQLStmt(stmt), EmptyVector(), f)
+		return _res
+
+	case _c == 3:
+		conn := ExtractObject(_args, 0)
+		sql := ExtractObject(_args, 1)
+		f := ExtractCallable(_args, 2)
+		_res := withLazyQuery(conn, sql, f)
+		return _res
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
+var __with_transaction__P ProcFn = __with_transaction_
+var with_transaction_ Proc = Proc{Fn: __with_transaction__P, Name: "with_transaction_", Package: "std/sql"}
+
+func __with_transaction_(_args []Object) Object {
+	_c := len(_args)
+	switch {
+	case _c == 2:
+		conn := ExtractObject(_args, 0)
+		f := ExtractCallable(_args, 1)
+		_res := withTransaction(conn, EmptyArrayMap(), f)
+		return _res
+
+	case _c == 3:
+		conn := ExtractObject(_args, 0)
+		opts := ExtractMap(_args, 1)
+		f := ExtractCallable(_args, 2)
+		_res := withTransaction(conn, opts, f)
+		return _res
+
+	default:
+		PanicArity(_c)
+	}
+	return NIL
+}
+
+func Init() {
+
+	InternsOrThunks()
+}
+
+var sqlNamespace = GLOBAL_ENV.EnsureSymbolIsLib(MakeSymbol("joker.sql"))
+
+func init() {
+	sqlNamespace.Lazy = Init
+}
@@ -0,0 +1,109 @@
+// This file is generated by generate-std.joke script. Do not edit manually!
+
+package sql
+
+import (
+	"fmt"
+	. "github.com/candid82/joker/core"
+	"os"
+)
+
+func InternsOrThunks() {
+	if VerbosityLevel > 0 {
+		fmt.Fprintln(os.Stderr, "Lazily running slow version of sql.InternsOrThunks().")
+	}
+	sqlNamespace.ResetMeta(MakeMeta(nil, `Provides access to SQL databases through Go's database/sql package.
+         The SQLite driver (pure Go, https://gitlab.com/cznic/sqlite) is built in.
+
+         Queries are given as a SQL string, or a vector of a SQL string
+         followed by the values of its ? parameters. Parameters may be nil,
+         numbers, strings, booleans, keywords (passed as their names) and times.
+         Rows are returned as maps of keyword column names to values.
+
+         Example:
+
+         user=> (def db (joker.sql/open "app.db"))
+         #'user/db
+         user=> (joker.sql/execute! db "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+         {:rows-affected 0, :last-insert-id 0}
+         user=> (joker.sql/execute! db ["INSERT INTO users (name) VALUES (?)" "Joe Black"])
+         {:rows-affected 1, :last-insert-id 1}
+         user=> (joker.sql/query db ["SELECT * FROM users WHERE id = ?" 1])
+         [{:id 1, :name "Joe Black"}]`, "1.0"))
+
+	sqlNamespace.InternVar("close", close_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("x"))),
+			`Closes a database or prepared statement.`, "1.0"))
+
+	sqlNamespace.InternVar("execute!", execute_BANG_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("stmt")), NewVectorFrom(MakeSymbol("conn"), MakeSymbol("sql"))),
+			`Runs a statement that doesn't return rows (such as INSERT, UPDATE
+  or CREATE TABLE) on conn, a database, transaction or prepared statement
+  (see query). Returns a map with :rows-affected and :last-insert-id keys,
+  where supported by the driver.`, "1.0"))
+
+	sqlNamespace.InternVar("lazy-query", lazy_query_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("stmt")), NewVectorFrom(MakeSymbol("conn"), MakeSymbol("sql"))),
+			`Like query, but returns a lazy seq of the rows, which are
+  read from the database as the seq is realized. The underlying
+  connection is held until the seq is fully realized or garbage
+  collected: a partially consumed seq keeps the connection from
+  being used by other queries until then. Use with-lazy-query
+  to release it as soon as the rows are no longer needed.
+  On a database with a single connection, such as an in-memory one,
+  the rows are all read right away instead, so that other queries
+  can still use the connection.`, "1.0"))
+
+	sqlNamespace.InternVar("open", open_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("dsn")), NewVectorFrom(MakeSymbol("driver"), MakeSymbol("dsn"))),
+			`Opens a database and returns it. With one argument, opens
+  the SQLite database at dsn, which is a file name (created if it
+  doesn't exist), ":memory:" for an in-memory database, or a file: URI.
+  Otherwise, opens dsn with the named database/sql driver.
+  The database holds a pool of connections and is safe to use from
+  several goroutines.`, "1.0").Plus(MakeKeyword("tag"), String{S: "SQLDB"}))
+
+	sqlNamespace.InternVar("prepare", prepare_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("conn"), MakeSymbol("sql"))),
+			`Returns a prepared statement for sql on conn, a database
+  or transaction, to be run with query or execute! as many times
+  as needed. A statement prepared on a transaction can only be used
+  until the transaction ends. Close it with close once it's
+  no longer needed.`, "1.0").Plus(MakeKeyword("tag"), String{S: "SQLStmt"}))
+
+	sqlNamespace.InternVar("query", query_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("stmt")), NewVectorFrom(MakeSymbol("conn"), MakeSymbol("sql"))),
+			`Runs query sql on conn, a database or transaction, and returns
+  a vector of the resulting rows. conn may also be a prepared statement,
+  in which case sql is a seq of the values of its parameters
+  (and may be omitted if it has none).`, "1.0"))
+
+	sqlNamespace.InternVar("with-lazy-query", with_lazy_query_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("stmt"), MakeSymbol("f")), NewVectorFrom(MakeSymbol("conn"), MakeSymbol("sql"), MakeSymbol("f"))),
+			`Runs query sql on conn (see query) and calls f with a lazy seq
+  of the resulting rows, like the one returned by lazy-query.
+  Returns the result of f. Once f returns or throws, the rows are
+  closed and the connection released, however much of the seq
+  has been realized; realizing more of it then throws.`, "1.0"))
+
+	sqlNamespace.InternVar("with-transaction", with_transaction_,
+		MakeMeta(
+			NewListFrom(NewVectorFrom(MakeSymbol("conn"), MakeSymbol("f")), NewVectorFrom(MakeSymbol("conn"), MakeSymbol("opts"), MakeSymbol("f"))),
+			`Begins a transaction on database conn and calls f with it.
+  Commits the transaction and returns the result of f, unless f throws,
+  in which case the transaction is rolled back and the error rethrown.
+  If conn is a transaction already, f is just called with it.
+  opts is a map with the following optional keys:
+  - isolation (isolation level keyword: :default, :read-uncommitted,
+    :read-committed, :write-committed, :repeatable-read, :snapshot,
+    :serializable or :linearizable)
+  - read-only (boolean).`, "1.0"))
+
+}
@@ -0,0 +1,517 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+	"unsafe"
+
+	. "github.com/candid82/joker/core"
+	_ "modernc.org/sqlite"
+)
+
+type (
+	SQLDB struct {
+		*sql.DB
+		hash uint32
+	}
+	SQLTx struct {
+		*sql.Tx
+		hash uint32
+	}
+	SQLStmt struct {
+		*sql.Stmt
+		hash uint32
+	}
+	// conn is what queries can be run on: a DB or a Tx.
+	conn interface {
+		Query(query string, args ...interface{}) (*sql.Rows, error)
+		Exec(query string, args ...interface{}) (sql.Result, error)
+		Prepare(query string) (*sql.Stmt, error)
+	}
+)
+
+var sqlDBType *Type
+var sqlTxType *Type
+var sqlStmtType *Type
+
+func MakeSQLDB(db *sql.DB) SQLDB {
+	res := SQLDB{db, 0}
+	res.hash = HashPtr(uintptr(unsafe.Pointer(db)))
+	return res
+}
+
+func (db SQLDB) ToString(escape bool) string {
+	return "#object[SQLDB]"
+}
+
+func (db SQLDB) Equals(other interface{}) bool {
+	if otherDb, ok := other.(SQLDB); ok {
+		return db.DB == otherDb.DB
+	}
+	return false
+}
+
+func (db SQLDB) GetInfo() *ObjectInfo {
+	return nil
+}
+
+func (db SQLDB) GetType() *Type {
+	return sqlDBType
+}
+
+func (db SQLDB) Hash() uint32 {
+	return db.hash
+}
+
+func (db SQLDB) WithInfo(info *ObjectInfo) Object {
+	return db
+}
+
+func EnsureArgIsSQLDB(args []Object, index int) SQLDB {
+	obj := args[index]
+	if c, yes := obj.(SQLDB); yes {
+		return c
+	}
+	panic(FailArg(obj, "SQLDB", index))
+}
+
+func ExtractSQLDB(args []Object, index int) *sql.DB {
+	return EnsureArgIsSQLDB(args, index).DB
+}
+
+func MakeSQLTx(tx *sql.Tx) SQLTx {
+	res := SQLTx{tx, 0}
+	res.hash = HashPtr(uintptr(unsafe.Pointer(tx)))
+	return res
+}
+
+func (tx SQLTx) ToString(escape bool) string {
+	return "#object[SQLTx]"
+}
+
+func (tx SQLTx) Equals(other interface{}) bool {
+	if otherTx, ok := other.(SQLTx); ok {
+		return tx.Tx == otherTx.Tx
+	}
+	return false
+}
+
+func (tx SQLTx) GetInfo() *ObjectInfo {
+	return nil
+}
+
+func (tx SQLTx) GetType() *Type {
+	return sqlTxType
+}
+
+func (tx SQLTx) Hash() uint32 {
+	return tx.hash
+}
+
+func (tx SQLTx) WithInfo(info *ObjectInfo) Object {
+	return tx
+}
+
+func MakeSQLStmt(stmt *sql.Stmt) SQLStmt {
+	res := SQLStmt{stmt, 0}
+	res.hash = HashPtr(uintptr(unsafe.Pointer(stmt)))
+	return res
+}
+
+func (stmt SQLStmt) ToString(escape bool) string {
+	return "#object[SQLStmt]"
+}
+
+func (stmt SQLStmt) Equals(other interface{}) bool {
+	if otherStmt, ok := other.(SQLStmt); ok {
+		return stmt.Stmt == otherStmt.Stmt
+	}
+	return false
+}
+
+func (stmt SQLStmt) GetInfo() *ObjectInfo {
+	return nil
+}
+
+func (stmt SQLStmt) GetType() *Type {
+	return sqlStmtType
+}
+
+func (stmt SQLStmt) Hash() uint32 {
+	return stmt.hash
+}
+
+func (stmt SQLStmt) WithInfo(info *ObjectInfo) Object {
+	return stmt
+}
+
+func EnsureArgIsSQLStmt(args []Object, index int) SQLStmt {
+	obj := args[index]
+	if c, yes := obj.(SQLStmt); yes {
+		return c
+	}
+	panic(FailArg(obj, "SQLStmt", index))
+}
+
+func ExtractSQLStmt(args []Object, index int) *sql.Stmt {
+	return EnsureArgIsSQLStmt(args, index).Stmt
+}
+
+func open(driver, dsn string) *sql.DB {
+	db, err := sql.Open(driver, dsn)
+	PanicOnErr(err)
+	if driver == "sqlite" && dsn == ":memory:" {
+		// Every connection to :memory: gets a database of its own.
+		db.SetMaxOpenConns(1)
+	}
+	PanicOnErr(db.Ping())
+	return db
+}
+
+func close(obj Object) Nil {
+	switch obj := obj.(type) {
+	case SQLDB:
+		PanicOnErr(obj.Close())
+	case SQLStmt:
+		singleConnStmts.Delete(obj.Stmt)
+		PanicOnErr(obj.Close())
+	default:
+		panic(RT.NewArgTypeError(0, obj, "SQLDB or SQLStmt"))
+	}
+	return NIL
+}
+
+func toConn(obj Object) conn {
+	switch obj := obj.(type) {
+	case SQLDB:
+		return obj.DB
+	case SQLTx:
+		return obj.Tx
+	default:
+		panic(RT.NewArgTypeError(0, obj, "SQLDB or SQLTx"))
+	}
+}
+
+func toArg(obj Object) interface{} {
+	switch obj := obj.(type) {
+	case Nil:
+		return nil
+	case Int:
+		return int64(obj.I)
+	case Double:
+		return obj.D
+	case String:
+		return obj.S
+	case Boolean:
+		return obj.B
+	case Keyword:
+		return obj.Name()
+	case Char:
+		return string(obj.Ch)
+	case Time:
+		return obj.T
+	case Number:
+		return obj.ToString(false)
+	default:
+		panic(RT.NewError("SQL parameter must be nil, a number, string, boolean, keyword or time, got " + obj.GetType().ToString(false)))
+	}
+}
+
+func toArgs(params Seq) []interface{} {
+	var res []interface{}
+	for ; !params.IsEmpty(); params = params.Rest() {
+		res = append(res, toArg(params.First()))
+	}
+	return res
+}
+
+// sqlParams splits sqlParams, a string or a vector of
+// a string followed by its parameters.
+func sqlParams(sqlParams Object) (string, []interface{}) {
+	switch sp := sqlParams.(type) {
+	case String:
+		return sp.S, nil
+	case Vec:
+		if sp.Count() == 0 {
+			panic(RT.NewError("SQL vector must not be empty"))
+		}
+		s := sp.Seq()
+		return EnsureObjectIsString(s.First(), "SQL: %s").S, toArgs(s.Rest())
+	default:
+		panic(RT.NewArgTypeError(1, sqlParams, "String or Vector"))
+	}
+}
+
+func fromValue(v interface{}) Object {
+	switch v := v.(type) {
+	case nil:
+		return NIL
+	case int64:
+		return MakeInt(int(v))
+	case float64:
+		return MakeDouble(v)
+	case string:
+		return MakeString(v)
+	case []byte:
+		return MakeString(string(v))
+	case bool:
+		return MakeBoolean(v)
+	case time.Time:
+		return MakeTime(v)
+	default:
+		return MakeString(fmt.Sprint(v))
+	}
+}
+
+// Statements prepared on databases with a single connection.
+var singleConnStmts sync.Map
+
+// isSingleConn returns whether c is a database (or a statement
+// prepared on one) with a single connection, which rows that are
+// read lazily would keep from any other query until they're closed.
+func isSingleConn(c Object) bool {
+	switch c := c.(type) {
+	case SQLDB:
+		return c.Stats().MaxOpenConnections == 1
+	case SQLStmt:
+		_, ok := singleConnStmts.Load(c.Stmt)
+		return ok
+	}
+	return false
+}
+
+// rowReader reads rows as maps of keyword column names to values.
+type rowReader struct {
+	rows    *sql.Rows
+	columns []Keyword
+	values  []interface{}
+	ptrs    []interface{}
+	// Rows read in advance, if buffered.
+	buffered []Map
+	// Set by close, after which no more rows can be read.
+	closed bool
+}
+
+func newRowReader(rows *sql.Rows) *rowReader {
+	names, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		panic(RT.NewError(err.Error()))
+	}
+	r := &rowReader{
+		rows:    rows,
+		columns: make([]Keyword, len(names)),
+		values:  make([]interface{}, len(names)),
+		ptrs:    make([]interface{}, len(names)),
+	}
+	for i, name := range names {
+		r.columns[i] = MakeKeyword(name)
+		r.ptrs[i] = &r.values[i]
+	}
+	return r
+}
+
+// next returns the next row, or nil once the rows are exhausted,
+// at which point they are closed.
+func (r *rowReader) next() Map {
+	if r.closed {
+		panic(RT.NewError("Rows of lazy-query have been closed"))
+	}
+	if r.buffered != nil {
+		if len(r.buffered) == 0 {
+			return nil
+		}
+		row := r.buffered[0]
+		r.buffered = r.buffered[1:]
+		return row
+	}
+	return r.read()
+}
+
+// read reads the next row from the database.
+func (r *rowReader) read() Map {
+	if !r.rows.Next() {
+		err := r.rows.Err()
+		r.rows.Close()
+		PanicOnErr(err)
+		return nil
+	}
+	if err := r.rows.Scan(r.ptrs...); err != nil {
+		r.rows.Close()
+		panic(RT.NewError(err.Error()))
+	}
+	row := EmptyArrayMap()
+	for i, k := range r.columns {
+		row.Add(k, fromValue(r.values[i]))
+	}
+	return row
+}
+
+// buffer reads all the remaining rows in advance, releasing
+// their connection.
+func (r *rowReader) buffer() {
+	r.buffered = []Map{}
+	for row := r.read(); row != nil; row = r.read() {
+		r.buffered = append(r.buffered, row)
+	}
+}
+
+// close closes the rows, releasing their connection, no matter
+// how many of them have been read.
+func (r *rowReader) close() {
+	r.closed = true
+	r.rows.Close()
+}
+
+// lazySeq returns the lazy seq of the remaining rows.
+func (r *rowReader) lazySeq() *LazySeq {
+	return NewLazySeq(Proc{Fn: func(args []Object) Object {
+		row := r.next()
+		if row == nil {
+			return EmptyList
+		}
+		return NewConsSeq(row, r.lazySeq())
+	}, Name: "rows", Package: "std/sql"})
+}
+
+func runQuery(c Object, sp Object) *sql.Rows {
+	var rows *sql.Rows
+	var err error
+	if stmt, ok := c.(SQLStmt); ok {
+		rows, err = stmt.Query(toArgs(EnsureObjectIsSeqable(sp, "params: %s").Seq())...)
+	} else {
+		query, args := sqlParams(sp)
+		rows, err = toConn(c).Query(query, args...)
+	}
+	PanicOnErr(err)
+	return rows
+}
+
+func query(c Object, sp Object) Object {
+	r := newRowReader(runQuery(c, sp))
+	res := EmptyVector()
+	for row := r.next(); row != nil; row = r.next() {
+		res = res.Conjoin(row)
+	}
+	return res
+}
+
+// newLazyRowReader returns a reader of the rows of a query that
+// are read as they're needed, unless the connection they'd hold
+// is the only one.
+func newLazyRowReader(c Object, sp Object) *rowReader {
+	r := newRowReader(runQuery(c, sp))
+	if isSingleConn(c) {
+		r.buffer()
+	}
+	return r
+}
+
+func lazyQuery(c Object, sp Object) Object {
+	r := newLazyRowReader(c, sp)
+	// A seq that is dropped before it's fully realized would
+	// hold the connection forever.
+	runtime.SetFinalizer(r, func(r *rowReader) { r.rows.Close() })
+	return r.lazySeq()
+}
+
+// withLazyQuery calls f with the lazy seq of the rows and closes
+// them once f returns or throws, however many have been realized.
+func withLazyQuery(c Object, sp Object, f Callable) Object {
+	r := newLazyRowReader(c, sp)
+	defer r.close()
+	return f.Call([]Object{r.lazySeq()})
+}
+
+func execute(c Object, sp Object) Map {
+	var res sql.Result
+	var err error
+	if stmt, ok := c.(SQLStmt); ok {
+		res, err = stmt.Exec(toArgs(EnsureObjectIsSeqable(sp, "params: %s").Seq())...)
+	} else {
+		query, args := sqlParams(sp)
+		res, err = toConn(c).Exec(query, args...)
+	}
+	PanicOnErr(err)
+	m := EmptyArrayMap()
+	if n, err := res.RowsAffected(); err == nil {
+		m.Add(MakeKeyword("rows-affected"), MakeInt(int(n)))
+	}
+	if id, err := res.LastInsertId(); err == nil {
+		m.Add(MakeKeyword("last-insert-id"), MakeInt(int(id)))
+	}
+	return m
+}
+
+func prepare(c Object, query string) *sql.Stmt {
+	stmt, err := toConn(c).Prepare(query)
+	PanicOnErr(err)
+	if isSingleConn(c) {
+		singleConnStmts.Store(stmt, true)
+	}
+	return stmt
+}
+
+var isolationLevels = map[string]sql.IsolationLevel{
+	"default":          sql.LevelDefault,
+	"read-uncommitted": sql.LevelReadUncommitted,
+	"read-committed":   sql.LevelReadCommitted,
+	"write-committed":  sql.LevelWriteCommitted,
+	"repeatable-read":  sql.LevelRepeatableRead,
+	"snapshot":         sql.LevelSnapshot,
+	"serializable":     sql.LevelSerializable,
+	"linearizable":     sql.LevelLinearizable,
+}
+
+func txOptions(opts Map) *sql.TxOptions {
+	res := &sql.TxOptions{}
+	if ok, level := opts.Get(MakeKeyword("isolation")); ok {
+		l, found := isolationLevels[EnsureObjectIsKeyword(level, "isolation: %s").Name()]
+		if !found {
+			panic(RT.NewError("Unknown isolation level: " + level.ToString(true)))
+		}
+		res.Isolation = l
+	}
+	if ok, ro := opts.Get(MakeKeyword("read-only")); ok {
+		res.ReadOnly = ToBool(ro)
+	}
+	return res
+}
+
+// withTransaction calls f with a transaction on c, which is committed
+// if f returns and rolled back if it throws. If c is a transaction
+// already, f is called with it, to be committed or rolled back
+// along with the rest of it.
+func withTransaction(c Object, opts Map, f Callable) Object {
+	var db *sql.DB
+	switch c := c.(type) {
+	case SQLTx:
+		return f.Call([]Object{c})
+	case SQLDB:
+		db = c.DB
+	default:
+		panic(RT.NewArgTypeError(0, c, "SQLDB or SQLTx"))
+	}
+	tx, err := db.BeginTx(context.Background(), txOptions(opts))
+	PanicOnErr(err)
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+	res := f.Call([]Object{MakeSQLTx(tx)})
+	committed = true
+	PanicOnErr(tx.Commit())
+	return res
+}
+
+func init() {
+	sqlDBType = RegType("SQLDB", (*SQLDB)(nil), "Wraps Go 'database/sql.DB' type")
+	sqlTxType = RegType("SQLTx", (*SQLTx)(nil), "Wraps Go 'database/sql.Tx' type")
+	sqlStmtType = RegType("SQLStmt", (*SQLStmt)(nil), "Wraps Go 'database/sql.Stmt' type")
+}
@@ -0,0 +1,38 @@
+// This file is generated by generate-std.joke script. Do not edit manually!
+
+//go:build !gen_code
+// +build !gen_code
+
+package time
+
+import (
+	"fmt"
+	. "github.com/candid82/joker/core"
+	"os"
+)
+
+func InternsOrThunks() {
+	if VerbosityLevel > 0 {
+		fmt.Fprintln(os.Stderr, "Lazily running fast version of time.InternsOrThunks().")
+	}
+	STD_thunk_time_add__var = __add_
+	STD_thunk_time_add_date__var = __add_date_
+	STD_thunk_time_day_of_year__var = __day_of_year_
+	STD_thunk_time_format__var = __format_
+	STD_thunk_time_from_unix__var = __from_unix_
+	STD_thunk_time_hours__var = __hours_
+	STD_thunk_time_in_timezone__var = __in_timezone_
+	STD_thunk_time_minutes__var = __minutes_
+	STD_thunk_time_now__var = __now_
+	STD_thunk_time_parse__var = __parse_
+	STD_thunk_time_parse_duration__var = __parse_duration_
+	STD_thunk_time_round__var = __round_
+	STD_thunk_time_seconds__var = __seconds_
+	STD_thunk_time_since__var = __since_
+	STD_thunk_time_sleep__var = __sleep_
+	STD_thunk_time_string__var = __string_
+	STD_thunk_time_sub__var = __sub_
+	STD_thunk_time_truncate__var = __truncate_
+	STD_thunk_time_unix__var = __unix_
+	STD_thunk_time_until__var = __until_
+}
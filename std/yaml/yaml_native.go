@@ -57,17 +57,17 @@ func toObject(v interface{}) Object {
 	case nil:
 		return NIL
 	case []interface{}:
-		res := EmptyVector()
+		res := NewTransientVector(EmptyVector())
 		for _, v := range v {
-			res = res.Conjoin(toObject(v))
+			res.TransientConj(toObject(v))
 		}
-		return res
+		return res.Persistent()
 	case map[interface{}]interface{}:
-		res := EmptyArrayMap()
+		res := NewTransientMap(EmptyArrayMap())
 		for k, v := range v {
-			res.Add(toObject(k), toObject(v))
+			res.TransientAssoc(toObject(k), toObject(v))
 		}
-		return res
+		return res.Persistent()
 	default:
 		panic(RT.NewError(fmt.Sprintf("Unknown yaml value: %v", v)))
 	}
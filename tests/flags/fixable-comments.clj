@@ -0,0 +1,8 @@
+(ns fixable-comments
+  (:require ; keep me
+   [clojure.set :as set]
+   [clojure.string :as s] ; strings
+   ;; walking
+   [clojure.walk :as w] ; trees
+   [clojure.data :as d]))
+(s/join (d/diff 1 2))
@@ -0,0 +1,2 @@
+(ns fixable (:require [clojure.string :as s] [clojure.set :as set]))
+(s/join (let [[a :as v] [1]] (do a)))
@@ -0,0 +1,4 @@
+(ns app.bad)
+
+(defn g [x]
+(inc x))
@@ -0,0 +1,4 @@
+(ns app.good)
+
+(defn f [x]
+  (inc x))
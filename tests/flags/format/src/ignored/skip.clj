@@ -0,0 +1,3 @@
+(ns app.skip)
+(defn h [x]
+(inc x))
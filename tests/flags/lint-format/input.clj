@@ -0,0 +1,6 @@
+(ns lint-format)
+
+(defn f [a] (let [b 1] a))
+(a<b&c 1)
+(f 1 2)
+"\q"
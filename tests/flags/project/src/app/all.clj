@@ -0,0 +1,7 @@
+(ns app.all)
+
+(defn helper [x] x)
+
+(defn- private-one [] 1)
+
+(private-one)
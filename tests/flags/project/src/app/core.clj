@@ -0,0 +1,15 @@
+(ns app.core
+  (:require [app.util :as u]
+            [app.macros :refer [with-thing]]
+            [app.defs :as d]
+            [app.all :refer :all]))
+
+(defn run []
+  (u/add 1)
+  (u/nope 2)
+  (helper 1 2)
+  (private-one)
+  (d/made-by-macro))
+
+(defn run2 []
+  (with-thing [x 1] (inc x)))
@@ -0,0 +1,4 @@
+(ns app.defs
+  (:require [app.macros :refer [defthing]]))
+
+(defthing made-by-macro)
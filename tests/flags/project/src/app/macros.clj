@@ -0,0 +1,7 @@
+(ns app.macros)
+
+(defmacro with-thing [bindings & body]
+  `(let ~bindings ~@body))
+
+(defmacro defthing [name]
+  `(def ~name 1))
@@ -0,0 +1,10 @@
+(ns app.util)
+
+(defn add [a b]
+  (+ a b))
+
+(defn sub [a b]
+  (early a b))
+
+(defn early [a b]
+  (- a b))
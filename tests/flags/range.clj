@@ -0,0 +1,10 @@
+(ns range)
+
+(defn f [x]
+(inc x))
+
+(defn broken [x
+  (dec x)
+
+(defn g [x]
+(* x  2))
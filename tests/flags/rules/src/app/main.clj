@@ -0,0 +1,7 @@
+(ns app.main
+  (:require [lib.util :as u]))
+
+(defn -main
+  "Entry point."
+  []
+  (println (u/greet "world") (u/ok 1)))
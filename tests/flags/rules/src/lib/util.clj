@@ -0,0 +1,13 @@
+(ns lib.util
+  (:require [clojure.java.shell :as sh]))
+
+(defn greet
+  [name]
+  (println "Hello" name)
+  (sh/sh "ls"))
+
+(defn ok
+  "Documented."
+  [x]
+  #_{:joker/ignore [:no-println]}
+  (println x))
@@ -0,0 +1,36 @@
+(ns my.app
+  (:require [my.zoo :as zoo]
+            [my.app.db :as db]))
+(defthing users
+  {:table "users"})
+
+
+
+(defthing
+  orders
+  :table "orders")
+(my.routes/defroutes app
+  (GET "/" [] "index")
+  (GET "/users" [] (db/users)))
+;; A comment stays with its form.
+(defservice users-service
+  (start [this]
+    (db/connect this))
+  (stop [this]
+    (db/disconnect this)))
+
+(with-conn [c db/spec]
+  (db/query c "select 1"))
+
+(def config
+  {:host "localhost"
+   :port 5432
+   :database-name "app"})
+
+(let [a 1
+      bbb 2
+      cc (+ a bbb)]
+  (println a bbb cc))
+
+(defn long-call []
+  (some-function-with-a-long-name :first-argument :second-argument :third-argument :fourth))
@@ -0,0 +1,24 @@
+(ns width.test)
+
+(def primes
+  [2 3 5 7 11 13 17 19 23 29 31 37 41 43 47 53 59 61 67 71 73 79 83 89 97 101
+   103 107 109])
+
+(def config
+  {:server {:host "localhost" :port 8080}
+   :db {:host "db.example.com" :port 5432 :user "app"}})
+
+(def small {:a 1 :b [1 2 3]})
+
+(defn handler [request]
+  (respond {:status 200
+            :headers {"Content-Type" "application/json"}
+            :body (encode (:params request))}))
+
+(def tags
+  #{:alpha :beta :gamma :delta :epsilon :zeta :eta :theta :iota :kappa :lambda})
+
+(def vertical
+  [1
+   2
+   3])
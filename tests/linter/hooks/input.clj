@@ -0,0 +1,18 @@
+(ns my.test
+  (:require [my.web :refer [defendpoint with-conn broken]]))
+
+(def db {:host "localhost"})
+
+(defendpoint get-user "/users/:id"
+  [req id]
+  (with-conn [conn db]
+    (str id)))
+
+(defendpoint list-users "/users"
+  [req]
+  (with-conn [conn dbb]
+    (str conn)))
+
+(get-user {} 1 2)
+
+(broken [x 1] x)
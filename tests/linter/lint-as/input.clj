@@ -0,0 +1,22 @@
+(ns my.test
+  (:require [my.macros :as m :refer [my-let]]))
+
+(my-let [x 1
+         y 2]
+  (inc x))
+
+(m/my-let [a 1]
+  (+ a b))
+
+(m/defthing thing 42)
+
+(inc thing)
+
+(m/defn+ add
+  [x y]
+  (+ x y))
+
+(add 1)
+
+(m/when-some+ [v (add 1 2)]
+  (println v))
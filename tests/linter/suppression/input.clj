@@ -0,0 +1,25 @@
+(ns suppression (:require #_:joker/ignore [clojure.string :as str]
+                [clojure.set :as set]))
+
+#_:joker/ignore
+(let [a 1] (if true 2))
+
+#_{:joker/ignore [:unused-binding]}
+(let [b 1] (if true 2))
+
+(defn ^{:joker/lint {:ignore [:unused-binding]}} f [x]
+  (let [c 1] x))
+
+^{:joker/lint {:ignore [:if-without-else]}}
+(let [d 1] d)
+
+#_:joker/ignore
+(foo)
+
+(let [e #_:joker/ignore (inc)] e)
+
+#_{:joker/ignore [:empty-cond]}
+(cond)
+
+#_{:ignore true}
+(let [g 1] (if true 2))